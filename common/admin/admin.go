@@ -0,0 +1,230 @@
+// Package admin serves the Admin gRPC service on a separate listener: build info, effective config,
+// open connections, in-flight requests, log level and storage of a running server.
+//
+// Every call should have the configured token in "authorization: Bearer <token>" metadata. The token isn't
+// protected by TLS, so the listener should still be bound to a loopback or private address.
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+
+	logger "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type (
+	Config struct {
+		Enabled bool   `json:"enabled" yaml:"enabled" default:"false"`
+		Host    string `json:"host" yaml:"host" default:"localhost:8095"`
+
+		// Token authenticates admin calls. It's required if admin is enabled and could be a secret reference.
+		Token string `json:"token" yaml:"token" secret:"true" validate:"required_if=Enabled true"`
+	}
+
+	// ConfigSource lists the effective config, config.Manager implements it
+	ConfigSource interface {
+		Fields() ([]config.Field, error)
+	}
+
+	// Storage is implemented by servers that store files
+	Storage interface {
+		StorageStats(ctx context.Context) (*api.StorageStats, error)
+		RunRetention(ctx context.Context, dryRun bool) (*api.RetentionReport, error)
+	}
+
+	// Event is an admin call that changes the server or is rejected, see OnAudit
+	Event struct {
+		Method     string
+		RemoteAddr string
+
+		// Code is the result of the call, it's Unauthenticated for calls without a valid token
+		Code codes.Code
+
+		// Request is the JSON request of a call that is authenticated
+		Request string
+	}
+
+	// AuditFunc records an admin event, e.g. to the audit trail of the server
+	AuditFunc func(ctx context.Context, ev Event)
+
+	Server struct {
+		api.UnimplementedAdminServer
+
+		config    Config
+		name      string
+		startedAt time.Time
+		source    ConfigSource
+		tracker   *Tracker
+		storage   Storage
+		audit     AuditFunc
+		log       *logger.Logger
+	}
+)
+
+// New creates an admin server of the server name. storage could be nil.
+func New(config Config, name string, source ConfigSource, tracker *Tracker, storage Storage, log *logger.Logger) *Server {
+	return &Server{
+		config:    config,
+		name:      name,
+		startedAt: time.Now(),
+		source:    source,
+		tracker:   tracker,
+		storage:   storage,
+		log:       log,
+	}
+}
+
+// OnAudit sets a func that records calls changing the server and calls that are rejected. It should be set before Start.
+func (s *Server) OnAudit(f AuditFunc) {
+	s.audit = f
+}
+
+// Start listens on the admin host and serves until ctx is done
+func (s *Server) Start(ctx context.Context) error {
+
+	lis, err := net.Listen("tcp", s.config.Host)
+	if err != nil {
+		return fmt.Errorf("net.Listen: %w", err)
+	}
+
+	grpcServer := s.NewGrpcServer()
+
+	go func() {
+		<-ctx.Done()
+		grpcServer.GracefulStop()
+	}()
+
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			s.log.Errorf("admin server: %v", err)
+		}
+	}()
+
+	s.log.Infof("admin server is listening on %s", lis.Addr())
+
+	return nil
+}
+
+// NewGrpcServer creates a gRPC server with the Admin service and authentication. It's served by Start or on any listener.
+func (s *Server) NewGrpcServer() *grpc.Server {
+
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(s.authInterceptor))
+	api.RegisterAdminServer(grpcServer, s)
+
+	return grpcServer
+}
+
+func (s *Server) GetBuildInfo(context.Context, *emptypb.Empty) (*api.BuildInfo, error) {
+
+	info := api.BuildInfo{
+		Server:    s.name,
+		StartedAt: timestamppb.New(s.startedAt),
+		Pid:       int64(os.Getpid()),
+	}
+	info.Hostname, _ = os.Hostname()
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return &info, nil
+	}
+
+	info.Version = bi.Main.Version
+	info.GoVersion = bi.GoVersion
+
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			if t, err := time.Parse(time.RFC3339, setting.Value); err == nil {
+				info.RevisionTime = timestamppb.New(t)
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+
+	return &info, nil
+}
+
+func (s *Server) GetConfig(context.Context, *emptypb.Empty) (*api.GetConfigResponse, error) {
+
+	fields, err := s.source.Fields()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := api.GetConfigResponse{Fields: make([]*api.ConfigField, 0, len(fields))}
+	for _, f := range fields {
+		resp.Fields = append(resp.Fields, &api.ConfigField{Path: f.Path, Value: f.Value, Reloadable: f.Reloadable})
+	}
+
+	return &resp, nil
+}
+
+func (s *Server) ListConnections(context.Context, *emptypb.Empty) (*api.ListConnectionsResponse, error) {
+	return &api.ListConnectionsResponse{Connections: s.tracker.Connections()}, nil
+}
+
+func (s *Server) ListRequests(context.Context, *emptypb.Empty) (*api.ListRequestsResponse, error) {
+	return &api.ListRequestsResponse{Requests: s.tracker.Requests()}, nil
+}
+
+func (s *Server) SetLogLevel(_ context.Context, req *api.SetLogLevelRequest) (*api.SetLogLevelResponse, error) {
+
+	level, err := logger.ParseLevel(strings.TrimSpace(req.Level))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	previous := s.log.GetLevel()
+	s.log.SetLevel(level)
+
+	s.log.Warnf("admin: log level is changed from %s to %s", previous, level)
+
+	return &api.SetLogLevelResponse{Previous: previous.String(), Level: level.String()}, nil
+}
+
+func (s *Server) GetStorageStats(ctx context.Context, _ *emptypb.Empty) (*api.StorageStats, error) {
+
+	if s.storage == nil {
+		return nil, status.Errorf(codes.Unimplemented, "%s has no storage", s.name)
+	}
+
+	stats, err := s.storage.StorageStats(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return stats, nil
+}
+
+func (s *Server) RunRetention(ctx context.Context, req *api.RunRetentionRequest) (*api.RetentionReport, error) {
+
+	if s.storage == nil {
+		return nil, status.Errorf(codes.Unimplemented, "%s has no storage", s.name)
+	}
+
+	s.log.Infof("admin: running retention, dry run: %v", req.DryRun)
+
+	report, err := s.storage.RunRetention(ctx, req.DryRun)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return report, nil
+}
@@ -0,0 +1,134 @@
+package admin_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+
+	logger "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const token = "s3cret"
+
+type noConfig struct{}
+
+func (noConfig) Fields() ([]config.Field, error) { return nil, nil }
+
+// startAdmin serves admin over bufconn and returns a client and the recorded events
+func startAdmin(t *testing.T) (api.AdminClient, *[]admin.Event) {
+	t.Helper()
+
+	log := logger.New()
+	log.SetLevel(logger.InfoLevel)
+
+	var events []admin.Event
+
+	srv := admin.New(admin.Config{Enabled: true, Token: token}, "test", noConfig{}, admin.NewTracker(), nil, log)
+	srv.OnAudit(func(_ context.Context, ev admin.Event) {
+		events = append(events, ev)
+	})
+
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := srv.NewGrpcServer()
+
+	go func() { _ = grpcServer.Serve(lis) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+	)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return api.NewAdminClient(conn), &events
+}
+
+func TestAuthentication(t *testing.T) {
+
+	client, events := startAdmin(t)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{name: "no token", ctx: context.Background(), code: codes.Unauthenticated},
+		{name: "wrong token", ctx: admin.WithToken(context.Background(), "guess"), code: codes.Unauthenticated},
+		{name: "empty token", ctx: admin.WithToken(context.Background(), ""), code: codes.Unauthenticated},
+		{name: "token", ctx: admin.WithToken(context.Background(), token), code: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			*events = nil
+
+			_, err := client.GetBuildInfo(tt.ctx, &emptypb.Empty{})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("code is %s, expected %s", code, tt.code)
+			}
+
+			// Rejected calls are audited, read-only calls are not
+			if tt.code == codes.OK && len(*events) != 0 {
+				t.Errorf("read-only call is audited: %+v", *events)
+			}
+			if tt.code != codes.OK && (len(*events) != 1 || (*events)[0].Code != codes.Unauthenticated) {
+				t.Errorf("rejected call is not audited: %+v", *events)
+			}
+		})
+	}
+}
+
+func TestAuditMutations(t *testing.T) {
+
+	client, events := startAdmin(t)
+	ctx := admin.WithToken(context.Background(), token)
+
+	if _, err := client.SetLogLevel(ctx, &api.SetLogLevelRequest{Level: "debug"}); err != nil {
+		t.Fatalf("setting log level: %v", err)
+	}
+
+	if _, err := client.SetLogLevel(ctx, &api.SetLogLevelRequest{Level: "loud"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+
+	// The server has no storage, a failed call is audited as well
+	if _, err := client.RunRetention(ctx, &api.RunRetentionRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected Unimplemented, got %v", err)
+	}
+
+	expected := []struct {
+		method  string
+		code    codes.Code
+		request string
+	}{
+		{method: "/grpc_rest.admin.v1.Admin/SetLogLevel", code: codes.OK, request: "debug"},
+		{method: "/grpc_rest.admin.v1.Admin/SetLogLevel", code: codes.InvalidArgument, request: "loud"},
+		{method: "/grpc_rest.admin.v1.Admin/RunRetention", code: codes.Unimplemented},
+	}
+
+	if len(*events) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), *events)
+	}
+
+	for i, e := range expected {
+		ev := (*events)[i]
+		if ev.Method != e.method || ev.Code != e.code || !strings.Contains(ev.Request, e.request) || len(ev.RemoteAddr) == 0 {
+			t.Errorf("event %d is %+v, expected %+v", i, ev, e)
+		}
+	}
+}
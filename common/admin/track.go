@@ -0,0 +1,294 @@
+package admin
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/gateway"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Listeners of connections and requests
+const (
+	ListenerGRPC = "grpc"
+	ListenerREST = "rest"
+)
+
+type (
+	// Tracker keeps open connections and in-flight requests of server listeners. Connections are keyed
+	// by net.Conn for REST and by *connection for gRPC.
+	// A nil tracker tracks nothing, so it could be passed if admin is disabled.
+	Tracker struct {
+		mu       sync.Mutex
+		nextID   uint64
+		conns    map[interface{}]*connection
+		requests map[uint64]*request
+	}
+
+	connection struct {
+		listener   string
+		remoteAddr string
+		openedAt   time.Time
+		state      string
+	}
+
+	request struct {
+		listener   string
+		method     string
+		requestID  string
+		principal  string
+		remoteAddr string
+		startedAt  time.Time
+		received   atomic.Int64
+	}
+
+	countingBody struct {
+		io.ReadCloser
+		received *atomic.Int64
+	}
+
+	grpcStats struct {
+		t *Tracker
+	}
+
+	connKey struct{}
+)
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		conns:    make(map[interface{}]*connection),
+		requests: make(map[uint64]*request),
+	}
+}
+
+// ConnState tracks connections of an http.Server
+func (t *Tracker) ConnState(c net.Conn, state http.ConnState) {
+
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch state {
+	case http.StateNew:
+		t.conns[c] = &connection{
+			listener:   ListenerREST,
+			remoteAddr: c.RemoteAddr().String(),
+			openedAt:   time.Now(),
+			state:      state.String(),
+		}
+
+	case http.StateActive, http.StateIdle:
+		if conn, ok := t.conns[c]; ok {
+			conn.state = state.String()
+		}
+
+	case http.StateHijacked, http.StateClosed:
+		delete(t.conns, c)
+	}
+}
+
+// StatsHandler tracks connections of a gRPC server. It's nil for a nil tracker.
+func (t *Tracker) StatsHandler() stats.Handler {
+	if t == nil {
+		return nil
+	}
+
+	return grpcStats{t: t}
+}
+
+// Middleware tracks REST requests while they are served
+func (t *Tracker) Middleware(next http.Handler) http.Handler {
+
+	if t == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		req := request{
+			listener:   ListenerREST,
+			method:     r.Method + " " + r.URL.Path,
+			requestID:  r.Header.Get(logging.HeaderRequestID),
+			principal:  r.Header.Get(logging.HeaderPrincipal),
+			remoteAddr: r.RemoteAddr,
+			startedAt:  time.Now(),
+		}
+
+		if r.Body != nil {
+			r.Body = &countingBody{ReadCloser: r.Body, received: &req.received}
+		}
+
+		defer t.add(&req)()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryInterceptor tracks gRPC calls while they are served except the ones made by the gateway
+func (t *Tracker) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		if t == nil {
+			return handler(ctx, req)
+		}
+
+		// Gateway calls are tracked by the REST middleware
+		if gateway.IsGatewayCall(ctx) {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		r := request{
+			listener:  ListenerGRPC,
+			method:    info.FullMethod,
+			requestID: logging.RequestID(ctx),
+			startedAt: time.Now(),
+		}
+
+		if values := md.Get(logging.MetadataPrincipal); len(values) > 0 {
+			r.principal = values[0]
+		}
+		if p, ok := peer.FromContext(ctx); ok {
+			r.remoteAddr = p.Addr.String()
+		}
+		if m, ok := req.(proto.Message); ok {
+			r.received.Store(int64(proto.Size(m)))
+		}
+
+		defer t.add(&r)()
+
+		return handler(ctx, req)
+	}
+}
+
+// add tracks a request and returns a func that removes it
+func (t *Tracker) add(r *request) func() {
+
+	t.mu.Lock()
+	t.nextID++
+	id := t.nextID
+	t.requests[id] = r
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		delete(t.requests, id)
+		t.mu.Unlock()
+	}
+}
+
+// Connections returns open connections, the oldest first
+func (t *Tracker) Connections() []*api.Connection {
+
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make([]*api.Connection, 0, len(t.conns))
+	for _, c := range t.conns {
+		res = append(res, &api.Connection{
+			Listener:   c.listener,
+			RemoteAddr: c.remoteAddr,
+			OpenedAt:   timestamppb.New(c.openedAt),
+			State:      c.state,
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].OpenedAt.AsTime().Before(res[j].OpenedAt.AsTime()) })
+
+	return res
+}
+
+// Requests returns in-flight requests, the oldest first
+func (t *Tracker) Requests() []*api.Request {
+
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make([]*api.Request, 0, len(t.requests))
+	for _, r := range t.requests {
+		res = append(res, &api.Request{
+			Listener:      r.listener,
+			Method:        r.method,
+			RequestId:     r.requestID,
+			Principal:     r.principal,
+			RemoteAddr:    r.remoteAddr,
+			StartedAt:     timestamppb.New(r.startedAt),
+			BytesReceived: r.received.Load(),
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].StartedAt.AsTime().Before(res[j].StartedAt.AsTime()) })
+
+	return res
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.received.Add(int64(n))
+	return n, err
+}
+
+func (h grpcStats) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+
+	remoteAddr := ""
+	if info.RemoteAddr != nil {
+		remoteAddr = info.RemoteAddr.String()
+	}
+
+	return context.WithValue(ctx, connKey{}, &connection{
+		listener:   ListenerGRPC,
+		remoteAddr: remoteAddr,
+		state:      "active",
+	})
+}
+
+func (h grpcStats) HandleConn(ctx context.Context, s stats.ConnStats) {
+
+	c, ok := ctx.Value(connKey{}).(*connection)
+	if !ok {
+		return
+	}
+
+	h.t.mu.Lock()
+	defer h.t.mu.Unlock()
+
+	switch s.(type) {
+	case *stats.ConnBegin:
+		c.openedAt = time.Now()
+		h.t.conns[c] = c
+	case *stats.ConnEnd:
+		delete(h.t.conns, c)
+	}
+}
+
+func (h grpcStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h grpcStats) HandleRPC(context.Context, stats.RPCStats) {}
@@ -0,0 +1,77 @@
+// openapi-sdkgen generates a typed REST client from Swagger 2.0 or OpenAPI 3.1 document.
+//
+//	go run github.com/yurii-vyrovyi/go-grpc-rest/common/cmd/openapi-sdkgen \
+//	  -spec ./api/service.swagger.json -package sdk -trim-prefix v1 -out ./api/sdk/sdk.gen.go
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+//go:embed sdk.go.tmpl
+var sdkTemplate string
+
+func main() {
+
+	spec := flag.String("spec", "", "Swagger 2.0 or OpenAPI 3.1 JSON document")
+	out := flag.String("out", "", "output file")
+	pkgName := flag.String("package", "sdk", "package name")
+	trimPrefix := flag.String("trim-prefix", "", "prefix that is trimmed from schema names, e.g. v1")
+	flag.Parse()
+
+	if err := run(*spec, *out, *pkgName, *trimPrefix); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(spec, out, pkgName, trimPrefix string) error {
+
+	if len(spec) == 0 || len(out) == 0 {
+		return fmt.Errorf("both -spec and -out are required")
+	}
+
+	doc, err := LoadDoc(spec)
+	if err != nil {
+		return fmt.Errorf("loading spec: %w", err)
+	}
+
+	b := builder{trimPrefix: trimPrefix}
+
+	pkg, err := b.Build(doc, pkgName, filepath.Base(spec))
+	if err != nil {
+		return fmt.Errorf("building package: %w", err)
+	}
+
+	tmpl, err := template.New("sdk").Parse(sdkTemplate)
+	if err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+
+	buf := bytes.Buffer{}
+	if err := tmpl.Execute(&buf, pkg); err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting source: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return fmt.Errorf("creating output dir: %w", err)
+	}
+
+	if err := os.WriteFile(out, src, 0600); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const refSchemas = "#/components/schemas/"
+
+type (
+	Package struct {
+		Name      string
+		Source    string
+		Title     string
+		Version   string
+		Models    []Model
+		Ops       []Op
+		ErrorType string
+	}
+
+	Model struct {
+		Name        string
+		Description string
+		Fields      []Field
+		IsMap       bool
+	}
+
+	Field struct {
+		Name     string
+		Type     string
+		JsonName string
+		AsString bool
+	}
+
+	Op struct {
+		Name         string
+		Method       string
+		Path         string
+		Summary      string
+		BodyType     string
+		ResponseType string
+		SuccessCodes []int
+		Multipart    *MultipartBody
+
+		// PathArgs are method arguments for path template parameters, PathExpr builds the path from them
+		PathArgs []Arg
+		PathExpr string
+
+		// Query is a struct with query parameters. It's nil if the operation has none
+		Query *QueryParams
+	}
+
+	Arg struct {
+		Name string
+		Type string
+	}
+
+	QueryParams struct {
+		Name   string
+		Fields []QueryField
+	}
+
+	QueryField struct {
+		Name     string
+		Type     string
+		Param    string
+		Repeated bool
+
+		// IsSet is a Go expression that reports a non-zero value of the field
+		IsSet string
+	}
+
+	MultipartBody struct {
+		Name      string
+		JsonParts []Part
+		FileParts []Part
+	}
+
+	Part struct {
+		Name        string
+		FieldName   string
+		Type        string
+		ContentType string
+		Repeated    bool
+	}
+)
+
+type builder struct {
+	trimPrefix string
+}
+
+func (b *builder) Build(doc *Doc, pkgName, source string) (*Package, error) {
+
+	pkg := Package{
+		Name:    pkgName,
+		Source:  source,
+		Title:   doc.Info.Title,
+		Version: doc.Info.Version,
+	}
+
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		model, err := b.model(name, doc.Components.Schemas[name])
+		if err != nil {
+			return nil, fmt.Errorf("schema [%s]: %w", name, err)
+		}
+
+		pkg.Models = append(pkg.Models, model)
+	}
+
+	for _, path := range sortedKeys(doc.Paths) {
+		for _, method := range sortedKeys(doc.Paths[path]) {
+			op := doc.Paths[path][method]
+
+			ops, errType, err := b.ops(path, method, op)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+
+			pkg.Ops = append(pkg.Ops, ops...)
+
+			if len(errType) > 0 {
+				if len(pkg.ErrorType) > 0 && pkg.ErrorType != errType {
+					return nil, fmt.Errorf("operations have different error types [%s] [%s]", pkg.ErrorType, errType)
+				}
+				pkg.ErrorType = errType
+			}
+		}
+	}
+
+	return &pkg, nil
+}
+
+func (b *builder) model(name string, s *Schema) (Model, error) {
+
+	model := Model{
+		Name:        b.typeName(name),
+		Description: s.Description,
+	}
+
+	if s.AdditionalProperties != nil {
+		model.IsMap = true
+		return model, nil
+	}
+
+	for _, propName := range sortedKeys(s.Properties) {
+		prop := s.Properties[propName]
+
+		goType, err := b.goType(prop)
+		if err != nil {
+			return Model{}, fmt.Errorf("property [%s]: %w", propName, err)
+		}
+
+		model.Fields = append(model.Fields, Field{
+			Name:     exportedName(propName),
+			Type:     goType,
+			JsonName: propName,
+			AsString: prop.TypeName() == "string" && (prop.Format == "int64" || prop.Format == "uint64"),
+		})
+	}
+
+	return model, nil
+}
+
+func (b *builder) ops(path, method string, src *Operation) ([]Op, string, error) {
+
+	name := src.OperationID
+	if i := strings.LastIndex(name, "_"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	op := Op{
+		Name:     exportedName(name),
+		Method:   methodConst(method),
+		Path:     path,
+		PathExpr: strconv.Quote(path),
+		Summary:  src.Summary,
+	}
+
+	if err := b.params(&op, src.Parameters); err != nil {
+		return nil, "", err
+	}
+
+	var errType string
+
+	for _, code := range sortedKeys(src.Responses) {
+		resp := src.Responses[code]
+		mt := resp.Content["application/json"]
+
+		if code == "default" {
+			if mt != nil && mt.Schema != nil {
+				t, err := b.goType(mt.Schema)
+				if err != nil {
+					return nil, "", fmt.Errorf("default response: %w", err)
+				}
+				errType = t
+			}
+			continue
+		}
+
+		statusCode, err := strconv.Atoi(code)
+		if err != nil || statusCode < 200 || statusCode > 299 {
+			continue
+		}
+
+		op.SuccessCodes = append(op.SuccessCodes, statusCode)
+
+		if mt != nil && mt.Schema != nil {
+			t, errResp := b.goType(mt.Schema)
+			if errResp != nil {
+				return nil, "", fmt.Errorf("response [%s]: %w", code, errResp)
+			}
+
+			if len(op.ResponseType) > 0 && op.ResponseType != t {
+				return nil, "", fmt.Errorf("success responses have different types [%s] [%s]", op.ResponseType, t)
+			}
+			op.ResponseType = t
+		}
+	}
+
+	if len(op.ResponseType) == 0 {
+		return nil, "", errors.New("no success response with JSON body")
+	}
+
+	var ops []Op
+
+	if src.RequestBody == nil {
+		return append(ops, op), errType, nil
+	}
+
+	if mt, ok := src.RequestBody.Content["application/json"]; ok {
+		t, err := b.goType(mt.Schema)
+		if err != nil {
+			return nil, "", fmt.Errorf("request body: %w", err)
+		}
+
+		jsonOp := op
+		jsonOp.BodyType = t
+		ops = append(ops, jsonOp)
+	}
+
+	if mt, ok := src.RequestBody.Content["multipart/form-data"]; ok {
+		if len(op.PathArgs) > 0 || op.Query != nil {
+			return nil, "", errors.New("parameters are not supported for multipart bodies")
+		}
+
+		mpOp := op
+		mpOp.Name += "Multipart"
+
+		body, err := b.multipartBody(mpOp.Name+"Body", mt)
+		if err != nil {
+			return nil, "", fmt.Errorf("multipart body: %w", err)
+		}
+		mpOp.Multipart = body
+
+		ops = append(ops, mpOp)
+	}
+
+	return ops, errType, nil
+}
+
+// params turns path parameters into method arguments and query parameters into <Op>Params struct
+func (b *builder) params(op *Op, params []*Parameter) error {
+
+	path := op.Path
+	var exprParts []string
+
+	for _, p := range params {
+
+		if p.Schema == nil {
+			return fmt.Errorf("parameter [%s]: no schema", p.Name)
+		}
+
+		t, err := b.goType(p.Schema)
+		if err != nil {
+			return fmt.Errorf("parameter [%s]: %w", p.Name, err)
+		}
+
+		switch p.In {
+
+		case "path":
+			placeholder := "{" + p.Name + "}"
+
+			i := strings.Index(path, placeholder)
+			if i < 0 {
+				return fmt.Errorf("path parameter [%s] is not in the path", p.Name)
+			}
+
+			arg := Arg{Name: argName(p.Name), Type: t}
+			op.PathArgs = append(op.PathArgs, arg)
+
+			exprParts = append(exprParts, strconv.Quote(path[:i]), fmt.Sprintf("url.PathEscape(fmt.Sprint(%s))", arg.Name))
+			path = path[i+len(placeholder):]
+
+		case "query":
+			if op.Query == nil {
+				op.Query = &QueryParams{Name: op.Name + "Params"}
+			}
+
+			field := QueryField{
+				Name:     exportedName(p.Name),
+				Type:     t,
+				Param:    p.Name,
+				Repeated: strings.HasPrefix(t, "[]"),
+			}
+
+			switch {
+			case field.Repeated || t == "string":
+				field.IsSet = fmt.Sprintf("len(p.%s) > 0", field.Name)
+			case t == "bool":
+				field.IsSet = "p." + field.Name
+			case strings.HasPrefix(t, "map"):
+				return fmt.Errorf("parameter [%s]: object query parameters are not supported", p.Name)
+			default:
+				field.IsSet = fmt.Sprintf("p.%s != 0", field.Name)
+			}
+
+			op.Query.Fields = append(op.Query.Fields, field)
+
+		default:
+			return fmt.Errorf("parameter [%s]: parameters in %s are not supported", p.Name, p.In)
+		}
+	}
+
+	if len(exprParts) > 0 {
+		if len(path) > 0 {
+			exprParts = append(exprParts, strconv.Quote(path))
+		}
+		op.PathExpr = strings.Join(exprParts, " + ")
+	}
+
+	return nil
+}
+
+func (b *builder) multipartBody(name string, mt *MediaType) (*MultipartBody, error) {
+
+	if mt.Schema == nil {
+		return nil, errors.New("no schema")
+	}
+
+	body := MultipartBody{Name: name}
+
+	for _, partName := range sortedKeys(mt.Schema.Properties) {
+		s := mt.Schema.Properties[partName]
+
+		part := Part{
+			Name:        partName,
+			FieldName:   exportedName(partName),
+			ContentType: mt.Encoding[partName].ContentType,
+		}
+
+		if s.TypeName() == "array" && s.Items != nil {
+			part.Repeated = true
+			s = s.Items
+		}
+
+		if s.IsBinary() {
+			if len(part.ContentType) == 0 {
+				part.ContentType = s.ContentMediaType
+			}
+			body.FileParts = append(body.FileParts, part)
+			continue
+		}
+
+		t, err := b.goType(s)
+		if err != nil {
+			return nil, fmt.Errorf("part [%s]: %w", partName, err)
+		}
+		part.Type = t
+
+		if len(part.ContentType) == 0 {
+			part.ContentType = "application/json"
+		}
+
+		body.JsonParts = append(body.JsonParts, part)
+	}
+
+	return &body, nil
+}
+
+func (b *builder) goType(s *Schema) (string, error) {
+
+	if s == nil {
+		return "", errors.New("empty schema")
+	}
+
+	if len(s.Ref) > 0 {
+		if !strings.HasPrefix(s.Ref, refSchemas) {
+			return "", fmt.Errorf("unsupported reference [%s]", s.Ref)
+		}
+		return b.typeName(strings.TrimPrefix(s.Ref, refSchemas)), nil
+	}
+
+	if len(s.AllOf) == 1 {
+		return b.goType(s.AllOf[0])
+	}
+
+	switch s.TypeName() {
+
+	case "string":
+		switch s.Format {
+		case "byte":
+			return "[]byte", nil
+		case "int64":
+			return "int64", nil
+		case "uint64":
+			return "uint64", nil
+		}
+		return "string", nil
+
+	case "integer":
+		switch s.Format {
+		case "int32":
+			return "int32", nil
+		case "uint32":
+			return "uint32", nil
+		}
+		return "int64", nil
+
+	case "number":
+		if s.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+
+	case "boolean":
+		return "bool", nil
+
+	case "array":
+		t, err := b.goType(s.Items)
+		if err != nil {
+			return "", fmt.Errorf("array items: %w", err)
+		}
+		return "[]" + t, nil
+
+	case "object", "":
+		return "map[string]interface{}", nil
+	}
+
+	return "", fmt.Errorf("unsupported type [%v]", s.Type)
+}
+
+// typeName turns schema name into Go type name trimming generated package prefix: v2SayHelloRequest -> SayHelloRequest
+func (b *builder) typeName(name string) string {
+
+	if len(b.trimPrefix) > 0 && strings.HasPrefix(name, b.trimPrefix) {
+		trimmed := strings.TrimPrefix(name, b.trimPrefix)
+		if len(trimmed) > 0 && unicode.IsUpper(rune(trimmed[0])) {
+			name = trimmed
+		}
+	}
+
+	return exportedName(name)
+}
+
+func exportedName(name string) string {
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	sb := strings.Builder{}
+	for _, w := range words {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		sb.WriteString(string(r))
+	}
+
+	res := sb.String()
+	if len(res) == 0 || unicode.IsDigit(rune(res[0])) {
+		res = "X" + res
+	}
+
+	return res
+}
+
+// argName makes a Go argument name that doesn't clash with names used in generated methods
+func argName(name string) string {
+
+	res := exportedName(name)
+	r := []rune(res)
+	r[0] = unicode.ToLower(r[0])
+	res = string(r)
+
+	switch res {
+	case "ctx", "body", "params", "req", "resp", "err", "reqBody", "buf", "c":
+		res += "Arg"
+	default:
+		if token.IsKeyword(res) {
+			res += "Arg"
+		}
+	}
+
+	return res
+}
+
+func methodConst(method string) string {
+
+	switch strings.ToUpper(method) {
+	case http.MethodGet:
+		return "http.MethodGet"
+	case http.MethodPut:
+		return "http.MethodPut"
+	case http.MethodPost:
+		return "http.MethodPost"
+	case http.MethodDelete:
+		return "http.MethodDelete"
+	case http.MethodPatch:
+		return "http.MethodPatch"
+	}
+
+	return fmt.Sprintf("%q", strings.ToUpper(method))
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
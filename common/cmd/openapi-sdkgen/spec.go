@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/openapi"
+)
+
+// Subset of OpenAPI 3.1 that is produced by openapi.ConvertV2ToV3
+
+type (
+	Doc struct {
+		Info struct {
+			Title   string `json:"title"`
+			Version string `json:"version"`
+		} `json:"info"`
+
+		Paths      map[string]map[string]*Operation `json:"paths"`
+		Components struct {
+			Schemas map[string]*Schema `json:"schemas"`
+		} `json:"components"`
+	}
+
+	Operation struct {
+		OperationID string       `json:"operationId"`
+		Summary     string       `json:"summary"`
+		Description string       `json:"description"`
+		Parameters  []*Parameter `json:"parameters"`
+		RequestBody *struct {
+			Required bool                  `json:"required"`
+			Content  map[string]*MediaType `json:"content"`
+		} `json:"requestBody"`
+		Responses map[string]*Response `json:"responses"`
+	}
+
+	Parameter struct {
+		Name     string  `json:"name"`
+		In       string  `json:"in"`
+		Required bool    `json:"required"`
+		Schema   *Schema `json:"schema"`
+	}
+
+	Response struct {
+		Description string                `json:"description"`
+		Content     map[string]*MediaType `json:"content"`
+	}
+
+	MediaType struct {
+		Schema   *Schema `json:"schema"`
+		Encoding map[string]struct {
+			ContentType string `json:"contentType"`
+		} `json:"encoding"`
+	}
+
+	Schema struct {
+		Ref                  string             `json:"$ref"`
+		Type                 interface{}        `json:"type"`
+		Format               string             `json:"format"`
+		Description          string             `json:"description"`
+		ContentMediaType     string             `json:"contentMediaType"`
+		Items                *Schema            `json:"items"`
+		AllOf                []*Schema          `json:"allOf"`
+		Properties           map[string]*Schema `json:"properties"`
+		AdditionalProperties interface{}        `json:"additionalProperties"`
+	}
+)
+
+func LoadDoc(fileName string) (*Doc, error) {
+
+	buf, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("reading spec: %w", err)
+	}
+
+	var version struct {
+		Swagger string `json:"swagger"`
+	}
+	if err := json.Unmarshal(buf, &version); err != nil {
+		return nil, fmt.Errorf("unmarshalling spec: %w", err)
+	}
+
+	if version.Swagger == "2.0" {
+		buf, err = openapi.ConvertV2ToV3(buf)
+		if err != nil {
+			return nil, fmt.Errorf("converting spec: %w", err)
+		}
+	}
+
+	doc := Doc{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshalling spec: %w", err)
+	}
+
+	return &doc, nil
+}
+
+// TypeName returns JSON Schema type ignoring "null" in OpenAPI 3.1 type lists
+func (s *Schema) TypeName() string {
+
+	switch t := s.Type.(type) {
+	case string:
+		return t
+
+	case []interface{}:
+		for _, item := range t {
+			if str, ok := item.(string); ok && str != "null" {
+				return str
+			}
+		}
+	}
+
+	return ""
+}
+
+func (s *Schema) IsBinary() bool {
+	return s.TypeName() == "string" && (len(s.ContentMediaType) > 0 || s.Format == "binary")
+}
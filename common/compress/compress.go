@@ -0,0 +1,94 @@
+// Package compress wraps gzip and zstd codecs that are shared by storage, gRPC and REST transports.
+package compress
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	None = "none"
+	Gzip = "gzip"
+	Zstd = "zstd"
+
+	ExtGzip = ".gz"
+	ExtZstd = ".zst"
+
+	// MaxWindow caps the zstd window that a frame could declare. Decoders allocate the window up front,
+	// so without the cap a few bytes of a frame header could force an allocation of hundreds of MB.
+	MaxWindow = 8 << 20
+)
+
+// Algorithms are listed in the order of preference
+var Algorithms = []string{Zstd, Gzip}
+
+// NewWriter compresses everything written to w. Close flushes the compressed stream but doesn't close w.
+func NewWriter(algorithm string, w io.Writer) (io.WriteCloser, error) {
+
+	switch strings.ToLower(algorithm) {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+
+	case Zstd:
+		return zstd.NewWriter(w)
+	}
+
+	return nil, fmt.Errorf("unsupported compression: %s", algorithm)
+}
+
+// NewReader decompresses r. A positive maxSize limits memory of the zstd decoder, so frames
+// that declare more decoded bytes are rejected. Close releases the decoder but doesn't close r.
+func NewReader(algorithm string, r io.Reader, maxSize int64) (io.ReadCloser, error) {
+
+	switch strings.ToLower(algorithm) {
+	case Gzip:
+		return gzip.NewReader(r)
+
+	case Zstd:
+		dec, err := zstd.NewReader(r, decoderOptions(maxSize)...)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported compression: %s", algorithm)
+}
+
+func decoderOptions(maxSize int64) []zstd.DOption {
+
+	opts := []zstd.DOption{zstd.WithDecoderMaxWindow(MaxWindow)}
+	if maxSize > 0 {
+		opts = append(opts, zstd.WithDecoderMaxMemory(uint64(maxSize)))
+	}
+
+	return opts
+}
+
+// IsSupported reports algorithms that NewWriter and NewReader handle. None is not included.
+func IsSupported(algorithm string) bool {
+	for _, a := range Algorithms {
+		if strings.EqualFold(a, algorithm) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Extension is a file name suffix of the compressed files
+func Extension(algorithm string) string {
+
+	switch strings.ToLower(algorithm) {
+	case Gzip:
+		return ExtGzip
+	case Zstd:
+		return ExtZstd
+	}
+
+	return ""
+}
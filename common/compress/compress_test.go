@@ -0,0 +1,132 @@
+package compress_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/compress"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+)
+
+// windowFrame is a zstd frame of one raw byte that declares a window of 2^windowLog bytes
+func windowFrame(windowLog byte) []byte {
+	return []byte{
+		0x28, 0xb5, 0x2f, 0xfd, // magic number
+		0x00,                  // frame header descriptor: no content size, no checksum, no dictionary
+		(windowLog - 10) << 3, // window descriptor
+		0x09, 0x00, 0x00,      // last raw block of 1 byte
+		'a',
+	}
+}
+
+// hugeWindowFrame declares 256MB, which the decoder defaults allow
+var hugeWindowFrame = windowFrame(28)
+
+func compressed(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w, err := compress.NewWriter(compress.Zstd, &buf)
+	if err != nil {
+		t.Fatalf("creating writer: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("compressing: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing writer: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestNewReaderWindowLimit(t *testing.T) {
+
+	data := bytes.Repeat([]byte("hello "), 1000)
+
+	tests := []struct {
+		name    string
+		frame   []byte
+		maxSize int64
+		data    []byte
+		err     error
+	}{
+		{name: "valid", frame: compressed(t, data), data: data},
+		{name: "valid under max size", frame: compressed(t, data), maxSize: 1 << 20, data: data},
+		{name: "huge window", frame: hugeWindowFrame, err: zstd.ErrWindowSizeExceeded},
+		{name: "huge window under max size", frame: hugeWindowFrame, maxSize: 1 << 20, err: zstd.ErrWindowSizeExceeded},
+		{name: "window over max size", frame: windowFrame(22), maxSize: 1 << 20, err: zstd.ErrWindowSizeExceeded},
+		{name: "window under max size", frame: windowFrame(22), maxSize: 8 << 20, data: []byte("a")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r, err := compress.NewReader(compress.Zstd, bytes.NewReader(tt.frame), tt.maxSize)
+			if err != nil {
+				t.Fatalf("creating reader: %v", err)
+			}
+			defer func() { _ = r.Close() }()
+
+			got, err := io.ReadAll(r)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+
+			if err != nil || !bytes.Equal(got, tt.data) {
+				t.Errorf("read %d bytes, %v", len(got), err)
+			}
+		})
+	}
+}
+
+func TestMiddlewareWindowLimit(t *testing.T) {
+
+	var readErr error
+	handler := compress.Middleware(1<<20, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(hugeWindowFrame))
+	req.Header.Set(compress.HeaderContentEncoding, compress.Zstd)
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !errors.Is(readErr, zstd.ErrWindowSizeExceeded) {
+		t.Errorf("expected %v, got %v", zstd.ErrWindowSizeExceeded, readErr)
+	}
+}
+
+func TestGrpcWindowLimit(t *testing.T) {
+
+	compress.SetGrpcMaxMessageSize(1 << 20)
+	defer compress.SetGrpcMaxMessageSize(0)
+
+	c := encoding.GetCompressor(compress.Zstd)
+
+	r, err := c.Decompress(bytes.NewReader(hugeWindowFrame))
+	if err == nil {
+		_, err = io.ReadAll(r)
+	}
+	if !errors.Is(err, zstd.ErrWindowSizeExceeded) && !errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		t.Errorf("expected window size error, got %v", err)
+	}
+
+	data := []byte("hello")
+	if r, err = c.Decompress(bytes.NewReader(compressed(t, data))); err != nil {
+		t.Fatalf("decompressing: %v", err)
+	}
+	if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("read %q, %v", got, err)
+	}
+}
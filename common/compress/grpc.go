@@ -0,0 +1,104 @@
+package compress
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+
+	// Registers gzip compressor
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+// Registering zstd next to gzip, so both servers and clients understand it.
+// Servers respond with the compressor of a request, clients choose it with grpc.UseCompressor.
+func init() {
+	encoding.RegisterCompressor(grpcCompressor)
+}
+
+var grpcCompressor = &grpcZstd{}
+
+// SetGrpcMaxMessageSize limits memory of zstd decoders of gRPC messages to maxSize bytes, zero keeps only MaxWindow.
+// The compressor is shared by the process, so it's set before servers and clients start.
+func SetGrpcMaxMessageSize(maxSize int) {
+	grpcCompressor.maxSize.Store(int64(maxSize))
+}
+
+type grpcZstd struct {
+	encoders sync.Pool
+	decoders sync.Pool
+	maxSize  atomic.Int64
+}
+
+func (c *grpcZstd) Name() string {
+	return Zstd
+}
+
+func (c *grpcZstd) Compress(w io.Writer) (io.WriteCloser, error) {
+
+	if enc, ok := c.encoders.Get().(*zstd.Encoder); ok {
+		enc.Reset(w)
+		return &pooledEncoder{Encoder: enc, pool: &c.encoders}, nil
+	}
+
+	enc, err := zstd.NewWriter(w)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pooledEncoder{Encoder: enc, pool: &c.encoders}, nil
+}
+
+func (c *grpcZstd) Decompress(r io.Reader) (io.Reader, error) {
+
+	if dec, ok := c.decoders.Get().(*zstd.Decoder); ok {
+		if err := dec.Reset(r); err != nil {
+			return nil, err
+		}
+		return &pooledDecoder{Decoder: dec, pool: &c.decoders}, nil
+	}
+
+	// Decoders are used synchronously, so goroutines of the async decoder aren't needed
+	dec, err := zstd.NewReader(r, append(decoderOptions(c.maxSize.Load()), zstd.WithDecoderConcurrency(1))...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pooledDecoder{Decoder: dec, pool: &c.decoders}, nil
+}
+
+type pooledEncoder struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+func (e *pooledEncoder) Close() error {
+	err := e.Encoder.Close()
+	e.pool.Put(e.Encoder)
+
+	return err
+}
+
+// pooledDecoder returns the decoder to the pool when the message is read up to the end
+type pooledDecoder struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+func (d *pooledDecoder) Read(p []byte) (int, error) {
+
+	if d.Decoder == nil {
+		return 0, io.EOF
+	}
+
+	n, err := d.Decoder.Read(p)
+	if err == io.EOF {
+		_ = d.Decoder.Reset(nil)
+		d.pool.Put(d.Decoder)
+		d.Decoder = nil
+	}
+
+	return n, err
+}
@@ -0,0 +1,276 @@
+package compress
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	HeaderContentEncoding = "Content-Encoding"
+	HeaderAcceptEncoding  = "Accept-Encoding"
+
+	identity = "identity"
+)
+
+// Middleware decodes request bodies with gzip or zstd Content-Encoding
+// and compresses responses with the best encoding from Accept-Encoding.
+// A positive maxSize limits memory of zstd decoders like NewReader does.
+func Middleware(maxSize int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if enc := r.Header.Get(HeaderContentEncoding); len(enc) > 0 && !strings.EqualFold(enc, identity) {
+			if !IsSupported(enc) {
+				http.Error(w, "unsupported content encoding: "+enc, http.StatusUnsupportedMediaType)
+				return
+			}
+
+			body, err := NewReader(enc, r.Body, maxSize)
+			if err != nil {
+				http.Error(w, "malformed compressed body", http.StatusBadRequest)
+				return
+			}
+
+			r.Body = &readCloser{Reader: body, closers: []io.Closer{body, r.Body}}
+			r.ContentLength = -1
+			r.Header.Del(HeaderContentEncoding)
+			r.Header.Del("Content-Length")
+		}
+
+		algorithm := Negotiate(r.Header.Get(HeaderAcceptEncoding))
+		if len(algorithm) == 0 || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := responseWriter{ResponseWriter: w, algorithm: algorithm}
+		defer func() { _ = cw.Close() }()
+
+		next.ServeHTTP(&cw, r)
+	})
+}
+
+// Negotiate picks a supported encoding from Accept-Encoding header. It returns "" if nothing fits.
+func Negotiate(acceptEncoding string) string {
+
+	accepted := make(map[string]bool)
+	wildcard := false
+
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		if name == "*" {
+			wildcard = q > 0
+			continue
+		}
+		accepted[name] = q > 0
+	}
+
+	for _, a := range Algorithms {
+		if ok, listed := accepted[a]; ok || (!listed && wildcard) {
+			return a
+		}
+	}
+
+	return ""
+}
+
+// responseWriter decides on compression when the header is written,
+// so handlers that set their own Content-Encoding or write compressed media are not touched.
+type responseWriter struct {
+	http.ResponseWriter
+	algorithm string
+
+	wroteHeader bool
+	writer      io.WriteCloser
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.Header()
+
+	if code >= http.StatusOK && code != http.StatusNoContent && code != http.StatusNotModified &&
+		len(h.Get(HeaderContentEncoding)) == 0 && compressible(h.Get("Content-Type")) {
+
+		if zw, err := NewWriter(w.algorithm, w.ResponseWriter); err == nil {
+			w.writer = zw
+			h.Set(HeaderContentEncoding, w.algorithm)
+			h.Del("Content-Length")
+		}
+	}
+	h.Add("Vary", HeaderAcceptEncoding)
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+
+	if !w.wroteHeader {
+		if len(w.Header().Get("Content-Type")) == 0 {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.writer != nil {
+		return w.writer.Write(p)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush is used by streaming responses of the gateway
+func (w *responseWriter) Flush() {
+
+	if f, ok := w.writer.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+
+	return nil, nil, errors.New("hijacking is not supported")
+}
+
+func (w *responseWriter) Close() error {
+	if w.writer == nil {
+		return nil
+	}
+
+	return w.writer.Close()
+}
+
+// compressible skips media that is compressed by its format
+func compressible(contentType string) bool {
+
+	contentType = strings.ToLower(contentType)
+
+	switch {
+	case strings.HasPrefix(contentType, "image/svg+xml"):
+		return true
+	case strings.HasPrefix(contentType, "image/"),
+		strings.HasPrefix(contentType, "video/"),
+		strings.HasPrefix(contentType, "audio/"),
+		strings.HasPrefix(contentType, "application/zip"),
+		strings.HasPrefix(contentType, "application/gzip"),
+		strings.HasPrefix(contentType, "application/zstd"):
+		return false
+	}
+
+	return true
+}
+
+// Transport compresses request bodies with Encoding and decompresses gzip and zstd responses.
+// Empty Encoding or "none" sends bodies as is.
+type Transport struct {
+	Base     http.RoundTripper
+	Encoding string
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	req = req.Clone(req.Context())
+
+	if IsSupported(t.Encoding) && req.Body != nil && req.Body != http.NoBody {
+		req.Body = compressBody(t.Encoding, req.Body)
+		req.GetBody = nil
+		req.ContentLength = -1
+		req.Header.Del("Content-Length")
+		req.Header.Set(HeaderContentEncoding, t.Encoding)
+	}
+
+	if len(req.Header.Get(HeaderAcceptEncoding)) == 0 {
+		req.Header.Set(HeaderAcceptEncoding, strings.Join(Algorithms, ", "))
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if enc := resp.Header.Get(HeaderContentEncoding); IsSupported(enc) {
+		body, err := NewReader(enc, resp.Body, 0)
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+
+		resp.Body = &readCloser{Reader: body, closers: []io.Closer{body, resp.Body}}
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+		resp.Header.Del(HeaderContentEncoding)
+		resp.Header.Del("Content-Length")
+	}
+
+	return resp, nil
+}
+
+// compressBody streams compressed body, so it's not buffered in memory
+func compressBody(algorithm string, body io.ReadCloser) io.ReadCloser {
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		defer func() { _ = body.Close() }()
+
+		zw, err := NewWriter(algorithm, pw)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		if _, err := io.Copy(zw, body); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		pw.CloseWithError(zw.Close())
+	}()
+
+	return pr
+}
+
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (rc *readCloser) Close() error {
+	var res error
+	for _, c := range rc.closers {
+		if err := c.Close(); err != nil && res == nil {
+			res = err
+		}
+	}
+
+	return res
+}
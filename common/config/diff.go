@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Diff returns paths of leaf fields that differ in a and b. Both should be structs or pointers to the same struct type.
+// Paths are built from yaml tags, e.g. "grpc.gateway-timeout".
+func Diff(a, b interface{}) []string {
+	var res []string
+	diff(reflect.Indirect(reflect.ValueOf(a)), reflect.Indirect(reflect.ValueOf(b)), "", &res)
+	return res
+}
+
+func diff(a, b reflect.Value, prefix string, res *[]string) {
+
+	if a.Kind() != reflect.Struct || isLeafStruct(a.Type()) {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*res = append(*res, prefix)
+		}
+		return
+	}
+
+	for i := 0; i < a.NumField(); i++ {
+		f := a.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		diff(a.Field(i), b.Field(i), joinPath(prefix, FieldName(f)), res)
+	}
+}
+
+// CopyField sets the field at path in dst to its value in src.
+func CopyField(dst, src interface{}, path string) error {
+
+	dstField, err := fieldByPath(reflect.Indirect(reflect.ValueOf(dst)), path)
+	if err != nil {
+		return err
+	}
+
+	srcField, err := fieldByPath(reflect.Indirect(reflect.ValueOf(src)), path)
+	if err != nil {
+		return err
+	}
+
+	if !dstField.CanSet() {
+		return fmt.Errorf("field [%s] can't be set", path)
+	}
+
+	dstField.Set(srcField)
+
+	return nil
+}
+
+func fieldByPath(v reflect.Value, path string) (reflect.Value, error) {
+
+	if len(path) == 0 {
+		return v, nil
+	}
+
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("field [%s] is not found", path)
+		}
+
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.IsExported() && FieldName(f) == name {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return reflect.Value{}, fmt.Errorf("field [%s] is not found", path)
+		}
+	}
+
+	return v, nil
+}
+
+// FieldName returns a yaml name of the field
+func FieldName(f reflect.StructField) string {
+
+	if tag, ok := f.Tag.Lookup("yaml"); ok {
+		if name := strings.Split(tag, ",")[0]; len(name) > 0 && name != "-" {
+			return name
+		}
+	}
+
+	return strings.ToLower(f.Name)
+}
+
+// isLeafStruct reports structs that are compared as a whole, e.g. time.Time
+func isLeafStruct(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func joinPath(prefix, name string) string {
+	if len(prefix) == 0 {
+		return name
+	}
+
+	return prefix + "." + name
+}
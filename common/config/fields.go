@@ -0,0 +1,188 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type leaf struct {
+	path   string
+	envKey string
+	field  reflect.StructField
+	value  reflect.Value
+}
+
+// walkLeaves calls fn for every leaf field of a struct. Path is built from yaml tags and env key follows envconfig rules.
+func walkLeaves(v reflect.Value, path, envKey string, fn func(leaf) error) error {
+
+	v = reflect.Indirect(v)
+
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		fieldPath := joinPath(path, FieldName(f))
+		fieldKey := joinEnvKey(envKey, EnvName(f))
+
+		// Embedded structs are flattened like envconfig does
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fieldPath, fieldKey = path, envKey
+		}
+
+		fv := v.Field(i)
+
+		if f.Type.Kind() == reflect.Struct && !isLeafStruct(f.Type) {
+			if err := walkLeaves(fv, fieldPath, fieldKey, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := fn(leaf{path: fieldPath, envKey: fieldKey, field: f, value: fv}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var (
+	gatherRegexp  = regexp.MustCompile("([^A-Z]+|[A-Z]+[^A-Z]+|[A-Z]+)")
+	acronymRegexp = regexp.MustCompile("([A-Z]+)([A-Z][^A-Z]+)")
+)
+
+// EnvName returns the env var name part of the field the way envconfig builds it
+func EnvName(f reflect.StructField) string {
+
+	if name, ok := f.Tag.Lookup("envconfig"); ok && len(name) > 0 {
+		return strings.ToUpper(name)
+	}
+
+	if f.Tag.Get("split_words") != "true" {
+		return strings.ToUpper(f.Name)
+	}
+
+	words := gatherRegexp.FindAllStringSubmatch(f.Name, -1)
+	if len(words) == 0 {
+		return strings.ToUpper(f.Name)
+	}
+
+	var name []string
+	for _, words := range words {
+		if m := acronymRegexp.FindStringSubmatch(words[0]); len(m) == 3 {
+			name = append(name, m[1], m[2])
+		} else {
+			name = append(name, words[0])
+		}
+	}
+
+	return strings.ToUpper(strings.Join(name, "_"))
+}
+
+func joinEnvKey(prefix, name string) string {
+	if len(prefix) == 0 {
+		return name
+	}
+
+	return prefix + "_" + name
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setValue parses a string value the way envconfig does: slices are comma separated
+func setValue(v reflect.Value, raw string) error {
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+
+	case reflect.String:
+		v.SetString(raw)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+
+	case reflect.Slice:
+		items := strings.Split(raw, ",")
+		if len(strings.TrimSpace(raw)) == 0 {
+			items = nil
+		}
+
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		v.Set(slice)
+
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+
+	return nil
+}
+
+// formatValue is the reverse of setValue
+func formatValue(v reflect.Value) string {
+
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	if v.Kind() == reflect.Slice {
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, formatValue(v.Index(i)))
+		}
+		return strings.Join(items, ",")
+	}
+
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,425 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/secrets"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultEnvPrefix = "APP"
+	DefaultFileEnv   = "CONFIG_FILE"
+
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+
+	DefaultSecretsRefresh = 5 * time.Minute
+	secretsTimeout        = 30 * time.Second
+
+	flagConfig         = "config"
+	flagPrintConfig    = "print-config"
+	flagSecretsRefresh = "secrets-refresh"
+)
+
+type (
+	// Loader merges config layers. Later layers override earlier ones:
+	//  1. `default` struct tags
+	//  2. config files in the order they are given: FileEnv (comma separated) and then -config flags
+	//  3. env vars named like envconfig does: APP_GRPC_GATEWAY_TIMEOUT
+	//  4. command line flags named by yaml paths: -grpc.gateway-timeout=10s
+	//
+	// String values that are secret references (file://, env://, vault://) are resolved after all layers
+	// are merged, so a reference could come from any layer.
+	Loader struct {
+		EnvPrefix string
+		FileEnv   string
+		Args      []string
+		Secrets   *secrets.Resolver
+	}
+
+	// Provenance maps yaml path of a field to the source of its value, e.g. "env:APP_LOG_LEVEL"
+	Provenance map[string]string
+
+	Result struct {
+		Files      []string
+		Provenance Provenance
+
+		// PrintConfig is set with -print-config flag. The caller is expected to print config and exit.
+		PrintConfig bool
+
+		// Secrets maps yaml path of a field to the secret reference its value is resolved from
+		Secrets map[string]string
+
+		// SecretsRefresh is how often secrets should be resolved again. It's 0 if config has no references.
+		SecretsRefresh time.Duration
+
+		// Args are command line arguments left after flags
+		Args []string
+	}
+)
+
+func NewLoader(args []string) *Loader {
+	return &Loader{
+		EnvPrefix: DefaultEnvPrefix,
+		FileEnv:   DefaultFileEnv,
+		Args:      args,
+		Secrets:   secrets.NewResolver(),
+	}
+}
+
+// Load fills cfg that should be a pointer to a struct and validates it with `validate` tags.
+func (l *Loader) Load(cfg interface{}) (*Result, error) {
+
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config should be a pointer to a struct, got %T", cfg)
+	}
+
+	res := Result{
+		Provenance: make(Provenance),
+		Secrets:    make(map[string]string),
+	}
+
+	if len(l.FileEnv) > 0 {
+		for _, f := range strings.Split(os.Getenv(l.FileEnv), ",") {
+			if f = strings.TrimSpace(f); len(f) > 0 {
+				res.Files = append(res.Files, f)
+			}
+		}
+	}
+
+	flags, err := l.parseFlags(v, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyDefaults(v, res.Provenance); err != nil {
+		return nil, fmt.Errorf("applying defaults: %w", err)
+	}
+
+	for _, f := range res.Files {
+		if err := applyFile(v, f, res.Provenance); err != nil {
+			return nil, fmt.Errorf("loading config file [%s]: %w", f, err)
+		}
+	}
+
+	if err := l.applyEnv(v, res.Provenance); err != nil {
+		return nil, fmt.Errorf("loading env: %w", err)
+	}
+
+	for _, fl := range flags {
+		if err := setValue(fl.value, fl.raw); err != nil {
+			return nil, fmt.Errorf("flag -%s: %w", fl.path, err)
+		}
+		res.Provenance[fl.path] = SourceFlag + ":-" + fl.path
+	}
+
+	if l.Secrets != nil {
+		if err := l.resolveSecrets(v, &res); err != nil {
+			return nil, fmt.Errorf("resolving secrets: %w", err)
+		}
+	}
+
+	if len(res.Secrets) == 0 {
+		res.SecretsRefresh = 0
+	}
+
+	if err := validator.New().Struct(cfg); err != nil {
+		return nil, fmt.Errorf("validating config: %w", err)
+	}
+
+	return &res, nil
+}
+
+// resolveSecrets replaces secret references in string fields and in items of string slices.
+// Items of secret slices could also be "<prefix>:<reference>", only the reference is replaced then.
+func (l *Loader) resolveSecrets(v reflect.Value, res *Result) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretsTimeout)
+	defer cancel()
+
+	return walkLeaves(v, "", "", func(lf leaf) error {
+
+		switch {
+		case lf.value.Kind() == reflect.String:
+			ref, err := l.resolveSecret(ctx, lf.value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", lf.path, err)
+			}
+			if len(ref) > 0 {
+				res.Secrets[lf.path] = ref
+			}
+
+		case lf.value.Kind() == reflect.Slice && lf.value.Type().Elem().Kind() == reflect.String:
+			refs := make([]string, lf.value.Len())
+			resolved := false
+
+			for i := 0; i < lf.value.Len(); i++ {
+				ref, err := l.resolveSecret(ctx, lf.value.Index(i))
+				if err == nil && len(ref) == 0 && IsSecret(lf.field, lf.path) {
+					ref, err = l.resolvePrefixedSecret(ctx, lf.value.Index(i))
+				}
+				if err != nil {
+					return fmt.Errorf("%s[%d]: %w", lf.path, i, err)
+				}
+
+				// Plain items are not shown next to references
+				refs[i] = redacted
+				if len(ref) > 0 {
+					refs[i] = ref
+					resolved = true
+				}
+			}
+
+			if resolved {
+				res.Secrets[lf.path] = strings.Join(refs, ",")
+			}
+		}
+
+		return nil
+	})
+}
+
+// resolveSecret sets the secret to a string value and returns the reference, or "" if it's a plain value
+func (l *Loader) resolveSecret(ctx context.Context, v reflect.Value) (string, error) {
+
+	ref := v.String()
+	if !l.Secrets.IsReference(ref) {
+		return "", nil
+	}
+
+	secret, err := l.Secrets.Resolve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	v.SetString(secret)
+
+	return ref, nil
+}
+
+// resolvePrefixedSecret resolves items of secret lists like "<id>:vault://secret/data/app#key" to "<id>:<secret>"
+// and returns the reference, or "" if the item has no reference after the prefix
+func (l *Loader) resolvePrefixedSecret(ctx context.Context, v reflect.Value) (string, error) {
+
+	item := v.String()
+
+	prefix, ref, ok := strings.Cut(item, ":")
+	if !ok || !l.Secrets.IsReference(ref) {
+		return "", nil
+	}
+
+	secret, err := l.Secrets.Resolve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	v.SetString(prefix + ":" + secret)
+
+	return item, nil
+}
+
+// Defaults fills cfg with values of `default` tags only. It's for configs that are built in code, e.g. in tests.
+func Defaults(cfg interface{}) error {
+
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config should be a pointer to a struct, got %T", cfg)
+	}
+
+	return applyDefaults(v, make(Provenance))
+}
+
+func applyDefaults(v reflect.Value, prov Provenance) error {
+	return walkLeaves(v, "", "", func(l leaf) error {
+		def, ok := l.field.Tag.Lookup("default")
+		if !ok {
+			return nil
+		}
+
+		if err := setValue(l.value, def); err != nil {
+			return fmt.Errorf("%s: %w", l.path, err)
+		}
+		prov[l.path] = SourceDefault
+
+		return nil
+	})
+}
+
+// applyFile overlays the file on top of cfg. JSON files use json tags, other files are parsed as YAML.
+func applyFile(v reflect.Value, fileName string, prov Provenance) error {
+
+	buf, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+
+	isJson := strings.EqualFold(filepath.Ext(fileName), ".json")
+
+	var doc map[string]interface{}
+	if isJson {
+		err = json.Unmarshal(buf, &doc)
+	} else {
+		err = yaml.Unmarshal(buf, &doc)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing file: %w", err)
+	}
+
+	if isJson {
+		err = json.Unmarshal(buf, v.Interface())
+	} else {
+		err = yaml.Unmarshal(buf, v.Interface())
+	}
+	if err != nil {
+		return fmt.Errorf("unmarshalling file: %w", err)
+	}
+
+	markPresent(v.Elem().Type(), doc, "", isJson, func(path string) {
+		prov[path] = SourceFile + ":" + fileName
+	})
+
+	return nil
+}
+
+// markPresent reports leaf fields that are set in a parsed document
+func markPresent(t reflect.Type, doc map[string]interface{}, path string, isJson bool, mark func(string)) {
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		fieldPath := joinPath(path, FieldName(f))
+
+		key := FieldName(f)
+		if isJson {
+			key = jsonName(f)
+		}
+
+		val, ok := doc[key]
+		if !ok {
+			continue
+		}
+
+		if f.Type.Kind() == reflect.Struct && !isLeafStruct(f.Type) {
+			if sub, ok := val.(map[string]interface{}); ok {
+				markPresent(f.Type, sub, fieldPath, isJson, mark)
+			}
+			continue
+		}
+
+		mark(fieldPath)
+	}
+}
+
+func (l *Loader) applyEnv(v reflect.Value, prov Provenance) error {
+	return walkLeaves(v, "", l.EnvPrefix, func(lf leaf) error {
+		raw, ok := os.LookupEnv(lf.envKey)
+		if !ok {
+			return nil
+		}
+
+		if err := setValue(lf.value, raw); err != nil {
+			return fmt.Errorf("%s: %w", lf.envKey, err)
+		}
+		prov[lf.path] = SourceEnv + ":" + lf.envKey
+
+		return nil
+	})
+}
+
+type flagValue struct {
+	path  string
+	raw   string
+	value reflect.Value
+}
+
+// parseFlags registers a flag for every leaf field. Values are collected and applied after other layers.
+func (l *Loader) parseFlags(v reflect.Value, res *Result) ([]*flagValue, error) {
+
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+
+	fs.Func(flagConfig, "config file, YAML or JSON. Could be repeated", func(s string) error {
+		res.Files = append(res.Files, s)
+		return nil
+	})
+	fs.BoolVar(&res.PrintConfig, flagPrintConfig, false, "print effective config with value sources and exit")
+	fs.DurationVar(&res.SecretsRefresh, flagSecretsRefresh, DefaultSecretsRefresh, "how often secret references are resolved again, 0 disables refresh")
+
+	var flags []*flagValue
+
+	err := walkLeaves(v, "", l.EnvPrefix, func(lf leaf) error {
+		usage := fmt.Sprintf("%s (env %s)", lf.field.Type, lf.envKey)
+		fs.Var(&leafFlag{lf: lf, collect: func(fv *flagValue) { flags = append(flags, fv) }}, lf.path, usage)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.Parse(l.Args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("parsing flags: %w", err)
+	}
+
+	res.Args = fs.Args()
+
+	return flags, nil
+}
+
+type leafFlag struct {
+	lf      leaf
+	raw     string
+	collect func(*flagValue)
+}
+
+func (f *leafFlag) String() string {
+	return f.raw
+}
+
+func (f *leafFlag) Set(s string) error {
+
+	// Checking the value right away, so flag package reports a bad one with usage
+	probe := reflect.New(f.lf.value.Type()).Elem()
+	if err := setValue(probe, s); err != nil {
+		return err
+	}
+
+	f.raw = s
+	f.collect(&flagValue{path: f.lf.path, raw: s, value: f.lf.value})
+
+	return nil
+}
+
+func (f *leafFlag) IsBoolFlag() bool {
+	return f.lf.value.Kind() == reflect.Bool
+}
+
+func jsonName(f reflect.StructField) string {
+
+	if tag, ok := f.Tag.Lookup("json"); ok {
+		if name := strings.Split(tag, ",")[0]; len(name) > 0 && name != "-" {
+			return name
+		}
+	}
+
+	return f.Name
+}
@@ -0,0 +1,64 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/secrets"
+)
+
+type staticProvider map[string]string
+
+func (p staticProvider) Resolve(_ context.Context, ref string) (string, error) {
+	return p[ref], nil
+}
+
+func TestLoadPrefixedSecrets(t *testing.T) {
+
+	var cfg struct {
+		Keys  []string `yaml:"keys" secret:"true"`
+		Hosts []string `yaml:"hosts"`
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	doc := `
+keys:
+  - "2024-10:vault://secret/data/storage#master-key"
+  - "vault://secret/data/storage#old-key"
+  - "2023-01:plain"
+hosts:
+  - "api:vault://secret/data/storage#master-key"
+`
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := config.NewLoader([]string{"-config", path})
+	loader.Secrets.Register(secrets.SchemeVault, staticProvider{
+		"secret/data/storage#master-key": "bWFzdGVy",
+		"secret/data/storage#old-key":    "2023-06:b2xk",
+	})
+
+	res, err := loader.Load(&cfg)
+	if err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+
+	expected := []string{"2024-10:bWFzdGVy", "2023-06:b2xk", "2023-01:plain"}
+	if strings.Join(cfg.Keys, ",") != strings.Join(expected, ",") {
+		t.Errorf("keys are %v, expected %v", cfg.Keys, expected)
+	}
+
+	// Only secret lists have prefixed references
+	if cfg.Hosts[0] != "api:vault://secret/data/storage#master-key" {
+		t.Errorf("hosts are %v", cfg.Hosts)
+	}
+
+	if ref := res.Secrets["keys"]; !strings.HasPrefix(ref, "2024-10:vault://secret/data/storage#master-key,") {
+		t.Errorf("keys are resolved from %q", ref)
+	}
+}
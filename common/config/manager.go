@@ -0,0 +1,284 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/hashicorp/go-multierror"
+	logger "github.com/sirupsen/logrus"
+)
+
+const reloadDebounce = 200 * time.Millisecond
+
+type (
+	Validator interface {
+		Validate() error
+	}
+
+	// LoadFunc builds config from scratch. T is expected to be a pointer to a struct.
+	LoadFunc[T Validator] func() (T, error)
+
+	// ApplyFunc applies config to a running component. It should be cheap and is expected not to fail
+	// for a valid config. If it fails the whole reload is rolled back.
+	ApplyFunc[T Validator] func(cfg T) error
+
+	// ReportFunc observes reloads, e.g. for an audit trail. Report is empty if err is set.
+	ReportFunc func(report Report, err error)
+
+	// Report describes the result of a reload. Fields are named with yaml tags, e.g. "log.level".
+	Report struct {
+		Applied []string
+		Ignored []string
+	}
+
+	// Manager keeps the current config and reloads it when config files change or SIGHUP is received.
+	// Only the fields under reloadable paths are applied. Other changes are reported and ignored
+	// until restart, so Current() always describes the config that is effectively used.
+	Manager[T Validator] struct {
+		configFiles []string
+		load        LoadFunc[T]
+		reloadable  []string
+		refresh     time.Duration
+		log         *logger.Logger
+
+		current atomic.Value
+
+		mu        sync.Mutex
+		appliers  []ApplyFunc[T]
+		reporters []ReportFunc
+	}
+)
+
+// NewManager starts with an initial config that is already loaded. load is used for reloads, reloads are logged to log.
+func NewManager[T Validator](cfg T, configFiles []string, load LoadFunc[T], log *logger.Logger, reloadable ...string) (*Manager[T], error) {
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validating config: %w", err)
+	}
+
+	m := Manager[T]{
+		configFiles: configFiles,
+		load:        load,
+		reloadable:  reloadable,
+		log:         log,
+	}
+	m.current.Store(cfg)
+
+	return &m, nil
+}
+
+func (m *Manager[T]) Current() T {
+	return m.current.Load().(T)
+}
+
+// Fields lists the current config with secrets redacted
+func (m *Manager[T]) Fields() ([]Field, error) {
+
+	fields, err := Fields(m.Current())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range fields {
+		fields[i].Reloadable = m.isReloadable(fields[i].Path)
+	}
+
+	return fields, nil
+}
+
+// RefreshEvery makes Run reload config periodically, so rotated secrets are picked up. It should be called before Run.
+func (m *Manager[T]) RefreshEvery(interval time.Duration) {
+	m.refresh = interval
+}
+
+// OnReload registers a component that follows config changes. It's called in registration order.
+func (m *Manager[T]) OnReload(apply ApplyFunc[T]) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.appliers = append(m.appliers, apply)
+}
+
+// OnReport registers an observer of reloads. It's called after every reload, including failed and empty ones.
+func (m *Manager[T]) OnReport(report ReportFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reporters = append(m.reporters, report)
+}
+
+// Reload loads and validates config and applies its reloadable part.
+func (m *Manager[T]) Reload() (Report, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report, err := m.reload()
+
+	for _, r := range m.reporters {
+		r(report, err)
+	}
+
+	return report, err
+}
+
+func (m *Manager[T]) reload() (Report, error) {
+
+	next, err := m.load()
+	if err != nil {
+		return Report{}, fmt.Errorf("loading config: %w", err)
+	}
+
+	if err := next.Validate(); err != nil {
+		return Report{}, fmt.Errorf("validating config: %w", err)
+	}
+
+	prev := m.Current()
+
+	report := Report{}
+
+	for _, path := range Diff(prev, next) {
+		if !m.isReloadable(path) {
+			report.Ignored = append(report.Ignored, path)
+			continue
+		}
+
+		report.Applied = append(report.Applied, path)
+	}
+
+	if len(report.Applied) == 0 {
+		return report, nil
+	}
+
+	// Non-reloadable values are kept from the running config
+	for _, path := range report.Ignored {
+		if err := CopyField(next, prev, path); err != nil {
+			return Report{}, fmt.Errorf("keeping [%s]: %w", path, err)
+		}
+	}
+
+	for i, apply := range m.appliers {
+		if errApply := apply(next); errApply != nil {
+
+			for j := i - 1; j >= 0; j-- {
+				if errRollback := m.appliers[j](prev); errRollback != nil {
+					errApply = multierror.Append(errApply, fmt.Errorf("rolling back: %w", errRollback))
+				}
+			}
+
+			return Report{}, fmt.Errorf("applying config: %w", errApply)
+		}
+	}
+
+	m.current.Store(next)
+
+	return report, nil
+}
+
+func (m *Manager[T]) isReloadable(path string) bool {
+	for _, r := range m.reloadable {
+		if path == r || (len(path) > len(r) && path[:len(r)] == r && path[len(r)] == '.') {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Run reloads config on SIGHUP, on config file changes and on refresh ticks until ctx is done.
+func (m *Manager[T]) Run(ctx context.Context) error {
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	var fileEvents <-chan fsnotify.Event
+	var watchErrors <-chan error
+
+	configPaths := make(map[string]struct{}, len(m.configFiles))
+
+	if len(m.configFiles) > 0 {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("creating file watcher: %w", err)
+		}
+		defer func() { _ = watcher.Close() }()
+
+		for _, f := range m.configFiles {
+			configPaths[filepath.Clean(f)] = struct{}{}
+
+			// Editors and config management tools replace the file, so the directory is watched
+			if err := watcher.Add(filepath.Dir(f)); err != nil {
+				return fmt.Errorf("watching config dir: %w", err)
+			}
+		}
+
+		fileEvents = watcher.Events
+		watchErrors = watcher.Errors
+	}
+
+	var refreshTicks <-chan time.Time
+	if m.refresh > 0 {
+		ticker := time.NewTicker(m.refresh)
+		defer ticker.Stop()
+
+		refreshTicks = ticker.C
+	}
+
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-sigChan:
+			m.log.Info("SIGHUP received, reloading config")
+			m.reloadAndLog()
+
+		case ev := <-fileEvents:
+			if _, ok := configPaths[filepath.Clean(ev.Name)]; !ok || !(ev.Has(fsnotify.Write) || ev.Has(fsnotify.Create)) {
+				continue
+			}
+			debounce.Reset(reloadDebounce)
+
+		case <-debounce.C:
+			m.log.Infof("config files %v changed, reloading config", m.configFiles)
+			m.reloadAndLog()
+
+		case <-refreshTicks:
+			m.log.Debug("refreshing config")
+			m.reloadAndLog()
+
+		case err := <-watchErrors:
+			m.log.Errorf("watching config file: %v", err)
+		}
+	}
+}
+
+func (m *Manager[T]) reloadAndLog() {
+
+	report, err := m.Reload()
+	if err != nil {
+		m.log.Errorf("config is not reloaded: %v", err)
+		return
+	}
+
+	if len(report.Ignored) > 0 {
+		m.log.Warnf("config changes require restart and were not applied: %v", report.Ignored)
+	}
+
+	if len(report.Applied) > 0 {
+		m.log.Infof("config changes applied: %v", report.Applied)
+	}
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"text/tabwriter"
+)
+
+const redacted = "******"
+
+var secretNameRegexp = regexp.MustCompile(`(?i)(password|secret|token|api[-_]?key|private[-_]?key)`)
+
+// Field is a leaf value of config
+type Field struct {
+	Path       string
+	Value      string
+	Reloadable bool
+}
+
+// IsSecret reports fields that are tagged with `secret:"true"` or named like a credential
+func IsSecret(f reflect.StructField, path string) bool {
+	return f.Tag.Get("secret") == "true" || secretNameRegexp.MatchString(path)
+}
+
+// Print writes effective config with the source of every value.
+// Secrets are redacted and values resolved from secret references are shown as references.
+func Print(w io.Writer, cfg interface{}, res *Result) error {
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	err := walkLeaves(reflect.ValueOf(cfg), "", "", func(l leaf) error {
+
+		value := formatValue(l.value)
+		if IsSecret(l.field, l.path) && !l.value.IsZero() {
+			value = redacted
+		}
+		if ref, ok := res.Secrets[l.path]; ok {
+			value = ref
+		}
+
+		source, ok := res.Provenance[l.path]
+		if !ok {
+			source = "-"
+		}
+
+		_, err := fmt.Fprintf(tw, "%s\t%s\t%s\n", l.path, value, source)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("printing config: %w", err)
+	}
+
+	return tw.Flush()
+}
+
+// Fields lists leaf values of config by yaml path. Secrets are redacted.
+func Fields(cfg interface{}) ([]Field, error) {
+
+	var fields []Field
+
+	err := walkLeaves(reflect.ValueOf(cfg), "", "", func(l leaf) error {
+
+		value := formatValue(l.value)
+		if IsSecret(l.field, l.path) && !l.value.IsZero() {
+			value = redacted
+		}
+
+		fields = append(fields, Field{Path: l.path, Value: value})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing config fields: %w", err)
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,118 @@
+// Package debugdump logs headers and bodies of REST requests and gRPC calls for debugging.
+// Dumps are written to request loggers at info level, so they don't depend on the log level.
+//
+// Config is applied at runtime with Apply, e.g. on a config reload. Calls that the gateway makes
+// to the gRPC server are dumped by the REST middleware only.
+package debugdump
+
+import (
+	"math/rand"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+const redacted = "[redacted]"
+
+type (
+	Config struct {
+		Enabled bool `json:"enabled" yaml:"enabled" default:"false"`
+
+		// Routes are path.Match patterns of REST paths and gRPC methods that are dumped, e.g. /v2/* or
+		// /grpc_rest.v2.GrpcRestMultipartService/*. All routes are dumped if it's empty.
+		Routes []string `json:"routes" yaml:"routes"`
+
+		// SampleRate is a share of matching requests that are dumped, from 0 to 1
+		SampleRate float64 `json:"sampleRate" yaml:"sample-rate" split_words:"true" default:"1" validate:"gte=0,lte=1"`
+
+		// MaxBodySize is bytes of a body that are logged. Longer bodies are cut.
+		MaxBodySize int `json:"maxBodySize" yaml:"max-body-size" split_words:"true" default:"4096" validate:"gte=0"`
+
+		// ElideAttachments logs sizes instead of file parts, bytes fields and binary responses
+		ElideAttachments bool `json:"elideAttachments" yaml:"elide-attachments" split_words:"true" default:"true"`
+
+		// RedactHeaders are path.Match patterns of header names which values aren't logged. Names are matched in lowercase.
+		RedactHeaders []string `json:"redactHeaders" yaml:"redact-headers" split_words:"true" default:"authorization,proxy-authorization,cookie,set-cookie,x-api-key"`
+	}
+
+	// Dumper dumps requests with the current config
+	Dumper struct {
+		config atomic.Pointer[Config]
+		log    *logger.Logger
+	}
+)
+
+// New creates a dumper that logs to request loggers of contexts or to log
+func New(config Config, log *logger.Logger) *Dumper {
+	d := Dumper{log: log}
+	d.Apply(config)
+	return &d
+}
+
+// Apply replaces the config. Requests that are being dumped keep the previous one.
+func (d *Dumper) Apply(config Config) {
+	config.Routes = append([]string(nil), config.Routes...)
+	config.RedactHeaders = append([]string(nil), config.RedactHeaders...)
+	d.config.Store(&config)
+}
+
+// sample returns the config if a request of route should be dumped
+func (d *Dumper) sample(route string) (*Config, bool) {
+
+	cfg := d.config.Load()
+	if !cfg.Enabled || !cfg.matches(route) {
+		return nil, false
+	}
+
+	if cfg.SampleRate < 1 && rand.Float64() >= cfg.SampleRate {
+		return nil, false
+	}
+
+	return cfg, true
+}
+
+func (c *Config) matches(route string) bool {
+
+	if len(c.Routes) == 0 {
+		return true
+	}
+
+	for _, pattern := range c.Routes {
+		if ok, _ := path.Match(pattern, route); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redact copies headers with lowercase names and values of redacted headers replaced
+func (c *Config) redact(header map[string][]string) map[string][]string {
+
+	res := make(map[string][]string, len(header))
+
+	for name, values := range header {
+		name = strings.ToLower(name)
+
+		if c.isRedacted(name) {
+			res[name] = []string{redacted}
+			continue
+		}
+
+		res[name] = values
+	}
+
+	return res
+}
+
+func (c *Config) isRedacted(name string) bool {
+	for _, pattern := range c.RedactHeaders {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,135 @@
+package debugdump
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/gateway"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+
+	logger "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// UnaryInterceptor dumps unary calls except the ones made by the gateway
+func (d *Dumper) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		if gateway.IsGatewayCall(ctx) {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		cfg, ok := d.sample(info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		fields := logger.Fields{
+			"transport": "grpc",
+			"method":    info.FullMethod,
+			"code":      status.Code(err).String(),
+			"duration":  time.Since(start).String(),
+			"header":    cfg.redact(md),
+			"request":   cfg.formatMessage(req),
+		}
+		if err != nil {
+			fields["error"] = status.Convert(err).Message()
+		} else {
+			fields["response"] = cfg.formatMessage(resp)
+		}
+
+		logging.FromContext(ctx, d.log).WithFields(fields).Info("debug dump")
+
+		return resp, err
+	}
+}
+
+// formatMessage returns a message as JSON. Bytes fields are cleared and counted if attachments are elided.
+func (c *Config) formatMessage(msg interface{}) string {
+
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return ""
+	}
+
+	elided := 0
+	if c.ElideAttachments {
+		m = proto.Clone(m)
+		elided = elideBytes(m.ProtoReflect())
+	}
+
+	buf, err := protojson.Marshal(m)
+	if err != nil {
+		return fmt.Sprintf("[marshalling: %v]", err)
+	}
+
+	res := cut(string(buf[:min(len(buf), c.MaxBodySize)]), int64(len(buf)))
+	if elided > 0 {
+		res = fmt.Sprintf("%s [%d bytes elided]", res, elided)
+	}
+
+	return res
+}
+
+// elideBytes clears bytes fields of m and its nested messages. It returns the number of cleared bytes.
+func elideBytes(m protoreflect.Message) int {
+
+	elided := 0
+	var cleared []protoreflect.FieldDescriptor
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+
+		case fd.IsMap():
+			if isMessage(fd.MapValue()) {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					elided += elideBytes(mv.Message())
+					return true
+				})
+			}
+
+		case fd.Kind() == protoreflect.BytesKind:
+			if fd.IsList() {
+				for i := 0; i < v.List().Len(); i++ {
+					elided += len(v.List().Get(i).Bytes())
+				}
+			} else {
+				elided += len(v.Bytes())
+			}
+			cleared = append(cleared, fd)
+
+		case isMessage(fd):
+			if fd.IsList() {
+				for i := 0; i < v.List().Len(); i++ {
+					elided += elideBytes(v.List().Get(i).Message())
+				}
+			} else {
+				elided += elideBytes(v.Message())
+			}
+		}
+
+		return true
+	})
+
+	// Fields aren't cleared while ranging over them
+	for _, fd := range cleared {
+		m.Clear(fd)
+	}
+
+	return elided
+}
+
+func isMessage(fd protoreflect.FieldDescriptor) bool {
+	return fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind
+}
@@ -0,0 +1,256 @@
+package debugdump
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+type (
+	// capture keeps up to max bytes of a body and counts the rest
+	capture struct {
+		buf  bytes.Buffer
+		max  int
+		size int64
+	}
+
+	// multipartCapture parses a multipart body while it's read. File parts are counted, other parts are kept.
+	multipartCapture struct {
+		pw   *io.PipeWriter
+		done chan struct{}
+		size int64
+
+		max   int
+		parts []string
+	}
+
+	teeBody struct {
+		io.ReadCloser
+		w io.Writer
+	}
+
+	responseCapture struct {
+		http.ResponseWriter
+		c      *capture
+		status int
+	}
+)
+
+// Middleware dumps requests that reach next and their responses
+func (d *Dumper) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		cfg, ok := d.sample(req.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		start := time.Now()
+
+		var (
+			reqBody   *capture
+			reqParts  *multipartCapture
+			reqFormat func() string
+		)
+
+		contentType := req.Header.Get("Content-Type")
+		mediaType, params, _ := mime.ParseMediaType(contentType)
+
+		if cfg.ElideAttachments && mediaType == "multipart/form-data" && len(params["boundary"]) > 0 {
+			reqParts = newMultipartCapture(params["boundary"], cfg.MaxBodySize)
+			reqFormat = reqParts.String
+		} else {
+			reqBody = &capture{max: cfg.MaxBodySize}
+			reqFormat = func() string { return cfg.formatBody(contentType, reqBody) }
+		}
+
+		if req.Body != nil {
+			var tee io.Writer = reqBody
+			if reqParts != nil {
+				tee = reqParts
+			}
+			req.Body = &teeBody{ReadCloser: req.Body, w: tee}
+		}
+
+		rw := responseCapture{ResponseWriter: w, c: &capture{max: cfg.MaxBodySize}, status: http.StatusOK}
+
+		next.ServeHTTP(&rw, req)
+
+		reqSize := int64(0)
+		if reqParts != nil {
+			reqParts.close()
+			reqSize = reqParts.size
+		} else {
+			reqSize = reqBody.size
+		}
+
+		logging.FromContext(req.Context(), d.log).WithFields(logger.Fields{
+			"transport":      "rest",
+			"method":         req.Method,
+			"path":           req.URL.RequestURI(),
+			"status":         rw.status,
+			"duration":       time.Since(start).String(),
+			"header":         cfg.redact(req.Header),
+			"requestSize":    reqSize,
+			"request":        reqFormat(),
+			"responseHeader": cfg.redact(rw.Header()),
+			"responseSize":   rw.c.size,
+			"response":       cfg.formatBody(rw.Header().Get("Content-Type"), rw.c),
+		}).Info("debug dump")
+	})
+}
+
+// formatBody returns a body as text. Binary bodies are elided if attachments are.
+func (c *Config) formatBody(contentType string, body *capture) string {
+
+	if body.size == 0 {
+		return ""
+	}
+
+	if c.ElideAttachments && !isText(contentType) {
+		return fmt.Sprintf("[%d bytes of %s elided]", body.size, contentType)
+	}
+
+	return body.String()
+}
+
+// isText tells if a body of contentType is readable in logs
+func isText(contentType string) bool {
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "application/x-www-form-urlencoded"
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+
+	c.size += int64(len(p))
+
+	if room := c.max - c.buf.Len(); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		c.buf.Write(p[:room])
+	}
+
+	return len(p), nil
+}
+
+func (c *capture) String() string {
+	return cut(c.buf.String(), c.size)
+}
+
+// cut marks a kept prefix of a body of size bytes
+func cut(kept string, size int64) string {
+	if rest := size - int64(len(kept)); rest > 0 {
+		return fmt.Sprintf("%s... (%d more bytes)", kept, rest)
+	}
+
+	return kept
+}
+
+func newMultipartCapture(boundary string, max int) *multipartCapture {
+
+	pr, pw := io.Pipe()
+
+	c := multipartCapture{
+		pw:   pw,
+		done: make(chan struct{}),
+		max:  max,
+	}
+
+	go func() {
+		defer close(c.done)
+
+		c.parse(multipart.NewReader(pr, boundary))
+
+		// The handler keeps writing to the pipe after a malformed part
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+
+	return &c
+}
+
+func (c *multipartCapture) parse(mpr *multipart.Reader) {
+
+	for {
+		part, err := mpr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			c.parts = append(c.parts, fmt.Sprintf("[malformed: %v]", err))
+			return
+		}
+
+		if len(part.FileName()) > 0 {
+			n, _ := io.Copy(io.Discard, part)
+			c.parts = append(c.parts, fmt.Sprintf("--- %s %q (%s): [%d bytes elided]",
+				part.FormName(), part.FileName(), part.Header.Get("Content-Type"), n))
+			continue
+		}
+
+		body := capture{max: c.max}
+		_, _ = io.Copy(&body, part)
+		c.parts = append(c.parts, fmt.Sprintf("--- %s\n%s", part.FormName(), body.String()))
+	}
+}
+
+func (c *multipartCapture) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	return c.pw.Write(p)
+}
+
+// close ends the body. Parts that the handler hasn't read are missing.
+func (c *multipartCapture) close() {
+	_ = c.pw.Close()
+	<-c.done
+}
+
+func (c *multipartCapture) String() string {
+	return strings.Join(c.parts, "\n")
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	_, _ = b.w.Write(p[:n])
+	return n, err
+}
+
+func (w *responseCapture) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseCapture) Write(p []byte) (int, error) {
+	_, _ = w.c.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush supports streaming handlers, e.g. file downloads
+func (w *responseCapture) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseCapture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
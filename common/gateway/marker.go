@@ -0,0 +1,61 @@
+// Package gateway marks gRPC calls that are made by the in-process REST gateway. Interceptors skip them,
+// since they are handled by REST middleware already.
+//
+// The marker is a random token of the process, so unlike x-forwarded-host it can't be sent by a client.
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const metadataMarker = "x-gateway-marker"
+
+var marker = newMarker()
+
+func newMarker() string {
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic("gateway: generating marker: " + err.Error())
+	}
+
+	return hex.EncodeToString(buf)
+}
+
+// DialOptions mark every call of the gateway connection
+func DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{},
+			cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(mark(ctx), method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+			method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(mark(ctx), desc, cc, method, opts...)
+		}),
+	}
+}
+
+// IsGatewayCall reports incoming calls of a connection that is dialed with DialOptions of this process
+func IsGatewayCall(ctx context.Context) bool {
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, v := range md.Get(metadataMarker) {
+		if subtle.ConstantTimeCompare([]byte(v), []byte(marker)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func mark(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, metadataMarker, marker)
+}
@@ -0,0 +1,77 @@
+package gateway_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/gateway"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestIsGatewayCall(t *testing.T) {
+
+	lis := bufconn.Listen(1 << 20)
+
+	calls := make(chan bool, 1)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(
+		func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls <- gateway.IsGatewayCall(ctx)
+			return handler(ctx, req)
+		}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	dial := func(opts ...grpc.DialOption) healthpb.HealthClient {
+		opts = append(opts,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		)
+
+		conn, err := grpc.DialContext(context.Background(), "bufnet", opts...)
+		if err != nil {
+			t.Fatalf("dialing: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+
+		return healthpb.NewHealthClient(conn)
+	}
+
+	tests := []struct {
+		name     string
+		client   healthpb.HealthClient
+		md       metadata.MD
+		expected bool
+	}{
+		{name: "gateway", client: dial(gateway.DialOptions()...), expected: true},
+		{name: "client", client: dial(), expected: false},
+		{
+			name:     "forged",
+			client:   dial(),
+			md:       metadata.Pairs("x-forwarded-host", "localhost", "x-gateway-marker", "00000000000000000000000000000000"),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ctx := metadata.NewOutgoingContext(context.Background(), tt.md)
+			if _, err := tt.client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+				t.Fatalf("calling: %v", err)
+			}
+
+			if got := <-calls; got != tt.expected {
+				t.Errorf("IsGatewayCall is %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Package limits caps sizes of requests and responses of REST and gRPC servers and clients.
+package limits
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"google.golang.org/grpc"
+)
+
+var ErrTooLarge = errors.New("request is too large")
+
+// Config limits messages in bytes. Zero values keep gRPC defaults and don't limit REST bodies.
+// Servers receive requests and send responses, clients do the opposite, so both use the same names.
+type Config struct {
+	// MaxRequestSize limits REST request bodies and gRPC request messages
+	MaxRequestSize int `json:"maxRequestSize" yaml:"max-request-size" split_words:"true" default:"33554432" validate:"gte=0"`
+
+	// MaxResponseSize limits gRPC response messages
+	MaxResponseSize int `json:"maxResponseSize" yaml:"max-response-size" split_words:"true" default:"4194304" validate:"gte=0"`
+}
+
+// ServerOptions set message limits of a gRPC server. Larger messages fail with codes.ResourceExhausted.
+func (c Config) ServerOptions() []grpc.ServerOption {
+
+	var opts []grpc.ServerOption
+
+	if c.MaxRequestSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(c.MaxRequestSize))
+	}
+
+	if c.MaxResponseSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(c.MaxResponseSize))
+	}
+
+	return opts
+}
+
+// CallOptions set message limits of a gRPC client, so oversized requests fail before they are sent
+func (c Config) CallOptions() []grpc.CallOption {
+
+	var opts []grpc.CallOption
+
+	if c.MaxRequestSize > 0 {
+		opts = append(opts, grpc.MaxCallSendMsgSize(c.MaxRequestSize))
+	}
+
+	if c.MaxResponseSize > 0 {
+		opts = append(opts, grpc.MaxCallRecvMsgSize(c.MaxResponseSize))
+	}
+
+	return opts
+}
+
+// Middleware rejects request bodies over maxSize bytes with 413. A declared Content-Length is checked
+// before anything is read, other bodies fail with ErrTooLarge as soon as they are read past the limit.
+// Handlers that turn body errors into other statuses could check TooLarge.
+func Middleware(maxSize int64, next http.Handler) http.Handler {
+
+	if maxSize <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.ContentLength > maxSize {
+			// The body isn't read, so the connection can't be reused
+			w.Header().Set("Connection", "close")
+			http.Error(w, ErrTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		body := &limitedBody{rc: r.Body, left: maxSize}
+		r.Body = body
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), bodyKey{}, body)))
+	})
+}
+
+// TooLarge tells if the request body was cut by Middleware
+func TooLarge(r *http.Request) bool {
+	body, ok := r.Context().Value(bodyKey{}).(*limitedBody)
+	return ok && body.exceeded
+}
+
+type bodyKey struct{}
+
+type limitedBody struct {
+	rc       io.ReadCloser
+	left     int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+
+	if b.exceeded {
+		return 0, ErrTooLarge
+	}
+
+	// One byte over the limit tells a body that ends exactly at the limit from a larger one
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+
+	n, err := b.rc.Read(p)
+	if int64(n) <= b.left {
+		b.left -= int64(n)
+		return n, err
+	}
+
+	n = int(b.left)
+	b.left = 0
+	b.exceeded = true
+
+	return n, ErrTooLarge
+}
+
+func (b *limitedBody) Close() error {
+	return b.rc.Close()
+}
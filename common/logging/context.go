@@ -0,0 +1,136 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Fields of request loggers
+const (
+	FieldRequestID = "requestId"
+	FieldPrincipal = "principal"
+	FieldMethod    = "method"
+)
+
+const (
+	// HeaderRequestID is taken from requests or generated, and is returned in responses.
+	// The gateway forwards it to gRPC as MetadataRequestID.
+	HeaderRequestID   = "X-Request-Id"
+	MetadataRequestID = "x-request-id"
+
+	// HeaderPrincipal is the caller identity that is set by an authenticating proxy
+	HeaderPrincipal   = "X-Principal"
+	MetadataPrincipal = "x-principal"
+)
+
+type ctxKey struct{}
+
+// WithEntry returns ctx with a request logger
+func WithEntry(ctx context.Context, entry *logger.Entry) context.Context {
+	return context.WithValue(ctx, ctxKey{}, entry)
+}
+
+// FromContext returns the request logger of ctx or an entry of base if there is none
+func FromContext(ctx context.Context, base *logger.Logger) *logger.Entry {
+
+	if entry, ok := ctx.Value(ctxKey{}).(*logger.Entry); ok {
+		return entry
+	}
+
+	return logger.NewEntry(base).WithContext(ctx)
+}
+
+// RequestID returns the request ID of the request logger of ctx
+func RequestID(ctx context.Context) string {
+
+	if entry, ok := ctx.Value(ctxKey{}).(*logger.Entry); ok {
+		id, _ := entry.Data[FieldRequestID].(string)
+		return id
+	}
+
+	return ""
+}
+
+// Middleware puts a request logger to request contexts and logs completed requests at debug level
+func Middleware(l *logger.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		requestID := r.Header.Get(HeaderRequestID)
+		if len(requestID) == 0 {
+			requestID = NewRequestID()
+			r.Header.Set(HeaderRequestID, requestID)
+		}
+		w.Header().Set(HeaderRequestID, requestID)
+
+		fields := logger.Fields{
+			FieldRequestID: requestID,
+			FieldMethod:    r.Method + " " + r.URL.Path,
+		}
+		if principal := r.Header.Get(HeaderPrincipal); len(principal) > 0 {
+			fields[FieldPrincipal] = principal
+		}
+
+		entry := logger.NewEntry(l).WithFields(fields)
+
+		start := time.Now()
+		next.ServeHTTP(w, r.WithContext(WithEntry(r.Context(), entry)))
+
+		entry.Debugf("request is served in %v", time.Since(start))
+	})
+}
+
+// UnaryInterceptor puts a request logger to call contexts and logs completed calls at debug level.
+// Calls of the gateway keep the request ID of the REST request.
+func UnaryInterceptor(l *logger.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		// Generated IDs are returned to callers in the response header
+		requestID := first(md.Get(MetadataRequestID))
+		if len(requestID) == 0 {
+			requestID = NewRequestID()
+			_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataRequestID, requestID))
+		}
+
+		fields := logger.Fields{
+			FieldRequestID: requestID,
+			FieldMethod:    info.FullMethod,
+		}
+		if principal := first(md.Get(MetadataPrincipal)); len(principal) > 0 {
+			fields[FieldPrincipal] = principal
+		}
+
+		entry := logger.NewEntry(l).WithFields(fields)
+
+		start := time.Now()
+		resp, err := handler(WithEntry(ctx, entry), req)
+
+		entry.Debugf("call is served in %v with %s", time.Since(start), status.Code(err))
+
+		return resp, err
+	}
+}
+
+// NewRequestID returns a random 16 bytes ID in hex
+func NewRequestID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
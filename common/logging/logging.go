@@ -0,0 +1,97 @@
+// Package logging builds logrus loggers from config and carries request loggers in contexts.
+// Loggers are created once in main and injected, Apply reconfigures them in place on a config reload.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+type (
+	Config struct {
+		// Level is trace, debug, info, warn, error, fatal or panic in any case
+		Level   string `json:"level" yaml:"level" split_words:"true" default:"info"`
+		Pretty  bool   `json:"pretty" yaml:"pretty" split_words:"true"`
+		NonJson bool   `json:"nonJson" yaml:"non-json" split_words:"true"`
+
+		// File writes logs to a file instead of stderr
+		File FileConfig `json:"file" yaml:"file"`
+	}
+
+	FileConfig struct {
+		Path string `json:"path" yaml:"path"`
+
+		// MaxSize is bytes of a file that is rotated to <path>.1, <path>.2 and so on. Zero doesn't rotate.
+		MaxSize int64 `json:"maxSize" yaml:"max-size" split_words:"true" default:"104857600" validate:"gte=0"`
+
+		// MaxBackups is the number of rotated files that are kept
+		MaxBackups int `json:"maxBackups" yaml:"max-backups" split_words:"true" default:"5" validate:"gte=0"`
+	}
+)
+
+// New creates a logger. It should be closed with Close if it writes to a file.
+func New(config Config) (*logger.Logger, error) {
+
+	l := logger.New()
+	if err := Apply(l, config); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Apply reconfigures l. Loggers and entries that are derived from l follow the new config.
+// l isn't changed if config is wrong.
+func Apply(l *logger.Logger, config Config) error {
+
+	lvl, err := logger.ParseLevel(config.Level)
+	if err != nil {
+		return fmt.Errorf("parsing log level: %w", err)
+	}
+
+	var out io.Writer = os.Stderr
+	if len(config.File.Path) > 0 {
+
+		// The file is kept if only rotation settings are changed
+		if f, ok := l.Out.(*rotatingFile); ok && f.path == config.File.Path {
+			f.setLimits(config.File.MaxSize, config.File.MaxBackups)
+			out = f
+		} else if out, err = openRotatingFile(config.File); err != nil {
+			return fmt.Errorf("opening log file: %w", err)
+		}
+	}
+
+	var formatter logger.Formatter = &logger.JSONFormatter{PrettyPrint: config.Pretty}
+	if config.NonJson {
+		formatter = &logger.TextFormatter{}
+	}
+
+	prev := l.Out
+
+	l.SetLevel(lvl)
+	l.SetFormatter(formatter)
+	l.SetOutput(out)
+
+	// SetOutput waits for writes in progress, so the previous file isn't used anymore
+	if f, ok := prev.(*rotatingFile); ok && prev != out {
+		_ = f.Close()
+	}
+
+	return nil
+}
+
+// Close closes the log file of l if there is one. l writes to stderr after that.
+func Close(l *logger.Logger) error {
+
+	f, ok := l.Out.(*rotatingFile)
+	if !ok {
+		return nil
+	}
+
+	l.SetOutput(os.Stderr)
+
+	return f.Close()
+}
@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rotatingFile renames a file that outgrows maxSize to <path>.1 and shifts older backups
+type rotatingFile struct {
+	path string
+
+	mu         sync.Mutex
+	f          *os.File
+	size       int64
+	maxSize    int64
+	maxBackups int
+}
+
+func openRotatingFile(config FileConfig) (*rotatingFile, error) {
+
+	r := rotatingFile{
+		path:       config.Path,
+		maxSize:    config.MaxSize,
+		maxBackups: config.MaxBackups,
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (r *rotatingFile) open() error {
+
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	r.f = f
+	r.size = fi.Size()
+
+	return nil
+}
+
+func (r *rotatingFile) setLimits(maxSize int64, maxBackups int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.maxSize = maxSize
+	r.maxBackups = maxBackups
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			// Logs keep going to the current file
+			_, _ = fmt.Fprintf(os.Stderr, "rotating log file: %v\n", err)
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return r.reopen(err)
+		}
+		return r.open()
+	}
+
+	_ = os.Remove(backupName(r.path, r.maxBackups))
+
+	for i := r.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(backupName(r.path, i), backupName(r.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return r.reopen(err)
+		}
+	}
+
+	if err := os.Rename(r.path, backupName(r.path, 1)); err != nil {
+		return r.reopen(err)
+	}
+
+	return r.open()
+}
+
+// reopen continues with the current file after a failed rotation
+func (r *rotatingFile) reopen(cause error) error {
+	if err := r.open(); err != nil {
+		return fmt.Errorf("%v, reopening: %w", cause, err)
+	}
+
+	return cause
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return nil
+	}
+
+	err := r.f.Close()
+	r.f = nil
+
+	return err
+}
+
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
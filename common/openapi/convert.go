@@ -0,0 +1,599 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	Version3 = "3.1.0"
+
+	MIMEJson          = "application/json"
+	MIMEOctetStream   = "application/octet-stream"
+	MIMEMultipartForm = "multipart/form-data"
+	MIMEUrlEncoded    = "application/x-www-form-urlencoded"
+
+	// ExtMultipartForm describes parts of a multipart/form-data body in Swagger 2.0 document.
+	// Swagger 2.0 can't describe an operation that accepts both JSON body and form data,
+	// so the parts are kept in the extension and converted to a requestBody content in OpenAPI 3.
+	//
+	//  x-multipart-form:
+	//    object:
+	//      contentType: application/json
+	//      schema:
+	//        $ref: "#/definitions/v2SayHelloRequest"
+	//    attachment:
+	//      type: file
+	//      repeated: true
+	ExtMultipartForm = "x-multipart-form"
+
+	refDefinitions = "#/definitions/"
+	refSchemas     = "#/components/schemas/"
+)
+
+type (
+	obj = map[string]interface{}
+
+	MultipartPart struct {
+		Description string      `json:"description,omitempty"`
+		ContentType string      `json:"contentType,omitempty"`
+		Type        string      `json:"type,omitempty"`
+		Repeated    bool        `json:"repeated,omitempty"`
+		Schema      interface{} `json:"schema,omitempty"`
+	}
+)
+
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// ConvertV2ToV3 converts Swagger 2.0 document to OpenAPI 3.1.
+// It covers the subset of Swagger 2.0 that protoc-gen-openapiv2 produces.
+func ConvertV2ToV3(swaggerDoc []byte) ([]byte, error) {
+
+	src := obj{}
+	if err := json.Unmarshal(swaggerDoc, &src); err != nil {
+		return nil, fmt.Errorf("unmarshalling swagger document: %w", err)
+	}
+
+	if v, _ := src["swagger"].(string); v != "2.0" {
+		return nil, fmt.Errorf("unsupported swagger version [%v]", src["swagger"])
+	}
+
+	dst := obj{
+		"openapi": Version3,
+	}
+
+	copyKeys(dst, src, "info", "tags", "externalDocs", "security")
+	copyExtensions(dst, src)
+
+	if servers := convertServers(src); len(servers) > 0 {
+		dst["servers"] = servers
+	}
+
+	globalConsumes := stringList(src["consumes"], MIMEJson)
+	globalProduces := stringList(src["produces"], MIMEJson)
+
+	paths := obj{}
+	srcPaths, _ := src["paths"].(obj)
+
+	for p, item := range srcPaths {
+		srcItem, ok := item.(obj)
+		if !ok {
+			return nil, fmt.Errorf("bad path item [%s]", p)
+		}
+
+		dstItem, err := convertPathItem(srcItem, globalConsumes, globalProduces)
+		if err != nil {
+			return nil, fmt.Errorf("path [%s]: %w", p, err)
+		}
+
+		paths[p] = dstItem
+	}
+	dst["paths"] = paths
+
+	components := obj{}
+	if defs, ok := src["definitions"].(obj); ok {
+		schemas := obj{}
+		for name, def := range defs {
+			schemas[name] = convertSchema(def)
+		}
+		components["schemas"] = schemas
+	}
+
+	if secDefs, ok := src["securityDefinitions"].(obj); ok {
+		components["securitySchemes"] = convertSecuritySchemes(secDefs)
+	}
+
+	if len(components) > 0 {
+		dst["components"] = components
+	}
+
+	res, err := json.MarshalIndent(rewriteRefs(dst), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshalling openapi document: %w", err)
+	}
+
+	return res, nil
+}
+
+func convertServers(src obj) []interface{} {
+
+	host, _ := src["host"].(string)
+	basePath, _ := src["basePath"].(string)
+
+	if len(host) == 0 {
+		if len(basePath) == 0 {
+			return nil
+		}
+
+		return []interface{}{obj{"url": basePath}}
+	}
+
+	schemes := stringList(src["schemes"], "http")
+
+	servers := make([]interface{}, 0, len(schemes))
+	for _, scheme := range schemes {
+		servers = append(servers, obj{"url": fmt.Sprintf("%s://%s%s", scheme, host, basePath)})
+	}
+
+	return servers
+}
+
+func convertPathItem(src obj, consumes, produces []string) (obj, error) {
+
+	dst := obj{}
+	copyExtensions(dst, src)
+
+	var itemParams []interface{}
+	if params, ok := src["parameters"].([]interface{}); ok {
+		itemParams = params
+	}
+
+	for _, m := range httpMethods {
+		op, ok := src[m].(obj)
+		if !ok {
+			continue
+		}
+
+		dstOp, err := convertOperation(op, itemParams, consumes, produces)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.ToUpper(m), err)
+		}
+
+		dst[m] = dstOp
+	}
+
+	return dst, nil
+}
+
+func convertOperation(src obj, itemParams []interface{}, consumes, produces []string) (obj, error) {
+
+	dst := obj{}
+	copyKeys(dst, src, "tags", "summary", "description", "externalDocs", "operationId", "deprecated", "security")
+	copyExtensions(dst, src)
+
+	consumes = stringList(src["consumes"], consumes...)
+	produces = stringList(src["produces"], produces...)
+
+	params := make([]interface{}, 0, len(itemParams))
+	params = append(params, itemParams...)
+	if opParams, ok := src["parameters"].([]interface{}); ok {
+		params = append(params, opParams...)
+	}
+
+	content := obj{}
+	required := false
+
+	var formParams []obj
+	var dstParams []interface{}
+
+	for _, p := range params {
+		param, ok := p.(obj)
+		if !ok {
+			return nil, errors.New("bad parameter")
+		}
+
+		switch param["in"] {
+
+		case "body":
+			if r, _ := param["required"].(bool); r {
+				required = true
+			}
+
+			for _, ct := range consumes {
+				if isFormContentType(ct) {
+					continue
+				}
+				content[ct] = obj{"schema": convertSchema(param["schema"])}
+			}
+
+		case "formData":
+			formParams = append(formParams, param)
+
+		default:
+			dstParams = append(dstParams, convertParameter(param))
+		}
+	}
+
+	if len(dstParams) > 0 {
+		dst["parameters"] = dstParams
+	}
+
+	if len(formParams) > 0 {
+		schema, encoding := formParamsSchema(formParams)
+		for _, ct := range consumes {
+			if isFormContentType(ct) {
+				content[ct] = mediaType(schema, encoding)
+			}
+		}
+	}
+
+	// protoc-gen-openapiv2 doesn't keep operation consumes, so the extension itself enables multipart content
+	if parts, ok := src[ExtMultipartForm]; ok {
+		delete(dst, ExtMultipartForm)
+
+		schema, encoding, err := multipartSchema(parts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ExtMultipartForm, err)
+		}
+
+		content[MIMEMultipartForm] = mediaType(schema, encoding)
+	}
+
+	if len(content) > 0 {
+		reqBody := obj{"content": content}
+		if required {
+			reqBody["required"] = true
+		}
+
+		dst["requestBody"] = reqBody
+	}
+
+	if responses, ok := src["responses"].(obj); ok {
+		dst["responses"] = convertResponses(responses, produces)
+	}
+
+	return dst, nil
+}
+
+func convertParameter(src obj) obj {
+
+	dst := obj{}
+	copyKeys(dst, src, "name", "in", "description", "required", "deprecated", "allowEmptyValue")
+	copyExtensions(dst, src)
+
+	schema := obj{}
+	copyKeys(schema, src, "type", "format", "items", "enum", "default", "minimum", "maximum", "pattern", "maxLength", "minLength")
+
+	if src["type"] == "array" {
+		// csv is the default for Swagger 2.0 and form/explode=false is its OpenAPI 3 equivalent
+		switch src["collectionFormat"] {
+		case "multi":
+			dst["style"], dst["explode"] = "form", true
+		case "ssv":
+			dst["style"] = "spaceDelimited"
+		case "pipes":
+			dst["style"] = "pipeDelimited"
+		default:
+			dst["style"], dst["explode"] = "form", false
+		}
+	}
+
+	dst["schema"] = convertSchema(schema)
+
+	return dst
+}
+
+func formParamsSchema(params []obj) (obj, obj) {
+
+	props := obj{}
+	encoding := obj{}
+	var required []interface{}
+
+	for _, p := range params {
+		name, _ := p["name"].(string)
+
+		prop := obj{}
+		copyKeys(prop, p, "description", "type", "format", "items", "enum", "default")
+
+		if p["type"] == "file" {
+			prop = fileSchema(p["description"])
+			encoding[name] = obj{"contentType": MIMEOctetStream}
+		}
+
+		props[name] = convertSchema(prop)
+
+		if r, _ := p["required"].(bool); r {
+			required = append(required, name)
+		}
+	}
+
+	schema := obj{
+		"type":       "object",
+		"properties": props,
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema, encoding
+}
+
+func multipartSchema(ext interface{}) (obj, obj, error) {
+
+	buf, err := json.Marshal(ext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshalling extension: %w", err)
+	}
+
+	parts := make(map[string]MultipartPart)
+	if err := json.Unmarshal(buf, &parts); err != nil {
+		return nil, nil, fmt.Errorf("unmarshalling extension: %w", err)
+	}
+
+	names := make([]string, 0, len(parts))
+	for name := range parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	props := obj{}
+	encoding := obj{}
+
+	for _, name := range names {
+		part := parts[name]
+
+		var prop interface{}
+
+		switch {
+		case part.Type == "file":
+			prop = fileSchema(part.Description)
+
+		case part.Schema != nil:
+			schema := obj{"allOf": []interface{}{convertSchema(part.Schema)}}
+			if len(part.Description) > 0 {
+				schema["description"] = part.Description
+			}
+			prop = schema
+
+		default:
+			return nil, nil, fmt.Errorf("part [%s] has neither schema nor file type", name)
+		}
+
+		if part.Repeated {
+			prop = obj{"type": "array", "items": prop}
+		}
+
+		props[name] = prop
+
+		if len(part.ContentType) > 0 {
+			encoding[name] = obj{"contentType": part.ContentType}
+		}
+	}
+
+	return obj{"type": "object", "properties": props}, encoding, nil
+}
+
+func convertResponses(src obj, produces []string) obj {
+
+	dst := obj{}
+
+	for code, r := range src {
+		resp, ok := r.(obj)
+		if !ok {
+			continue
+		}
+
+		dstResp := obj{}
+		copyKeys(dstResp, resp, "description")
+		copyExtensions(dstResp, resp)
+
+		if _, ok := dstResp["description"]; !ok {
+			dstResp["description"] = ""
+		}
+
+		if schema, ok := resp["schema"]; ok {
+			content := obj{}
+			for _, ct := range produces {
+				content[ct] = obj{"schema": convertSchema(schema)}
+			}
+			dstResp["content"] = content
+		}
+
+		if headers, ok := resp["headers"].(obj); ok {
+			dstHeaders := obj{}
+			for name, h := range headers {
+				header, _ := h.(obj)
+
+				dstHeader := obj{}
+				copyKeys(dstHeader, header, "description")
+
+				schema := obj{}
+				copyKeys(schema, header, "type", "format", "items", "enum", "default", "pattern")
+				dstHeader["schema"] = schema
+
+				dstHeaders[name] = dstHeader
+			}
+			dstResp["headers"] = dstHeaders
+		}
+
+		dst[code] = dstResp
+	}
+
+	return dst
+}
+
+func convertSecuritySchemes(src obj) obj {
+
+	dst := obj{}
+
+	for name, s := range src {
+		scheme, ok := s.(obj)
+		if !ok {
+			continue
+		}
+
+		d := obj{}
+		copyKeys(d, scheme, "description")
+
+		switch scheme["type"] {
+		case "basic":
+			d["type"], d["scheme"] = "http", "basic"
+
+		case "apiKey":
+			d["type"] = "apiKey"
+			copyKeys(d, scheme, "name", "in")
+
+		case "oauth2":
+			d["type"] = "oauth2"
+
+			flow := obj{}
+			copyKeys(flow, scheme, "authorizationUrl", "tokenUrl", "scopes")
+			if _, ok := flow["scopes"]; !ok {
+				flow["scopes"] = obj{}
+			}
+
+			flowName, _ := scheme["flow"].(string)
+			switch flowName {
+			case "accessCode":
+				flowName = "authorizationCode"
+			case "application":
+				flowName = "clientCredentials"
+			}
+			d["flows"] = obj{flowName: flow}
+		}
+
+		dst[name] = d
+	}
+
+	return dst
+}
+
+// convertSchema applies JSON Schema differences of OpenAPI 3.1:
+// file type becomes binary string and x-nullable becomes a "null" type.
+func convertSchema(src interface{}) interface{} {
+
+	switch v := src.(type) {
+
+	case obj:
+		dst := obj{}
+		for k, val := range v {
+			if k == "x-nullable" {
+				continue
+			}
+			dst[k] = convertSchema(val)
+		}
+
+		if dst["type"] == "file" {
+			dst["type"] = "string"
+			dst["contentMediaType"] = MIMEOctetStream
+		}
+
+		if nullable, _ := v["x-nullable"].(bool); nullable {
+			if t, ok := dst["type"].(string); ok {
+				dst["type"] = []interface{}{t, "null"}
+			}
+		}
+
+		return dst
+
+	case []interface{}:
+		dst := make([]interface{}, 0, len(v))
+		for _, val := range v {
+			dst = append(dst, convertSchema(val))
+		}
+		return dst
+
+	default:
+		return v
+	}
+}
+
+func rewriteRefs(v interface{}) interface{} {
+
+	switch val := v.(type) {
+
+	case obj:
+		for k, item := range val {
+			if s, ok := item.(string); ok && k == "$ref" && strings.HasPrefix(s, refDefinitions) {
+				val[k] = refSchemas + strings.TrimPrefix(s, refDefinitions)
+				continue
+			}
+			val[k] = rewriteRefs(item)
+		}
+		return val
+
+	case []interface{}:
+		for i, item := range val {
+			val[i] = rewriteRefs(item)
+		}
+		return val
+
+	default:
+		return v
+	}
+}
+
+func fileSchema(description interface{}) obj {
+
+	schema := obj{
+		"type":             "string",
+		"contentMediaType": MIMEOctetStream,
+	}
+
+	if d, ok := description.(string); ok && len(d) > 0 {
+		schema["description"] = d
+	}
+
+	return schema
+}
+
+func mediaType(schema, encoding obj) obj {
+
+	mt := obj{"schema": schema}
+	if len(encoding) > 0 {
+		mt["encoding"] = encoding
+	}
+
+	return mt
+}
+
+func isFormContentType(ct string) bool {
+	return ct == MIMEMultipartForm || ct == MIMEUrlEncoded
+}
+
+func copyKeys(dst, src obj, keys ...string) {
+	for _, k := range keys {
+		if v, ok := src[k]; ok {
+			dst[k] = v
+		}
+	}
+}
+
+func copyExtensions(dst, src obj) {
+	for k, v := range src {
+		if strings.HasPrefix(k, "x-") {
+			dst[k] = v
+		}
+	}
+}
+
+func stringList(v interface{}, def ...string) []string {
+
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return def
+	}
+
+	res := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			res = append(res, s)
+		}
+	}
+
+	return res
+}
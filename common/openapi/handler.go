@@ -0,0 +1,45 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const (
+	PathSwagger = "/swagger.json"
+	PathOpenAPI = "/openapi.json"
+)
+
+// Docs keeps both versions of an API document.
+type Docs struct {
+	V2 []byte
+	V3 []byte
+}
+
+func NewDocs(swaggerDoc []byte) (*Docs, error) {
+
+	v3, err := ConvertV2ToV3(swaggerDoc)
+	if err != nil {
+		return nil, fmt.Errorf("converting to openapi 3: %w", err)
+	}
+
+	return &Docs{
+		V2: swaggerDoc,
+		V3: v3,
+	}, nil
+}
+
+func (d *Docs) SwaggerHandler() http.HandlerFunc {
+	return jsonHandler(d.V2)
+}
+
+func (d *Docs) OpenAPIHandler() http.HandlerFunc {
+	return jsonHandler(d.V3)
+}
+
+func jsonHandler(doc []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", MIMEJson)
+		_, _ = w.Write(doc)
+	}
+}
@@ -0,0 +1,75 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var ErrTruncated = errors.New("recorded body is truncated")
+
+// Archive is a recording that is read back
+type Archive struct {
+	Dir     string
+	Entries []Entry
+}
+
+// Open reads the index of an archive. Entries are in the order they were recorded.
+func Open(dir string) (*Archive, error) {
+
+	f, err := os.Open(filepath.Join(dir, IndexFileName))
+	if err != nil {
+		return nil, fmt.Errorf("opening recording index: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	a := Archive{Dir: dir}
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+
+		e := Entry{}
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("reading recording index line %d: %w", line, err)
+		}
+
+		a.Entries = append(a.Entries, e)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading recording index: %w", err)
+	}
+
+	return &a, nil
+}
+
+// ReadBody reads a body of an entry. An empty body has no file. Truncated bodies are returned with ErrTruncated.
+func (a *Archive) ReadBody(b Body) ([]byte, error) {
+
+	if len(b.File) == 0 {
+		return nil, nil
+	}
+
+	if filepath.Base(b.File) != b.File {
+		return nil, fmt.Errorf("wrong body file name [%s]", b.File)
+	}
+
+	buf, err := os.ReadFile(filepath.Join(a.Dir, b.File))
+	if err != nil {
+		return nil, fmt.Errorf("reading recorded body: %w", err)
+	}
+
+	if b.Truncated {
+		return buf, ErrTruncated
+	}
+
+	return buf, nil
+}
@@ -0,0 +1,165 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/gateway"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type (
+	// capture keeps up to max bytes of a body and counts the rest
+	capture struct {
+		buf  bytes.Buffer
+		max  int
+		size int64
+	}
+
+	teeBody struct {
+		io.ReadCloser
+		c *capture
+	}
+
+	responseCapture struct {
+		http.ResponseWriter
+		c      *capture
+		status int
+	}
+)
+
+// Middleware records requests that reach next
+func (r *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		start := time.Now()
+
+		reqBody := r.newCapture()
+		if req.Body != nil {
+			req.Body = &teeBody{ReadCloser: req.Body, c: reqBody}
+		}
+
+		rw := responseCapture{ResponseWriter: w, c: r.newCapture(), status: http.StatusOK}
+
+		next.ServeHTTP(&rw, req)
+
+		e := Entry{
+			Transport:  TransportREST,
+			Method:     req.Method,
+			Path:       req.URL.RequestURI(),
+			Header:     redact(req.Header),
+			ReceivedAt: start,
+			Duration:   time.Since(start),
+			Status:     rw.status,
+		}
+
+		if err := r.record(e, reqBody, rw.c); err != nil {
+			logging.FromContext(req.Context(), r.log).Errorf("recording request %s %s: %v", req.Method, req.URL.Path, err)
+		}
+	})
+}
+
+// UnaryInterceptor records unary calls except the ones made by the gateway
+func (r *Recorder) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		if gateway.IsGatewayCall(ctx) {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		e := Entry{
+			Transport:  TransportGRPC,
+			Method:     info.FullMethod,
+			Header:     redact(md),
+			ReceivedAt: start,
+			Duration:   time.Since(start),
+			Status:     int(status.Code(err)),
+		}
+		if err != nil {
+			e.Error = status.Convert(err).Message()
+		}
+
+		if errRec := r.record(e, r.captureMessage(req), r.captureMessage(resp)); errRec != nil {
+			logging.FromContext(ctx, r.log).Errorf("recording call %s: %v", info.FullMethod, errRec)
+		}
+
+		return resp, err
+	}
+}
+
+func (r *Recorder) captureMessage(msg interface{}) *capture {
+
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return nil
+	}
+
+	buf, err := proto.Marshal(m)
+	if err != nil {
+		r.log.Errorf("marshalling recorded message: %v", err)
+		return nil
+	}
+
+	c := r.newCapture()
+	_, _ = c.Write(buf)
+
+	return c
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+
+	c.size += int64(len(p))
+
+	if room := c.max - c.buf.Len(); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		c.buf.Write(p[:room])
+	}
+
+	return len(p), nil
+}
+
+func (c *capture) truncated() bool {
+	return c.size > int64(c.buf.Len())
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	_, _ = b.c.Write(p[:n])
+	return n, err
+}
+
+func (w *responseCapture) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseCapture) Write(p []byte) (int, error) {
+	_, _ = w.c.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush supports streaming handlers, e.g. file downloads
+func (w *responseCapture) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseCapture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,205 @@
+// Package recording captures incoming gRPC and REST requests of a server to a local archive, so they could be
+// replayed later. An archive is a directory with an index.jsonl of entries and a file per request and response body.
+//
+// REST bodies are recorded as they are read by handlers, after decompression. gRPC messages are recorded
+// in the proto wire format. Calls that the gateway makes to the gRPC server are recorded by the REST middleware only.
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	TransportGRPC = "grpc"
+	TransportREST = "rest"
+
+	// IndexFileName lists entries of an archive, one JSON per line
+	IndexFileName = "index.jsonl"
+
+	redacted = "[redacted]"
+)
+
+// sensitiveHeaders are not written to archives. Names are lowercase, the same as gRPC metadata keys.
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+}
+
+var ErrClosed = errors.New("recorder is closed")
+
+type (
+	Config struct {
+		// Enabled starts a new archive in Dir on every server start
+		Enabled bool   `json:"enabled" yaml:"enabled" default:"false"`
+		Dir     string `json:"dir" yaml:"dir" default:"recordings"`
+
+		// MaxBodySize cuts recorded bodies. Cut entries are marked as truncated and can't be replayed.
+		MaxBodySize int `json:"maxBodySize" yaml:"max-body-size" split_words:"true" default:"33554432" validate:"gt=0"`
+	}
+
+	// Entry is a recorded request. Status is an HTTP status for REST and a gRPC code for gRPC.
+	Entry struct {
+		Seq        int64               `json:"seq"`
+		Transport  string              `json:"transport"`
+		Method     string              `json:"method"`
+		Path       string              `json:"path,omitempty"`
+		Header     map[string][]string `json:"header,omitempty"`
+		ReceivedAt time.Time           `json:"receivedAt"`
+		Duration   time.Duration       `json:"duration"`
+		Status     int                 `json:"status"`
+		Error      string              `json:"error,omitempty"`
+
+		Request  Body `json:"request"`
+		Response Body `json:"response"`
+	}
+
+	// Body is a body file of an entry. Size is the full size, the file could be cut by MaxBodySize.
+	Body struct {
+		File      string `json:"file,omitempty"`
+		Size      int64  `json:"size"`
+		Truncated bool   `json:"truncated,omitempty"`
+	}
+
+	Recorder struct {
+		dir         string
+		maxBodySize int
+		log         *logger.Logger
+
+		mu    sync.Mutex
+		seq   int64
+		index *os.File
+	}
+)
+
+// NewRecorder starts an archive in a new subdirectory of config.Dir named by the server name and start time
+func NewRecorder(config Config, name string, log *logger.Logger) (*Recorder, error) {
+
+	dir := filepath.Join(config.Dir, fmt.Sprintf("%s-%s", name, time.Now().UTC().Format("20060102T150405.000")))
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("creating recording dir: %w", err)
+	}
+
+	index, err := os.OpenFile(filepath.Join(dir, IndexFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("creating recording index: %w", err)
+	}
+
+	return &Recorder{
+		dir:         dir,
+		maxBodySize: config.MaxBodySize,
+		index:       index,
+		log:         log,
+	}, nil
+}
+
+// Dir is the archive location
+func (r *Recorder) Dir() string {
+	return r.dir
+}
+
+// Close stops recording. Requests that are finished later are not recorded.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.index == nil {
+		return nil
+	}
+
+	err := r.index.Close()
+	r.index = nil
+
+	if err != nil {
+		return fmt.Errorf("closing recording index: %w", err)
+	}
+
+	return nil
+}
+
+// record writes bodies and appends the entry to the index. Seq and body files are set here.
+func (r *Recorder) record(e Entry, request, response *capture) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.index == nil {
+		return ErrClosed
+	}
+
+	r.seq++
+	e.Seq = r.seq
+
+	var err error
+
+	if e.Request, err = r.writeBody(fmt.Sprintf("%06d.req", e.Seq), request); err != nil {
+		return err
+	}
+
+	if e.Response, err = r.writeBody(fmt.Sprintf("%06d.resp", e.Seq), response); err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshalling entry: %w", err)
+	}
+
+	if _, err := r.index.Write(append(buf, '\n')); err != nil {
+		return fmt.Errorf("writing recording index: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Recorder) writeBody(fileName string, c *capture) (Body, error) {
+
+	if c == nil || c.size == 0 {
+		return Body{}, nil
+	}
+
+	if err := os.WriteFile(filepath.Join(r.dir, fileName), c.buf.Bytes(), 0640); err != nil {
+		return Body{}, fmt.Errorf("writing recorded body: %w", err)
+	}
+
+	return Body{
+		File:      fileName,
+		Size:      c.size,
+		Truncated: c.truncated(),
+	}, nil
+}
+
+func (r *Recorder) newCapture() *capture {
+	return &capture{max: r.maxBodySize}
+}
+
+// redact copies headers with lowercase names and hides credentials
+func redact(h map[string][]string) map[string][]string {
+
+	res := make(map[string][]string, len(h))
+
+	for k, v := range h {
+		k = strings.ToLower(k)
+
+		if sensitiveHeaders[k] {
+			res[k] = []string{redacted}
+			continue
+		}
+
+		res[k] = append([]string(nil), v...)
+	}
+
+	return res
+}
@@ -0,0 +1,35 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileProvider reads a secret from a file, e.g. file:///run/secrets/api-token.
+// A trailing line break is trimmed since most tools write one.
+type FileProvider struct{}
+
+func (FileProvider) Resolve(_ context.Context, path string) (string, error) {
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading secret file: %w", err)
+	}
+
+	return strings.TrimRight(string(buf), "\r\n"), nil
+}
+
+// EnvProvider reads a secret from an env var, e.g. env://API_TOKEN.
+type EnvProvider struct{}
+
+func (EnvProvider) Resolve(_ context.Context, name string) (string, error) {
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("env var %s is not set", name)
+	}
+
+	return value, nil
+}
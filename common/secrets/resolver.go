@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	SchemeFile  = "file"
+	SchemeEnv   = "env"
+	SchemeVault = "vault"
+
+	schemeSeparator = "://"
+)
+
+type (
+	// Provider resolves references of a single scheme. ref is the part after "<scheme>://".
+	Provider interface {
+		Resolve(ctx context.Context, ref string) (string, error)
+	}
+
+	// Resolver dispatches references like "file:///run/secrets/token" to providers by scheme.
+	Resolver struct {
+		providers map[string]Provider
+	}
+)
+
+// NewResolver creates a resolver with file://, env:// and vault:// providers.
+// Vault is configured with VAULT_ADDR and VAULT_TOKEN env vars.
+func NewResolver() *Resolver {
+	r := Resolver{
+		providers: make(map[string]Provider),
+	}
+
+	r.Register(SchemeFile, FileProvider{})
+	r.Register(SchemeEnv, EnvProvider{})
+	r.Register(SchemeVault, NewVaultProviderFromEnv())
+
+	return &r
+}
+
+func (r *Resolver) Register(scheme string, p Provider) {
+	r.providers[scheme] = p
+}
+
+// IsReference reports whether a value should be resolved, i.e. it starts with a registered scheme.
+func (r *Resolver) IsReference(value string) bool {
+	_, _, ok := r.split(value)
+	return ok
+}
+
+// Resolve returns the secret that value refers to.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+
+	p, ref, ok := r.split(value)
+	if !ok {
+		return "", fmt.Errorf("not a secret reference")
+	}
+
+	secret, err := p.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolving [%s]: %w", value, err)
+	}
+
+	return secret, nil
+}
+
+func (r *Resolver) split(value string) (Provider, string, bool) {
+
+	scheme, ref, ok := strings.Cut(value, schemeSeparator)
+	if !ok {
+		return nil, "", false
+	}
+
+	p, ok := r.providers[scheme]
+	if !ok {
+		return nil, "", false
+	}
+
+	return p, ref, true
+}
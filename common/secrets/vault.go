@@ -0,0 +1,122 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	EnvVaultAddr      = "VAULT_ADDR"
+	EnvVaultToken     = "VAULT_TOKEN"
+	EnvVaultNamespace = "VAULT_NAMESPACE"
+
+	defaultVaultTimeout = 10 * time.Second
+)
+
+type (
+	HttpDoer interface {
+		Do(req *http.Request) (*http.Response, error)
+	}
+
+	// VaultProvider reads secrets with Vault HTTP API, e.g. vault://secret/data/grpc-rest#api-token.
+	// The part before '#' is the API path and the part after it is a key in the secret.
+	// Both KV v1 and KV v2 responses are supported. Any server that implements
+	// GET /v1/<path> with X-Vault-Token header works, so a local stub could be used in tests.
+	VaultProvider struct {
+		Addr       string
+		Token      string
+		Namespace  string
+		HttpClient HttpDoer
+	}
+
+	vaultResponse struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []string                   `json:"errors"`
+	}
+)
+
+func NewVaultProviderFromEnv() *VaultProvider {
+	return &VaultProvider{
+		Addr:       os.Getenv(EnvVaultAddr),
+		Token:      os.Getenv(EnvVaultToken),
+		Namespace:  os.Getenv(EnvVaultNamespace),
+		HttpClient: &http.Client{Timeout: defaultVaultTimeout},
+	}
+}
+
+func (p *VaultProvider) Resolve(ctx context.Context, ref string) (string, error) {
+
+	if len(p.Addr) == 0 {
+		return "", fmt.Errorf("%s is not set", EnvVaultAddr)
+	}
+
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || len(key) == 0 {
+		return "", fmt.Errorf("secret key is missing, expected vault://<path>#<key>")
+	}
+
+	url := strings.TrimSuffix(p.Addr, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+
+	if len(p.Token) > 0 {
+		req.Header.Set("X-Vault-Token", p.Token)
+	}
+	if len(p.Namespace) > 0 {
+		req.Header.Set("X-Vault-Namespace", p.Namespace)
+	}
+
+	resp, err := p.HttpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sending request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading response: %w", err)
+	}
+
+	var vaultResp vaultResponse
+	if err := json.Unmarshal(body, &vaultResp); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("decoding response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault responded %s: %s", resp.Status, strings.Join(vaultResp.Errors, "; "))
+	}
+
+	data := vaultResp.Data
+
+	// KV v2 wraps the secret into data.data
+	if nested, ok := data["data"]; ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nil
+			if err := json.Unmarshal(nested, &data); err != nil {
+				return "", fmt.Errorf("decoding kv v2 data: %w", err)
+			}
+		}
+	}
+
+	raw, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s is not found", key)
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		// Non-string values are returned as JSON
+		return string(raw), nil
+	}
+
+	return value, nil
+}
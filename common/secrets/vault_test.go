@@ -0,0 +1,165 @@
+package secrets_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/secrets"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+const vaultToken = "root-token"
+
+// vaultStub serves KV v2 secrets of a mount named "secret". Values could be changed while it runs.
+type vaultStub struct {
+	mu      sync.Mutex
+	secrets map[string]map[string]interface{}
+	version int
+}
+
+func (v *vaultStub) set(path string, data map[string]interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.secrets[path] = data
+	v.version++
+}
+
+func (v *vaultStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Header.Get("X-Vault-Token") != vaultToken {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+
+	data, ok := v.secrets[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")]
+	if r.Method != http.MethodGet || !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errors":[]}`))
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{
+			"data":     data,
+			"metadata": map[string]interface{}{"version": v.version},
+		},
+	})
+}
+
+func TestVaultProvider(t *testing.T) {
+
+	stub := vaultStub{secrets: make(map[string]map[string]interface{})}
+	stub.set("storage", map[string]interface{}{"master-key": "first", "port": 8080})
+
+	srv := httptest.NewServer(&stub)
+	defer srv.Close()
+
+	p := secrets.VaultProvider{Addr: srv.URL, Token: vaultToken, HttpClient: srv.Client()}
+	ctx := context.Background()
+
+	resolve := func(ref string) (string, error) {
+		return p.Resolve(ctx, ref)
+	}
+
+	if value, err := resolve("secret/data/storage#master-key"); err != nil || value != "first" {
+		t.Errorf("kv v2 read: %q, %v", value, err)
+	}
+
+	// Non-string values are returned as JSON
+	if value, err := resolve("secret/data/storage#port"); err != nil || value != "8080" {
+		t.Errorf("non-string read: %q, %v", value, err)
+	}
+
+	if _, err := resolve("secret/data/storage#missing"); err == nil || !strings.Contains(err.Error(), "key missing is not found") {
+		t.Errorf("expected missing key error, got %v", err)
+	}
+
+	if _, err := resolve("secret/data/unknown#master-key"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	forbidden := secrets.VaultProvider{Addr: srv.URL, Token: "wrong", HttpClient: srv.Client()}
+	if _, err := forbidden.Resolve(ctx, "secret/data/storage#master-key"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected permission error, got %v", err)
+	}
+
+	// Values aren't cached, so a refresh returns the new version
+	stub.set("storage", map[string]interface{}{"master-key": "second"})
+
+	if value, err := resolve("secret/data/storage#master-key"); err != nil || value != "second" {
+		t.Errorf("refreshed read: %q, %v", value, err)
+	}
+}
+
+type tokenConfig struct {
+	Token string `yaml:"token" secret:"true"`
+}
+
+func (c *tokenConfig) Validate() error { return nil }
+
+// TestVaultRefresh checks that a config reload picks up a secret that is changed in Vault
+func TestVaultRefresh(t *testing.T) {
+
+	stub := vaultStub{secrets: make(map[string]map[string]interface{})}
+	stub.set("app", map[string]interface{}{"token": "first"})
+
+	srv := httptest.NewServer(&stub)
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("token: vault://secret/data/app#token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	load := func() (*tokenConfig, error) {
+		loader := config.NewLoader([]string{"-config", path})
+		loader.Secrets.Register(secrets.SchemeVault, &secrets.VaultProvider{Addr: srv.URL, Token: vaultToken, HttpClient: srv.Client()})
+
+		var cfg tokenConfig
+		if _, err := loader.Load(&cfg); err != nil {
+			return nil, err
+		}
+		return &cfg, nil
+	}
+
+	cfg, err := load()
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+
+	manager, err := config.NewManager(cfg, []string{path}, load, logger.New(), "token")
+	if err != nil {
+		t.Fatalf("creating manager: %v", err)
+	}
+
+	if manager.Current().Token != "first" {
+		t.Fatalf("token is %q", manager.Current().Token)
+	}
+
+	stub.set("app", map[string]interface{}{"token": "second"})
+
+	report, err := manager.Reload()
+	if err != nil {
+		t.Fatalf("reloading: %v", err)
+	}
+
+	if manager.Current().Token != "second" || len(report.Applied) != 1 || report.Applied[0] != "token" {
+		t.Errorf("token is %q, report: %+v", manager.Current().Token, report)
+	}
+}
@@ -0,0 +1,72 @@
+// mockserver serves both APIs with responses scripted by a scenario file, so the client could run without real servers.
+// Config takes the same layers as the client, see config.Loader:
+//
+//	go run ./cmd/mockserver -scenario mock-scenario.yaml
+//	APP_SCENARIO=mock-scenario.yaml APP_GRPC_HOST=:9080 go run ./cmd/mockserver
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/limits"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/mockserver"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+type Config struct {
+	Log logging.Config `json:"log" yaml:"log"`
+
+	// Hosts are the default hosts of the real servers, so the client config works as is
+	GrpcHost string `json:"grpcHost" yaml:"grpc-host" split_words:"true" default:":8080"`
+	RestHost string `json:"restHost" yaml:"rest-host" split_words:"true" default:":8090"`
+
+	// Scenario is a YAML file with scripted responses, see mockserver.Scenario
+	Scenario string `json:"scenario" yaml:"scenario" validate:"required"`
+
+	Limits limits.Config `json:"limits" yaml:"limits"`
+}
+
+func main() {
+
+	if err := setup(); err != nil {
+		logger.Error(err)
+		os.Exit(1)
+	}
+}
+
+func setup() error {
+
+	cfg := Config{}
+
+	loadResult, err := config.NewLoader(os.Args[1:]).Load(&cfg)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	if loadResult.PrintConfig {
+		return config.Print(os.Stdout, &cfg, loadResult)
+	}
+
+	log, err := logging.New(cfg.Log)
+	if err != nil {
+		return fmt.Errorf("creating logger: %w", err)
+	}
+	defer func() { _ = logging.Close(log) }()
+
+	scenario, err := mockserver.LoadScenario(cfg.Scenario)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return mockserver.New(scenario, log).Run(ctx, cfg.GrpcHost, cfg.RestHost, cfg.Limits.ServerOptions()...)
+}
@@ -0,0 +1,132 @@
+// replay re-sends hello calls of a server recording to the configured servers. Calls are sent with the repository
+// type that matches the recorded transport and API version unless -type is set. Config takes the same layers
+// as the client, so the grpc and rest sections of the client config are used as is:
+//
+//	CONFIG_FILE=config.yaml go run ./cmd/replay -archive recordings/grpc-rest-multipart-server-20241019T101500.000
+//	CONFIG_FILE=config.yaml go run ./cmd/replay -archive <dir> -type grpcv2 -speed 0 -diff
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/recording"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/replay"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc"
+	grpcV1 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc/v1"
+	grpcV2 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc/v2"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/rest"
+	restV1 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/rest/v1"
+	restV2 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/rest/v2"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/service"
+)
+
+type Config struct {
+	GRPC grpc.Config `json:"grpc" yaml:"grpc"`
+	Rest rest.Config `json:"rest" yaml:"rest"`
+
+	// Archive is a recording dir with index.jsonl
+	Archive string `json:"archive" yaml:"archive" validate:"required"`
+
+	// Type overrides the recorded repository type
+	Type string `json:"type" yaml:"type" validate:"omitempty,oneof=grpcv1 grpcv2 restv1 restv2"`
+
+	// Speed divides recorded intervals between calls. Zero sends calls one after another.
+	Speed float64 `json:"speed" yaml:"speed" default:"1" validate:"gte=0"`
+
+	// Diff compares responses with the recorded ones. Replay fails if any differs.
+	Diff bool `json:"diff" yaml:"diff"`
+}
+
+func main() {
+
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+
+	cfg := Config{}
+
+	loadResult, err := config.NewLoader(os.Args[1:]).Load(&cfg)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	if loadResult.PrintConfig {
+		return config.Print(os.Stdout, &cfg, loadResult)
+	}
+
+	archive, err := recording.Open(cfg.Archive)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var closers []func() error
+	defer func() {
+		for _, c := range closers {
+			_ = c()
+		}
+	}()
+
+	repos := func(ctx context.Context, repoType string) (service.HelloRepo, error) {
+		switch repoType {
+
+		case service.TypeGrpcV1:
+			repo, err := grpcV1.BuildRepo(ctx, cfg.GRPC)
+			if err != nil {
+				return nil, err
+			}
+			closers = append(closers, repo.Close)
+			return repo, nil
+
+		case service.TypeGrpcV2:
+			repo, err := grpcV2.BuildRepo(ctx, cfg.GRPC)
+			if err != nil {
+				return nil, err
+			}
+			closers = append(closers, repo.Close)
+			return repo, nil
+
+		case service.TypeRestV1:
+			return restV1.New(cfg.Rest)
+
+		case service.TypeRestV2:
+			return restV2.New(cfg.Rest)
+		}
+
+		return nil, fmt.Errorf("wrong repository type: %s", repoType)
+	}
+
+	opts := replay.Options{
+		RepoType: cfg.Type,
+		Speed:    cfg.Speed,
+		Diff:     cfg.Diff,
+	}
+
+	sum, err := replay.New(opts, repos, os.Stdout).Run(ctx, archive)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("sent %d, skipped %d, failed %d", sum.Sent, sum.Skipped, sum.Failed)
+	if cfg.Diff {
+		fmt.Printf(", differ %d", sum.Differs)
+	}
+	fmt.Println()
+
+	if cfg.Diff && sum.Differs > 0 {
+		return fmt.Errorf("%d responses differ from the recording", sum.Differs)
+	}
+
+	return nil
+}
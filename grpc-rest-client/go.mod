@@ -1,29 +1,57 @@
 module github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client
 
-go 1.19
+go 1.22
 
 require (
 	github.com/go-playground/validator/v10 v10.11.1
-	github.com/hashicorp/go-multierror v1.1.1
-	github.com/kelseyhightower/envconfig v1.4.0
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.13.0
 	github.com/sirupsen/logrus v1.9.0
+	github.com/yurii-vyrovyi/go-grpc-rest/common v0.0.0
 	github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server v0.0.1
 	github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server v0.0.0-20221112113359-f1b1150c9f34
 	google.golang.org/grpc v1.50.1
+	google.golang.org/protobuf v1.28.1
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	github.com/KyleBanks/depth v1.2.1 // indirect
+	github.com/fsnotify/fsnotify v1.6.0 // indirect
+	github.com/go-openapi/jsonpointer v0.19.5 // indirect
+	github.com/go-openapi/jsonreference v0.20.0 // indirect
+	github.com/go-openapi/spec v0.20.6 // indirect
+	github.com/go-openapi/swag v0.19.15 // indirect
 	github.com/go-playground/locales v0.14.0 // indirect
 	github.com/go-playground/universal-translator v0.18.0 // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
-	github.com/grpc-ecosystem/grpc-gateway/v2 v2.13.0 // indirect
 	github.com/hashicorp/errwrap v1.0.0 // indirect
+	github.com/hashicorp/go-multierror v1.1.1 // indirect
+	github.com/josharian/intern v1.0.0 // indirect
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/labstack/echo/v4 v4.9.1 // indirect
+	github.com/labstack/gommon v0.4.0 // indirect
 	github.com/leodido/go-urn v1.2.1 // indirect
+	github.com/mailru/easyjson v0.7.7 // indirect
+	github.com/mattn/go-colorable v0.1.11 // indirect
+	github.com/mattn/go-isatty v0.0.14 // indirect
+	github.com/swaggo/echo-swagger v1.3.5 // indirect
+	github.com/swaggo/files v0.0.0-20220728132757-551d4a08d97a // indirect
+	github.com/swaggo/http-swagger v1.3.3 // indirect
+	github.com/swaggo/swag v1.8.7 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.1 // indirect
+	go.etcd.io/bbolt v1.3.10 // indirect
 	golang.org/x/crypto v0.0.0-20220411220226-7b82a4e95df4 // indirect
 	golang.org/x/net v0.1.0 // indirect
-	golang.org/x/sys v0.1.0 // indirect
+	golang.org/x/sys v0.7.0 // indirect
 	golang.org/x/text v0.4.0 // indirect
+	golang.org/x/tools v0.1.12 // indirect
 	google.golang.org/genproto v0.0.0-20221027153422-115e99e71e1c // indirect
-	google.golang.org/protobuf v1.28.1 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
+
+replace (
+	github.com/yurii-vyrovyi/go-grpc-rest/common => ../common
+	github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server => ../grpc-rest-multipart-server
+	github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server => ../grpc-rest-server
 )
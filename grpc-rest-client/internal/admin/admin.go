@@ -0,0 +1,215 @@
+// Package admin is the admin subcommand of the client. It calls the Admin service of a running server:
+//
+//	ADMIN_TOKEN=... grpc-rest-client admin -host localhost:8095 info
+//	grpc-rest-client admin config
+//	grpc-rest-client admin connections
+//	grpc-rest-client admin requests
+//	grpc-rest-client admin log-level debug
+//	grpc-rest-client admin storage
+//	grpc-rest-client admin retention -dry-run
+package admin
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin/api"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// envToken is the default of -token, so the token isn't visible in the process list
+const envToken = "ADMIN_TOKEN"
+
+const usage = `usage: admin [-host host] [-token token] [-timeout duration] [-json] <command>
+
+The token is taken from ADMIN_TOKEN env var if -token isn't set.
+
+commands:
+  info                 build info of the server
+  config               effective config, secrets are redacted
+  connections          open client connections
+  requests             in-flight requests, e.g. uploads
+  log-level <level>    changes the log level until restart or a log config reload
+  storage              storage statistics
+  retention [-dry-run] runs retention now
+`
+
+// Run executes a command of args and writes its result to out
+func Run(ctx context.Context, args []string, out io.Writer) error {
+
+	flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() { fmt.Fprint(out, usage) }
+
+	host := flags.String("host", "localhost:8095", "admin host of the server")
+	token := flags.String("token", os.Getenv(envToken), "admin token of the server")
+	timeout := flags.Duration("timeout", 30*time.Second, "call timeout")
+	asJSON := flags.Bool("json", false, "print responses as JSON")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("command is missing")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	ctx = admin.WithToken(ctx, *token)
+
+	conn, err := grpc.DialContext(ctx, *host, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("dialing admin server: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	client := api.NewAdminClient(conn)
+
+	resp, err := call(ctx, client, flags.Arg(0), flags.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		buf, err := protojson.MarshalOptions{Multiline: true, EmitUnpopulated: true}.Marshal(resp)
+		if err != nil {
+			return fmt.Errorf("marshalling response: %w", err)
+		}
+
+		_, err = fmt.Fprintln(out, string(buf))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	printResponse(tw, resp)
+
+	return tw.Flush()
+}
+
+func call(ctx context.Context, client api.AdminClient, command string, args []string) (proto.Message, error) {
+
+	empty := &emptypb.Empty{}
+
+	switch command {
+
+	case "info":
+		return client.GetBuildInfo(ctx, empty)
+
+	case "config":
+		return client.GetConfig(ctx, empty)
+
+	case "connections":
+		return client.ListConnections(ctx, empty)
+
+	case "requests":
+		return client.ListRequests(ctx, empty)
+
+	case "log-level":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("log-level takes a level, e.g. debug")
+		}
+		return client.SetLogLevel(ctx, &api.SetLogLevelRequest{Level: args[0]})
+
+	case "storage":
+		return client.GetStorageStats(ctx, empty)
+
+	case "retention":
+		flags := flag.NewFlagSet("retention", flag.ContinueOnError)
+		dryRun := flags.Bool("dry-run", false, "report what would be removed")
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		return client.RunRetention(ctx, &api.RunRetentionRequest{DryRun: *dryRun})
+	}
+
+	return nil, fmt.Errorf("unknown command: %s", command)
+}
+
+// printResponse writes a response as a table
+func printResponse(w io.Writer, resp proto.Message) {
+
+	switch r := resp.(type) {
+
+	case *api.BuildInfo:
+		fmt.Fprintf(w, "server\t%s\n", r.Server)
+		fmt.Fprintf(w, "version\t%s\n", r.Version)
+		if r.Modified {
+			fmt.Fprintf(w, "revision\t%s (modified)\n", r.Revision)
+		} else {
+			fmt.Fprintf(w, "revision\t%s\n", r.Revision)
+		}
+		fmt.Fprintf(w, "revision time\t%s\n", formatTime(r.RevisionTime))
+		fmt.Fprintf(w, "go\t%s\n", r.GoVersion)
+		fmt.Fprintf(w, "host\t%s\n", r.Hostname)
+		fmt.Fprintf(w, "pid\t%d\n", r.Pid)
+		fmt.Fprintf(w, "started\t%s (%s ago)\n", formatTime(r.StartedAt), since(r.StartedAt))
+
+	case *api.GetConfigResponse:
+		fmt.Fprintln(w, "PATH\tVALUE\tRELOADABLE")
+		for _, f := range r.Fields {
+			fmt.Fprintf(w, "%s\t%s\t%v\n", f.Path, f.Value, f.Reloadable)
+		}
+
+	case *api.ListConnectionsResponse:
+		fmt.Fprintln(w, "LISTENER\tREMOTE\tSTATE\tOPEN FOR")
+		for _, c := range r.Connections {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Listener, c.RemoteAddr, c.State, since(c.OpenedAt))
+		}
+
+	case *api.ListRequestsResponse:
+		fmt.Fprintln(w, "LISTENER\tMETHOD\tREQUEST ID\tPRINCIPAL\tREMOTE\tRUNNING FOR\tRECEIVED")
+		for _, req := range r.Requests {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+				req.Listener, req.Method, req.RequestId, req.Principal, req.RemoteAddr, since(req.StartedAt), req.BytesReceived)
+		}
+
+	case *api.SetLogLevelResponse:
+		fmt.Fprintf(w, "log level\t%s -> %s\n", r.Previous, r.Level)
+
+	case *api.StorageStats:
+		fmt.Fprintf(w, "store\t%s\n", r.StoreLocation)
+		fmt.Fprintf(w, "files\t%d\t%d bytes\n", r.Files, r.Bytes)
+		fmt.Fprintf(w, "trash\t%d\t%d bytes\n", r.TrashFiles, r.TrashBytes)
+		fmt.Fprintf(w, "quarantine\t%d\t%d bytes\n", r.QuarantineFiles, r.QuarantineBytes)
+		fmt.Fprintf(w, "uploads\t%d\n", r.Uploads)
+		fmt.Fprintf(w, "attachments\t%d\n", r.Attachments)
+
+	case *api.RetentionReport:
+		if r.DryRun {
+			fmt.Fprintln(w, "dry run")
+		}
+		fmt.Fprintf(w, "trashed\t%d\t%d bytes\n", r.Trashed, r.TrashedBytes)
+		fmt.Fprintf(w, "removed\t%d\t%d bytes reclaimed\n", r.Removed, r.ReclaimedBytes)
+	}
+}
+
+func formatTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+
+	return ts.AsTime().Local().Format(time.RFC3339)
+}
+
+func since(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+
+	return time.Since(ts.AsTime()).Round(time.Second).String()
+}
@@ -0,0 +1,184 @@
+package harness_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"sort"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/harness"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/service"
+	multipartServer "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/testserver"
+)
+
+var attachments = []service.Attachment{
+	{FileName: "notes.txt", Data: []byte("plain text notes\n"), ContentType: "text/plain"},
+	{FileName: "report.final.json", Data: []byte(`{"total": 42}`), ContentType: "application/json"},
+}
+
+func helloRequest() *service.Request {
+	return &service.Request{
+		Title:       "title",
+		Description: "desc",
+		IntValue:    42,
+		Attachments: attachments,
+	}
+}
+
+func TestSendHello(t *testing.T) {
+
+	tests := []struct {
+		repoType      string
+		noAttachments bool
+		wantResp      string
+		stored        bool
+	}{
+		{repoType: service.TypeGrpcV1, wantResp: "title: [desc: 42]"},
+		{repoType: service.TypeRestV1, noAttachments: true, wantResp: "title: [desc: 42]"},
+		{repoType: service.TypeGrpcV2, wantResp: "title: [desc: 42]. [notes.txt,report.final.json] were saved", stored: true},
+		{repoType: service.TypeRestV2, wantResp: "title: [desc: 42]. [notes.txt,report.final.json] were saved", stored: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repoType, func(t *testing.T) {
+
+			env := harness.Start(t)
+
+			req := helloRequest()
+			if tt.noAttachments {
+				req.Attachments = nil
+			}
+
+			resp, err := env.Repo(t, tt.repoType).SendHello(context.Background(), req)
+			if err != nil {
+				t.Fatalf("sending hello: %v", err)
+			}
+
+			if resp != tt.wantResp {
+				t.Errorf("response: got %q, want %q", resp, tt.wantResp)
+			}
+
+			if !tt.stored {
+				return
+			}
+
+			records := listAttachments(t, env.V2)
+			if len(records) != len(attachments) {
+				t.Fatalf("attachment records: got %d, want %d", len(records), len(attachments))
+			}
+
+			storedNames := make([]string, 0, len(records))
+
+			for i, at := range attachments {
+				rec := records[i]
+
+				if rec.FileName != at.FileName {
+					t.Errorf("record %d file name: got %q, want %q", i, rec.FileName, at.FileName)
+				}
+
+				if rec.Size != len(at.Data) {
+					t.Errorf("record %d size: got %d, want %d", i, rec.Size, len(at.Data))
+				}
+
+				// Original names are kept in metadata only
+				if path.Ext(rec.StoredName) != path.Ext(at.FileName) || path.Base(rec.StoredName) == at.FileName {
+					t.Errorf("record %d stored name: %q", i, rec.StoredName)
+				}
+
+				if got := env.V2.ReadStored(t, rec.StoredName); !bytes.Equal(got, at.Data) {
+					t.Errorf("stored %s: got %q, want %q", at.FileName, got, at.Data)
+				}
+
+				storedNames = append(storedNames, rec.StoredName)
+			}
+
+			sort.Strings(storedNames)
+			if got := env.V2.StoredFiles(t); !equal(got, storedNames) {
+				t.Errorf("stored files: got %v, want %v", got, storedNames)
+			}
+		})
+	}
+}
+
+// TestSendHelloV1Attachments checks that attachments aren't dropped silently by REST v1 that has no attachments
+func TestSendHelloV1Attachments(t *testing.T) {
+
+	env := harness.Start(t)
+
+	_, err := env.Repo(t, service.TypeRestV1).SendHello(context.Background(), helloRequest())
+	if !errors.Is(err, service.ErrAttachmentsNotSupported) {
+		t.Errorf("expected %v, got %v", service.ErrAttachmentsNotSupported, err)
+	}
+}
+
+func TestSendHelloRejected(t *testing.T) {
+
+	for _, repoType := range []string{service.TypeGrpcV2, service.TypeRestV2} {
+		t.Run(repoType, func(t *testing.T) {
+
+			env := harness.Start(t, multipartServer.WithYAML("service: {limits: {max-attachments: 1}}"))
+
+			if _, err := env.Repo(t, repoType).SendHello(context.Background(), helloRequest()); err == nil {
+				t.Fatal("request over the attachments limit is accepted")
+			}
+
+			if got := env.V2.StoredFiles(t); len(got) > 0 {
+				t.Errorf("rejected request left files: %v", got)
+			}
+
+			if got := listAttachments(t, env.V2); len(got) > 0 {
+				t.Errorf("rejected request is recorded: %v", got)
+			}
+		})
+	}
+}
+
+type attachmentRecord struct {
+	FileName   string `json:"fileName"`
+	StoredName string `json:"storedName"`
+	Size       int    `json:"size,string"`
+}
+
+// listAttachments returns records of the multipart server in upload order
+func listAttachments(t *testing.T, srv *multipartServer.Server) []attachmentRecord {
+	t.Helper()
+
+	resp, err := http.Get(srv.URL + "/v2/attachments?order_by=created_at")
+	if err != nil {
+		t.Fatalf("listing attachments: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("listing attachments: status %d", resp.StatusCode)
+	}
+
+	var page struct {
+		Attachments []attachmentRecord `json:"attachments"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("decoding attachments: %v", err)
+	}
+
+	return page.Attachments
+}
+
+func equal(a, b []string) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
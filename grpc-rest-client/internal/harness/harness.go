@@ -0,0 +1,103 @@
+// Package harness runs both servers in-process and builds client repositories that are connected to them.
+// grpcv1 and restv1 repositories talk to grpc-rest-server, grpcv2 and restv2 to grpc-rest-multipart-server.
+package harness
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc"
+	grpcV1 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc/v1"
+	grpcV2 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc/v2"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/rest"
+	restV1 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/rest/v1"
+	restV2 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/rest/v2"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/service"
+	multipartServer "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/testserver"
+	restServer "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/testserver"
+)
+
+type Env struct {
+	V1 *restServer.Server
+	V2 *multipartServer.Server
+}
+
+// Start runs both servers. Options configure the multipart server.
+func Start(t testing.TB, options ...multipartServer.Option) *Env {
+	t.Helper()
+
+	return &Env{
+		V1: restServer.Start(t),
+		V2: multipartServer.Start(t, options...),
+	}
+}
+
+// Repo builds a repository of the type that is connected to its server. It's closed when the test ends.
+func (e *Env) Repo(t testing.TB, repoType string) service.HelloRepo {
+	t.Helper()
+
+	switch repoType {
+
+	case service.TypeGrpcV1:
+		repo, err := grpcV1.BuildRepo(context.Background(), grpcConfig(t, restServer.Target), e.V1.DialOptions()...)
+		if err != nil {
+			t.Fatalf("building %s repo: %v", repoType, err)
+		}
+		t.Cleanup(func() { _ = repo.Close() })
+
+		return repo
+
+	case service.TypeGrpcV2:
+		repo, err := grpcV2.BuildRepo(context.Background(), grpcConfig(t, multipartServer.Target), e.V2.DialOptions()...)
+		if err != nil {
+			t.Fatalf("building %s repo: %v", repoType, err)
+		}
+		t.Cleanup(func() { _ = repo.Close() })
+
+		return repo
+
+	case service.TypeRestV1:
+		repo, err := restV1.New(restConfig(t, e.V1.URL))
+		if err != nil {
+			t.Fatalf("building %s repo: %v", repoType, err)
+		}
+
+		return repo
+
+	case service.TypeRestV2:
+		repo, err := restV2.New(restConfig(t, e.V2.URL))
+		if err != nil {
+			t.Fatalf("building %s repo: %v", repoType, err)
+		}
+
+		return repo
+	}
+
+	t.Fatalf("wrong repository type: %s", repoType)
+	return nil
+}
+
+func grpcConfig(t testing.TB, host string) grpc.Config {
+	t.Helper()
+
+	cfg := grpc.Config{}
+	if err := config.Defaults(&cfg); err != nil {
+		t.Fatalf("applying grpc config defaults: %v", err)
+	}
+	cfg.Host = host
+
+	return cfg
+}
+
+func restConfig(t testing.TB, url string) rest.Config {
+	t.Helper()
+
+	cfg := rest.Config{}
+	if err := config.Defaults(&cfg); err != nil {
+		t.Fatalf("applying rest config defaults: %v", err)
+	}
+	cfg.URL = url
+
+	return cfg
+}
@@ -1,74 +1,35 @@
 package opts
 
 import (
-	"encoding/json"
-	"fmt"
-	"gopkg.in/yaml.v3"
-	"os"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
 
-	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/log"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/rest"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/service"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/hashicorp/go-multierror"
-	"github.com/kelseyhightower/envconfig"
-)
-
-const (
-	defaultAppPrefix = "APP"
 )
 
 type Config struct {
-	Log     log.Config     `json:"log" yaml:"log"`
+	Log     logging.Config `json:"log" yaml:"log"`
 	GRPC    grpc.Config    `json:"grpc" yaml:"grpc"`
 	Rest    rest.Config    `json:"rest" yaml:"rest"`
 	Service service.Config `json:"service" yaml:"service" validate:"required"`
 }
 
-type YamlConfigLoader interface {
-	Unmarshal([]byte) error
-}
-
-func LoadConfigFromFileOrEnvs(configFile string, config interface{}) error {
+// Load merges defaults, config files, APP_* env vars and command line flags.
+// See config.Loader for the precedence.
+func Load(args []string) (*Config, *config.Result, error) {
 
-	if len(configFile) == 0 {
-		if err := FromEnv(config); err != nil {
-			return fmt.Errorf("locading opts from envs: %w", err)
-		}
+	cfg := Config{}
 
-		return nil
-	}
-
-	buf, err := os.ReadFile(configFile)
+	res, err := config.NewLoader(args).Load(&cfg)
 	if err != nil {
-		return fmt.Errorf("reading opts file: %w", err)
-	}
-
-	errYaml := yaml.Unmarshal(buf, config)
-	if errYaml == nil {
-		return nil
-	}
-
-	errJson := json.Unmarshal(buf, config)
-	if errJson == nil {
-		return nil
-	}
-
-	return multierror.Append(
-		fmt.Errorf("bad opts file [%s]. Failed to unmarshal YAML and JSON", configFile),
-		errYaml, errJson,
-	)
-}
-
-func FromEnv(config interface{}) error {
-
-	if err := envconfig.Process(defaultAppPrefix, &config); err != nil {
-		return fmt.Errorf("failed to load opts: %w", err)
+		return nil, nil, err
 	}
 
-	return nil
+	return &cfg, res, nil
 }
 
 func (c *Config) Validate() error {
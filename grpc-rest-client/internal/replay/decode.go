@@ -0,0 +1,305 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/recording"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/service"
+	apiV2 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	apiV1 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/api"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Recorded calls that are replayed. Other calls are skipped.
+const (
+	methodV1SayHello = "/grpc_rest.v1.GrpcRestService/SayHello"
+	methodV2SayHello = "/grpc_rest.v2.GrpcRestMultipartService/SayHello"
+
+	pathV1SayHello = "/v1/sayhello"
+	pathV2SayHello = "/v2/sayhello"
+	pathV2Hello    = "/v2/hello"
+
+	partObject     = "object"
+	partAttachment = "attachment"
+)
+
+var ErrNotReplayable = errors.New("call can't be replayed")
+
+var jsonUnmarshaler = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// Call is a recorded hello with its recorded outcome
+type Call struct {
+	Entry recording.Entry
+
+	// RepoType is the repository type that sends the call the way it was recorded
+	RepoType string
+	Request  *service.Request
+
+	// Failed calls have Error instead of Response. Response is unknown if its recorded body is truncated.
+	Response        string
+	Failed          bool
+	Error           string
+	ResponseUnknown bool
+}
+
+// Decode reads a recorded hello of any transport. Calls of other methods are ErrNotReplayable.
+func Decode(a *recording.Archive, e recording.Entry) (*Call, error) {
+
+	call := Call{Entry: e}
+
+	body, err := a.ReadBody(e.Request)
+	if errors.Is(err, recording.ErrTruncated) {
+		return nil, fmt.Errorf("%w: request body is truncated", ErrNotReplayable)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Transport {
+
+	case recording.TransportGRPC:
+		err = call.decodeGrpc(body)
+
+	case recording.TransportREST:
+		err = call.decodeRest(body)
+
+	default:
+		err = fmt.Errorf("%w: unknown transport [%s]", ErrNotReplayable, e.Transport)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.ReadBody(e.Response)
+	if errors.Is(err, recording.ErrTruncated) {
+		call.ResponseUnknown = true
+		return &call, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := call.decodeResponse(resp); err != nil {
+		return nil, fmt.Errorf("decoding recorded response: %w", err)
+	}
+
+	return &call, nil
+}
+
+func (c *Call) decodeGrpc(body []byte) error {
+
+	switch c.Entry.Method {
+
+	case methodV1SayHello:
+		req := apiV1.SayHelloRequest{}
+		if err := proto.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("unmarshalling request: %w", err)
+		}
+
+		c.RepoType = service.TypeGrpcV1
+		c.Request = fromApiV1(&req)
+
+	case methodV2SayHello:
+		req := apiV2.SayHelloRequest{}
+		if err := proto.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("unmarshalling request: %w", err)
+		}
+
+		c.RepoType = service.TypeGrpcV2
+		c.Request = fromApiV2(&req)
+
+	default:
+		return fmt.Errorf("%w: %s", ErrNotReplayable, c.Entry.Method)
+	}
+
+	return nil
+}
+
+func (c *Call) decodeRest(body []byte) error {
+
+	u, err := url.Parse(c.Entry.Path)
+	if err != nil {
+		return fmt.Errorf("parsing path: %w", err)
+	}
+
+	if c.Entry.Method != http.MethodPost {
+		return fmt.Errorf("%w: %s %s", ErrNotReplayable, c.Entry.Method, u.Path)
+	}
+
+	switch u.Path {
+
+	case pathV1SayHello:
+		req := apiV1.SayHelloRequest{}
+		if err := jsonUnmarshaler.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("unmarshalling request: %w", err)
+		}
+
+		c.RepoType = service.TypeRestV1
+		c.Request = fromApiV1(&req)
+
+	case pathV2SayHello, pathV2Hello:
+		req, err := c.decodeRestV2(body)
+		if err != nil {
+			return err
+		}
+
+		c.RepoType = service.TypeRestV2
+		c.Request = fromApiV2(req)
+
+	default:
+		return fmt.Errorf("%w: %s %s", ErrNotReplayable, c.Entry.Method, u.Path)
+	}
+
+	return nil
+}
+
+// decodeRestV2 reads a JSON or multipart body of the recorded content type
+func (c *Call) decodeRestV2(body []byte) (*apiV2.SayHelloRequest, error) {
+
+	req := apiV2.SayHelloRequest{}
+
+	contentType := ""
+	if v := c.Entry.Header["content-type"]; len(v) > 0 {
+		contentType = v[0]
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		if err := jsonUnmarshaler.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf("unmarshalling request: %w", err)
+		}
+
+		return &req, nil
+	}
+
+	mpr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+
+	for {
+		part, err := mpr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return &req, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading part: %w", err)
+		}
+
+		buf, err := io.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("reading part [%s]: %w", part.FormName(), err)
+		}
+
+		switch part.FormName() {
+
+		case partObject:
+			object := apiV2.SayHelloRequest{}
+			if err := jsonUnmarshaler.Unmarshal(buf, &object); err != nil {
+				return nil, fmt.Errorf("unmarshalling request object: %w", err)
+			}
+
+			req.Title = object.Title
+			req.Description = object.Description
+			req.IntValue = object.IntValue
+
+		case partAttachment:
+			req.Attachments = append(req.Attachments, &apiV2.Attachment{
+				FileName:    part.FileName(),
+				BinaryData:  buf,
+				ContentType: part.Header.Get("Content-Type"),
+			})
+		}
+	}
+}
+
+func (c *Call) decodeResponse(buf []byte) error {
+
+	if c.Entry.Transport == recording.TransportGRPC {
+
+		if code := codes.Code(c.Entry.Status); code != codes.OK {
+			c.Failed = true
+			c.Error = fmt.Sprintf("%s: %s", code, c.Entry.Error)
+			return nil
+		}
+
+		var resp interface{ GetResponse() string }
+
+		switch c.RepoType {
+		case service.TypeGrpcV1:
+			r := apiV1.SayHelloResponse{}
+			if err := proto.Unmarshal(buf, &r); err != nil {
+				return err
+			}
+			resp = &r
+
+		default:
+			r := apiV2.SayHelloResponse{}
+			if err := proto.Unmarshal(buf, &r); err != nil {
+				return err
+			}
+			resp = &r
+		}
+
+		c.Response = resp.GetResponse()
+		return nil
+	}
+
+	var resp struct {
+		Response string `json:"response"`
+		Message  string `json:"message"`
+	}
+
+	// Error responses of echo handlers could be empty
+	if len(buf) > 0 {
+		if err := json.Unmarshal(buf, &resp); err != nil {
+			return err
+		}
+	}
+
+	if c.Entry.Status >= http.StatusBadRequest {
+		c.Failed = true
+		c.Error = fmt.Sprintf("%d: %s", c.Entry.Status, resp.Message)
+		return nil
+	}
+
+	c.Response = resp.Response
+
+	return nil
+}
+
+func fromApiV1(req *apiV1.SayHelloRequest) *service.Request {
+	return &service.Request{
+		Title:       req.Title,
+		Description: req.Description,
+		IntValue:    int(req.IntValue),
+	}
+}
+
+func fromApiV2(req *apiV2.SayHelloRequest) *service.Request {
+
+	res := service.Request{
+		Title:       req.Title,
+		Description: req.Description,
+		IntValue:    int(req.IntValue),
+	}
+
+	for _, at := range req.Attachments {
+		res.Attachments = append(res.Attachments, service.Attachment{
+			FileName:    at.FileName,
+			Data:        at.BinaryData,
+			ContentType: at.ContentType,
+		})
+	}
+
+	return &res
+}
@@ -0,0 +1,189 @@
+// Package replay re-sends hello calls of a recording archive with client repositories.
+// Calls are sent one by one in the order they were received. Other recorded calls are skipped.
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/recording"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/service"
+)
+
+type (
+	Options struct {
+		// RepoType sends all calls with one repository type. Calls are sent with the recorded type by default.
+		RepoType string
+
+		// Speed divides recorded intervals between calls, e.g. 2 replays twice as fast. Zero doesn't wait.
+		Speed float64
+
+		// Diff compares responses with the recorded ones
+		Diff bool
+	}
+
+	// Repos builds a repository of a type. It's called once per type.
+	Repos func(ctx context.Context, repoType string) (service.HelloRepo, error)
+
+	Replayer struct {
+		opts  Options
+		repos Repos
+		out   io.Writer
+
+		built map[string]service.HelloRepo
+	}
+
+	Summary struct {
+		Sent    int
+		Skipped int
+		Failed  int
+		Differs int
+	}
+)
+
+// New creates a replayer that reports every call to out
+func New(opts Options, repos Repos, out io.Writer) *Replayer {
+	return &Replayer{
+		opts:  opts,
+		repos: repos,
+		out:   out,
+		built: make(map[string]service.HelloRepo),
+	}
+}
+
+func (r *Replayer) Run(ctx context.Context, a *recording.Archive) (Summary, error) {
+
+	sum := Summary{}
+
+	entries := append([]recording.Entry(nil), a.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].ReceivedAt.Before(entries[j].ReceivedAt)
+	})
+
+	if len(entries) == 0 {
+		return sum, nil
+	}
+
+	start := time.Now()
+	first := entries[0].ReceivedAt
+
+	for _, e := range entries {
+
+		name := entryName(e)
+
+		call, err := Decode(a, e)
+		if err != nil {
+			r.printf("%s: skipped: %v\n", name, err)
+			sum.Skipped++
+			continue
+		}
+
+		if err := r.wait(ctx, start.Add(r.scale(e.ReceivedAt.Sub(first)))); err != nil {
+			return sum, err
+		}
+
+		repoType := call.RepoType
+		if len(r.opts.RepoType) > 0 {
+			repoType = r.opts.RepoType
+		}
+
+		repo, err := r.repo(ctx, repoType)
+		if err != nil {
+			return sum, err
+		}
+
+		sent := time.Now()
+		resp, err := repo.SendHello(ctx, call.Request)
+		took := time.Since(sent)
+
+		sum.Sent++
+
+		if err != nil {
+			sum.Failed++
+			r.printf("%s via %s: error in %v (recorded %v): %v\n", name, repoType, took, e.Duration, err)
+		} else {
+			r.printf("%s via %s: %q in %v (recorded %v)\n", name, repoType, resp, took, e.Duration)
+		}
+
+		if r.opts.Diff && !call.ResponseUnknown && differs(call, resp, err) {
+			sum.Differs++
+			r.printf("  differs: recorded %s\n", recordedOutcome(call))
+		}
+	}
+
+	return sum, nil
+}
+
+func (r *Replayer) repo(ctx context.Context, repoType string) (service.HelloRepo, error) {
+
+	if repo, ok := r.built[repoType]; ok {
+		return repo, nil
+	}
+
+	repo, err := r.repos(ctx, repoType)
+	if err != nil {
+		return nil, fmt.Errorf("building %s repo: %w", repoType, err)
+	}
+	r.built[repoType] = repo
+
+	return repo, nil
+}
+
+func (r *Replayer) scale(d time.Duration) time.Duration {
+	if r.opts.Speed <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(d) / r.opts.Speed)
+}
+
+func (r *Replayer) wait(ctx context.Context, until time.Time) error {
+
+	d := time.Until(until)
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Replayer) printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(r.out, format, args...)
+}
+
+// differs compares outcomes. Error messages differ between transports, so only the fact of an error is compared.
+func differs(call *Call, resp string, err error) bool {
+
+	if call.Failed || err != nil {
+		return call.Failed != (err != nil)
+	}
+
+	return call.Response != resp
+}
+
+func recordedOutcome(call *Call) string {
+	if call.Failed {
+		return "error " + call.Error
+	}
+
+	return fmt.Sprintf("%q", call.Response)
+}
+
+func entryName(e recording.Entry) string {
+	if e.Transport == recording.TransportREST {
+		return fmt.Sprintf("#%d %s %s", e.Seq, e.Method, e.Path)
+	}
+
+	return fmt.Sprintf("#%d %s", e.Seq, e.Method)
+}
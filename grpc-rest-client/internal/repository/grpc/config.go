@@ -1,8 +1,33 @@
 package grpc
 
-import "time"
+import (
+	"time"
+
+	// compress also registers gzip and zstd gRPC compressors
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/compress"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/limits"
+
+	goGrpc "google.golang.org/grpc"
+)
 
 type Config struct {
-	Host    string        `json:"host" yaml:"host" split_words:"true" validate:"required"`
-	Timeout time.Duration `json:"timeout" yaml:"timeout" split_words:"true" default:"15s"`
+	Host        string        `json:"host" yaml:"host" split_words:"true" validate:"required"`
+	Timeout     time.Duration `json:"timeout" yaml:"timeout" split_words:"true" default:"15s"`
+	Compression string        `json:"compression" yaml:"compression" split_words:"true" default:"none" validate:"oneof=none gzip zstd"`
+
+	// Limits should match the server, so oversized requests fail before they are sent
+	Limits limits.Config `json:"limits" yaml:"limits"`
+}
+
+// CallOptions compress requests with the configured compressor and limit message sizes.
+// Responses are compressed the same way.
+func (c Config) CallOptions() []goGrpc.CallOption {
+
+	opts := c.Limits.CallOptions()
+
+	if compress.IsSupported(c.Compression) {
+		opts = append(opts, goGrpc.UseCompressor(c.Compression))
+	}
+
+	return opts
 }
@@ -21,14 +21,20 @@ type (
 	}
 )
 
-func BuildRepo(ctx context.Context, config grpc.Config) (*Repository, error) {
+// BuildRepo dials config.Host. Options are added to the default ones, e.g. to dial an in-process server in tests.
+func BuildRepo(ctx context.Context, config grpc.Config, opts ...goGrpc.DialOption) (*Repository, error) {
 
 	dialCtx, cancel := context.WithTimeout(ctx, config.Timeout)
 	defer cancel()
 
 	insecureCreds := insecure.NewCredentials()
 
-	conn, err := goGrpc.DialContext(dialCtx, config.Host, goGrpc.WithTransportCredentials(insecureCreds))
+	opts = append([]goGrpc.DialOption{
+		goGrpc.WithTransportCredentials(insecureCreds),
+		goGrpc.WithDefaultCallOptions(config.CallOptions()...),
+	}, opts...)
+
+	conn, err := goGrpc.DialContext(dialCtx, config.Host, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("dialling server: %w", err)
 	}
@@ -10,8 +10,9 @@ func ToApiAttachments(src []service.Attachment) []*api.Attachment {
 
 	for _, at := range src {
 		res = append(res, &api.Attachment{
-			FileName:   at.FileName,
-			BinaryData: at.Data,
+			FileName:    at.FileName,
+			BinaryData:  at.Data,
+			ContentType: at.ContentType,
 		})
 	}
 
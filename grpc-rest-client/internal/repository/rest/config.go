@@ -1,6 +1,22 @@
 package rest
 
+import (
+	"net/http"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/compress"
+)
+
 type Config struct {
-	URL              string `json:"url" yaml:"url" validate:"required"`
-	SayHelloEndpoint string `json:"sayHelloEndpoint" yaml:"say-hello-endpoint" validate:"required"`
+	URL         string        `json:"url" yaml:"url" validate:"required"`
+	Timeout     time.Duration `json:"timeout" yaml:"timeout" split_words:"true" default:"15s"`
+	Compression string        `json:"compression" yaml:"compression" split_words:"true" default:"none" validate:"oneof=none gzip zstd"`
+}
+
+// HttpClient sends request bodies with the configured Content-Encoding and accepts compressed responses
+func (c Config) HttpClient() *http.Client {
+	return &http.Client{
+		Timeout:   c.Timeout,
+		Transport: &compress.Transport{Encoding: c.Compression},
+	}
 }
@@ -6,41 +6,38 @@ import (
 
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/rest"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/service"
-	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api/sdk"
 )
 
 type (
 	Repository struct {
-		rest.Config
-	}
-
-	Payload struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		IntValue    int    `json:"int_value"`
+		client *sdk.Client
 	}
 )
 
-func New(config rest.Config) *Repository {
+func New(config rest.Config) (*Repository, error) {
 
-	return &Repository{
-		Config: config,
+	client, err := sdk.NewClient(config.URL, sdk.WithHttpClient(config.HttpClient()))
+	if err != nil {
+		return nil, fmt.Errorf("creating sdk client: %w", err)
 	}
+
+	return &Repository{
+		client: client,
+	}, nil
 }
 
+// SendHello sends multipart request. Attachments are streamed as file parts.
 func (r *Repository) SendHello(ctx context.Context, req *service.Request) (string, error) {
 
-	client := api.NewRestApiClient(r.URL)
-
-	apiAttachments := ToApiAttachments(req.Attachments)
-
-	resp, err := client.SendHello(ctx, &api.SayHelloRequest{
-		Title:       req.Title,
-		Description: req.Description,
-		IntValue:    int64(req.IntValue),
-		Attachments: apiAttachments,
+	resp, err := r.client.SayHelloMultipart(ctx, &sdk.SayHelloMultipartBody{
+		Object: &sdk.SayHelloRequest{
+			Title:       req.Title,
+			Description: req.Description,
+			IntValue:    int64(req.IntValue),
+		},
+		Attachment: ToFileParts(req.Attachments),
 	})
-
 	if err != nil {
 		return "", fmt.Errorf("sending hello: %w", err)
 	}
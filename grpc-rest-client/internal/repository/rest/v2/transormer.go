@@ -1,20 +1,23 @@
 package v2
 
 import (
+	"bytes"
+
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/service"
-	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api/sdk"
 )
 
-func ToApiAttachments(src []service.Attachment) []*api.Attachment {
+func ToFileParts(src []service.Attachment) []sdk.FilePart {
 	if len(src) == 0 {
 		return nil
 	}
 
-	res := make([]*api.Attachment, 0, len(src))
+	res := make([]sdk.FilePart, 0, len(src))
 	for _, at := range src {
-		res = append(res, &api.Attachment{
-			FileName:   at.FileName,
-			BinaryData: at.Data,
+		res = append(res, sdk.FilePart{
+			FileName:    at.FileName,
+			ContentType: at.ContentType,
+			Reader:      bytes.NewReader(at.Data),
 		})
 	}
 
@@ -2,10 +2,14 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"mime"
 	"os"
 	"path/filepath"
 	"time"
+
+	logger "github.com/sirupsen/logrus"
 )
 
 type (
@@ -21,10 +25,14 @@ const (
 	TypeRestV2 = "restv2"
 )
 
+// ErrAttachmentsNotSupported is returned by repositories of APIs without attachments, so files aren't lost silently
+var ErrAttachmentsNotSupported = errors.New("attachments are not supported by the API")
+
 type (
 	Service struct {
 		Config
 		helloRepo HelloRepo
+		log       *logger.Logger
 	}
 
 	Config struct {
@@ -40,16 +48,18 @@ type (
 	}
 
 	Attachment struct {
-		FileName string
-		Data     []byte
+		FileName    string
+		Data        []byte
+		ContentType string
 	}
 )
 
-func New(config Config, helloRepo HelloRepo) *Service {
+func New(config Config, helloRepo HelloRepo, log *logger.Logger) *Service {
 
 	return &Service{
 		Config:    config,
 		helloRepo: helloRepo,
+		log:       log,
 	}
 }
 
@@ -66,11 +76,14 @@ func (svc *Service) Run(ctx context.Context) error {
 		}
 
 		attachments = append(attachments, Attachment{
-			FileName: fileName,
-			Data:     data,
+			FileName:    fileName,
+			Data:        data,
+			ContentType: mime.TypeByExtension(filepath.Ext(fileName)),
 		})
 	}
 
+	svc.log.Debugf("sending hello with %d attachments via %s", len(attachments), svc.Type)
+
 	resp, err := svc.helloRepo.SendHello(ctx, &Request{
 		Title:       "tit",
 		Description: "desc",
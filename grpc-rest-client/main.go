@@ -8,7 +8,9 @@ import (
 	"strings"
 	"syscall"
 
-	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/log"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/admin"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/opts"
 	grpcV1 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc/v1"
 	grpcV2 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc/v2"
@@ -21,6 +23,15 @@ import (
 
 func main() {
 
+	// admin calls the Admin service of a running server instead of sending hello requests
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		if err := admin.Run(context.Background(), os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := setup(); err != nil {
 		logger.Error(err)
 		os.Exit(1)
@@ -29,24 +40,28 @@ func main() {
 
 func setup() error {
 
-	configFile := os.Getenv("CONFIG_FILE")
-
-	config := opts.Config{}
-
-	err := opts.LoadConfigFromFileOrEnvs(configFile, &config)
+	cfg, loadResult, err := opts.Load(os.Args[1:])
 	if err != nil {
 		return fmt.Errorf("building opts: %w", err)
 	}
 
+	if loadResult.PrintConfig {
+		return config.Print(os.Stdout, cfg, loadResult)
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	initLogger(config.Log)
+	log, err := logging.New(cfg.Log)
+	if err != nil {
+		return fmt.Errorf("creating logger: %w", err)
+	}
+	defer func() { _ = logging.Close(log) }()
 
-	return run(ctx, config)
+	return run(ctx, *cfg, log)
 }
 
-func run(ctx context.Context, config opts.Config) error {
+func run(ctx context.Context, config opts.Config, log *logger.Logger) error {
 
 	var sendRepo service.HelloRepo
 
@@ -71,17 +86,27 @@ func run(ctx context.Context, config opts.Config) error {
 		sendRepo = grpcRepo
 
 	case service.TypeRestV1:
-		sendRepo = restV1.New(config.Rest)
+		restRepo, err := restV1.New(config.Rest)
+		if err != nil {
+			return fmt.Errorf("building hello repo: %w", err)
+		}
+
+		sendRepo = restRepo
 
 	case service.TypeRestV2:
-		sendRepo = restV2.New(config.Rest)
+		restRepo, err := restV2.New(config.Rest)
+		if err != nil {
+			return fmt.Errorf("building hello repo: %w", err)
+		}
+
+		sendRepo = restRepo
 
 	default:
 		return fmt.Errorf("wrong repository type: %v", config.Service.Type)
 
 	}
 
-	svc := service.New(config.Service, sendRepo)
+	svc := service.New(config.Service, sendRepo, log)
 
 	if err := svc.Run(ctx); err != nil {
 		return fmt.Errorf("service run: %w", err)
@@ -89,26 +114,3 @@ func run(ctx context.Context, config opts.Config) error {
 
 	return nil
 }
-
-func initLogger(config log.Config) {
-
-	var lvl logger.Level
-
-	switch strings.ToUpper(config.Level) {
-	case "TRACE":
-		lvl = logger.TraceLevel
-	case "INFO":
-		lvl = logger.InfoLevel
-	case "ERROR":
-		lvl = logger.ErrorLevel
-
-	default:
-		lvl = logger.DebugLevel
-	}
-
-	logger.SetLevel(lvl)
-	if !config.NonJson {
-		logger.SetFormatter(&logger.JSONFormatter{PrettyPrint: config.Pretty})
-	}
-
-}
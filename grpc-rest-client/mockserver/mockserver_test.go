@@ -0,0 +1,244 @@
+package mockserver_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc"
+	grpcV1 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc/v1"
+	grpcV2 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/grpc/v2"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/rest"
+	restV1 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/rest/v1"
+	restV2 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/repository/rest/v2"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/internal/service"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-client/mockserver"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const scenario = `
+methods:
+  grpc_rest.v1.GrpcRestService/SayHello:
+    - response: {response: "v1 hello"}
+  grpc_rest.v2.GrpcRestMultipartService/SayHello:
+    - response: {uploadId: "u-1", response: "v2 hello"}
+    - error: {code: RESOURCE_EXHAUSTED, message: "too many attachments", http-status: 413}
+    - error: {code: Unavailable, message: "store is not available"}
+`
+
+func helloRequest() *service.Request {
+	return &service.Request{
+		Title:       "title",
+		Description: "desc",
+		IntValue:    42,
+		Attachments: []service.Attachment{
+			{FileName: "notes.txt", Data: []byte("plain text notes\n"), ContentType: "text/plain"},
+		},
+	}
+}
+
+func TestScriptedSteps(t *testing.T) {
+
+	for _, repoType := range []string{service.TypeGrpcV2, service.TypeRestV2} {
+		t.Run(repoType, func(t *testing.T) {
+
+			srv := mockserver.Start(t, mockserver.MustParse(t, scenario))
+			repo := newRepo(t, srv, repoType)
+
+			resp, err := repo.SendHello(context.Background(), helloRequest())
+			if err != nil {
+				t.Fatalf("first step: %v", err)
+			}
+			if resp != "v2 hello" {
+				t.Errorf("first step: got %q", resp)
+			}
+
+			// The last step repeats
+			for i := 0; i < 2; i++ {
+				if _, err := repo.SendHello(context.Background(), helloRequest()); err == nil {
+					t.Fatalf("call %d: error step succeeded", i+2)
+				}
+			}
+
+			if got := srv.Calls(mockserver.MethodV2SayHello); got != 3 {
+				t.Errorf("calls: got %d, want 3", got)
+			}
+		})
+	}
+
+	for _, repoType := range []string{service.TypeGrpcV1, service.TypeRestV1} {
+		t.Run(repoType, func(t *testing.T) {
+
+			srv := mockserver.Start(t, mockserver.MustParse(t, scenario))
+
+			// v1 API has no attachments
+			req := helloRequest()
+			req.Attachments = nil
+
+			resp, err := newRepo(t, srv, repoType).SendHello(context.Background(), req)
+			if err != nil {
+				t.Fatalf("sending hello: %v", err)
+			}
+			if resp != "v1 hello" {
+				t.Errorf("got %q", resp)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+
+	srv := mockserver.Start(t, mockserver.MustParse(t, scenario))
+
+	// Skipping the response step
+	if _, err := newRepo(t, srv, service.TypeGrpcV2).SendHello(context.Background(), helloRequest()); err != nil {
+		t.Fatalf("first step: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		wantStatus int
+	}{
+		{name: "http status is set", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "http status by code", wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			resp, err := http.Post(srv.URL+"/v2/sayhello", "application/json", strings.NewReader(`{"title": "x"}`))
+			if err != nil {
+				t.Fatalf("posting: %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status: got %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+
+	_, err := newRepo(t, srv, service.TypeGrpcV2).SendHello(context.Background(), helloRequest())
+	if got := status.Code(unwrap(err)); got != codes.Unavailable {
+		t.Errorf("grpc code: got %v, want %v", got, codes.Unavailable)
+	}
+
+	empty := mockserver.Start(t, mockserver.MustParse(t, "methods: {}"))
+
+	_, err = newRepo(t, empty, service.TypeGrpcV1).SendHello(context.Background(), helloRequest())
+	if got := status.Code(unwrap(err)); got != codes.Unimplemented {
+		t.Errorf("method without steps: got %v, want %v", got, codes.Unimplemented)
+	}
+}
+
+func TestDelay(t *testing.T) {
+
+	srv := mockserver.Start(t, mockserver.MustParse(t, `
+methods:
+  grpc_rest.v2.GrpcRestMultipartService/SayHello:
+    - delay: 300ms
+      response: {response: "late"}
+`))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := newRepo(t, srv, service.TypeGrpcV2).SendHello(ctx, helloRequest()); status.Code(unwrap(err)) != codes.DeadlineExceeded {
+		t.Errorf("delayed step: got %v, want a deadline error", err)
+	}
+
+	start := time.Now()
+
+	resp, err := newRepo(t, srv, service.TypeRestV2).SendHello(context.Background(), helloRequest())
+	if err != nil {
+		t.Fatalf("sending hello: %v", err)
+	}
+
+	if resp != "late" || time.Since(start) < 300*time.Millisecond {
+		t.Errorf("got %q in %v", resp, time.Since(start))
+	}
+}
+
+func TestParseScenario(t *testing.T) {
+
+	tests := map[string]string{
+		"unknown method":   `methods: {Unknown/Method: [{response: {}}]}`,
+		"unknown field":    `methods: {grpc_rest.v1.GrpcRestService/SayHello: [{response: {unknown: 1}}]}`,
+		"unknown code":     `methods: {grpc_rest.v1.GrpcRestService/SayHello: [{error: {code: Oops}}]}`,
+		"error + response": `methods: {grpc_rest.v1.GrpcRestService/SayHello: [{error: {code: Internal}, response: {response: x}}]}`,
+		"negative delay":   `methods: {grpc_rest.v1.GrpcRestService/SayHello: [{delay: -1s}]}`,
+	}
+
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := mockserver.ParseScenario([]byte(doc)); err == nil {
+				t.Error("scenario is accepted")
+			}
+		})
+	}
+}
+
+// unwrap takes the gRPC status error that repositories wrap
+func unwrap(err error) error {
+	for err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		err = errors.Unwrap(err)
+	}
+
+	return err
+}
+
+func newRepo(t *testing.T, srv *mockserver.TestServer, repoType string) service.HelloRepo {
+	t.Helper()
+
+	grpcCfg := grpc.Config{}
+	restCfg := rest.Config{}
+
+	if err := config.Defaults(&grpcCfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Defaults(&restCfg); err != nil {
+		t.Fatal(err)
+	}
+
+	grpcCfg.Host = srv.GrpcHost
+	restCfg.URL = srv.URL
+
+	var (
+		repo service.HelloRepo
+		err  error
+	)
+
+	switch repoType {
+	case service.TypeGrpcV1:
+		var r *grpcV1.Repository
+		if r, err = grpcV1.BuildRepo(context.Background(), grpcCfg); err == nil {
+			t.Cleanup(func() { _ = r.Close() })
+			repo = r
+		}
+	case service.TypeGrpcV2:
+		var r *grpcV2.Repository
+		if r, err = grpcV2.BuildRepo(context.Background(), grpcCfg); err == nil {
+			t.Cleanup(func() { _ = r.Close() })
+			repo = r
+		}
+	case service.TypeRestV1:
+		repo, err = restV1.New(restCfg)
+	case service.TypeRestV2:
+		repo, err = restV2.New(restCfg)
+	}
+
+	if err != nil {
+		t.Fatalf("building %s repo: %v", repoType, err)
+	}
+
+	return repo
+}
@@ -0,0 +1,115 @@
+package mockserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	apiV2 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	apiV1 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/api"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	mimeMultipartForm = "multipart/form-data"
+
+	// pathV2Hello is the multipart only endpoint of the multipart server
+	pathV2Hello = "/v2/hello"
+)
+
+// jsonMarshaler is the default marshaler of the gateway
+var jsonMarshaler = &runtime.JSONPb{
+	MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+	UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+}
+
+// RestHandler serves both services the way their gateways do. Multipart hello bodies are drained,
+// so clients could stream attachments.
+func (s *Server) RestHandler() (http.Handler, error) {
+
+	gwmux := runtime.NewServeMux(
+		runtime.WithForwardResponseOption(createdResponse),
+		runtime.WithErrorHandler(errorHandler),
+	)
+
+	if err := apiV1.RegisterGrpcRestServiceHandlerServer(context.Background(), gwmux, &serviceV1{srv: s}); err != nil {
+		return nil, fmt.Errorf("registering v1 gateway handler: %w", err)
+	}
+
+	v2 := &serviceV2{srv: s}
+	if err := apiV2.RegisterGrpcRestMultipartServiceHandlerServer(context.Background(), gwmux, v2); err != nil {
+		return nil, fmt.Errorf("registering v2 gateway handler: %w", err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodPost && isMultipart(r) &&
+			(r.URL.Path == apiV2.EndpointV2SayHello || r.URL.Path == pathV2Hello) {
+			multipartHello(w, r, gwmux, v2)
+			return
+		}
+
+		gwmux.ServeHTTP(w, r)
+	}), nil
+}
+
+// multipartHello responds to a multipart hello. The body isn't parsed, scenario responses don't depend on it.
+func multipartHello(w http.ResponseWriter, r *http.Request, mux *runtime.ServeMux, v2 *serviceV2) {
+
+	if _, err := io.Copy(io.Discard, r.Body); err != nil {
+		errorHandler(r.Context(), mux, jsonMarshaler, w, r, fmt.Errorf("reading body: %w", err))
+		return
+	}
+
+	resp, err := v2.SayHello(r.Context(), &apiV2.SayHelloRequest{})
+	if err != nil {
+		errorHandler(r.Context(), mux, jsonMarshaler, w, r, err)
+		return
+	}
+
+	buf, err := jsonMarshaler.Marshal(resp)
+	if err != nil {
+		errorHandler(r.Context(), mux, jsonMarshaler, w, r, fmt.Errorf("marshalling response: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", jsonMarshaler.ContentType(resp))
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write(buf)
+}
+
+// createdResponse keeps the status code of a successful hello of the multipart server
+func createdResponse(_ context.Context, w http.ResponseWriter, msg proto.Message) error {
+
+	if _, ok := msg.(*apiV2.SayHelloResponse); ok {
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	return nil
+}
+
+// errorHandler responds with the scripted HTTP status of a step, if it's set
+func errorHandler(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+
+	if httpStatus, ok := httpStatusError(err); ok {
+		err = &runtime.HTTPStatusError{HTTPStatus: httpStatus, Err: err}
+	}
+
+	runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+}
+
+func isMultipart(r *http.Request) bool {
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(mediaType, mimeMultipartForm)
+}
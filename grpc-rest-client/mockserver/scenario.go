@@ -0,0 +1,162 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	apiV2 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	apiV1 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/api"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Methods are full gRPC method names that scenarios script
+const (
+	MethodV1SayHello        = "grpc_rest.v1.GrpcRestService/SayHello"
+	MethodV2SayHello        = "grpc_rest.v2.GrpcRestMultipartService/SayHello"
+	MethodV2ListAttachments = "grpc_rest.v2.GrpcRestMultipartService/ListAttachments"
+	MethodV2GetUpload       = "grpc_rest.v2.GrpcRestMultipartService/GetUpload"
+)
+
+// responseTypes make an empty response of a method, canned responses are checked against them
+var responseTypes = map[string]func() proto.Message{
+	MethodV1SayHello:        func() proto.Message { return &apiV1.SayHelloResponse{} },
+	MethodV2SayHello:        func() proto.Message { return &apiV2.SayHelloResponse{} },
+	MethodV2ListAttachments: func() proto.Message { return &apiV2.ListAttachmentsResponse{} },
+	MethodV2GetUpload:       func() proto.Message { return &apiV2.Upload{} },
+}
+
+type (
+	// Scenario scripts responses by method. Steps of a method are played in order of calls
+	// and the last step repeats. A method without steps responds Unimplemented.
+	//
+	//	methods:
+	//	  grpc_rest.v2.GrpcRestMultipartService/SayHello:
+	//	    - delay: 500ms
+	//	      response: {uploadId: "u-1", response: "hello"}
+	//	    - error: {code: ResourceExhausted, message: "too many attachments", http-status: 413}
+	Scenario struct {
+		Methods map[string][]Step `json:"methods" yaml:"methods"`
+	}
+
+	// Step responds after Delay with Error or Response. Response is a response message in JSON
+	// mapping of proto, i.e. as the gateway serves it.
+	Step struct {
+		Delay    time.Duration          `json:"delay" yaml:"delay"`
+		Error    *StepError             `json:"error" yaml:"error"`
+		Response map[string]interface{} `json:"response" yaml:"response"`
+
+		response proto.Message
+	}
+
+	// StepError is a gRPC status. REST responds with the HTTP status the gateway maps the code to
+	// unless HTTPStatus is set.
+	StepError struct {
+		Code       string `json:"code" yaml:"code"`
+		Message    string `json:"message" yaml:"message"`
+		HTTPStatus int    `json:"httpStatus" yaml:"http-status"`
+
+		code codes.Code
+	}
+)
+
+// LoadScenario reads a YAML scenario file
+func LoadScenario(fileName string) (*Scenario, error) {
+
+	buf, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("reading scenario: %w", err)
+	}
+
+	return ParseScenario(buf)
+}
+
+// ParseScenario parses and checks a YAML scenario. Canned responses are unmarshalled into response messages,
+// so a mistyped field fails here rather than in a call.
+func ParseScenario(buf []byte) (*Scenario, error) {
+
+	sc := Scenario{}
+	if err := yaml.Unmarshal(buf, &sc); err != nil {
+		return nil, fmt.Errorf("unmarshalling scenario: %w", err)
+	}
+
+	for method, steps := range sc.Methods {
+
+		newResponse, ok := responseTypes[method]
+		if !ok {
+			return nil, fmt.Errorf("unknown method [%s]", method)
+		}
+
+		for i := range steps {
+			if err := steps[i].prepare(newResponse()); err != nil {
+				return nil, fmt.Errorf("method [%s] step %d: %w", method, i, err)
+			}
+		}
+	}
+
+	return &sc, nil
+}
+
+func (s *Step) prepare(resp proto.Message) error {
+
+	if s.Delay < 0 {
+		return errors.New("negative delay")
+	}
+
+	if s.Error != nil {
+		if len(s.Response) > 0 {
+			return errors.New("step has both error and response")
+		}
+
+		code, err := parseCode(s.Error.Code)
+		if err != nil {
+			return err
+		}
+		s.Error.code = code
+
+		return nil
+	}
+
+	// A step without a response responds with an empty message
+	s.response = resp
+	if len(s.Response) == 0 {
+		return nil
+	}
+
+	buf, err := json.Marshal(s.Response)
+	if err != nil {
+		return fmt.Errorf("marshalling response: %w", err)
+	}
+
+	if err := protojson.Unmarshal(buf, resp); err != nil {
+		return fmt.Errorf("unmarshalling response: %w", err)
+	}
+
+	return nil
+}
+
+// parseCode takes a code by number or by name in any case, e.g. ResourceExhausted, RESOURCE_EXHAUSTED or 8
+func parseCode(s string) (codes.Code, error) {
+
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return codes.Code(n), nil
+	}
+
+	name := strings.ToLower(strings.ReplaceAll(s, "_", ""))
+
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.ToLower(c.String()) == name {
+			return c, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown status code [%s]", s)
+}
@@ -0,0 +1,227 @@
+// Package mockserver serves GrpcRestService and GrpcRestMultipartService over gRPC and REST with responses
+// scripted by a Scenario. It's for client development without real servers. Requests are read but not processed.
+package mockserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	apiV2 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	apiV1 "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/api"
+
+	logger "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type (
+	Server struct {
+		scenario *Scenario
+		log      *logger.Logger
+
+		mu    sync.Mutex
+		calls map[string]int
+	}
+
+	serviceV1 struct {
+		apiV1.UnimplementedGrpcRestServiceServer
+		srv *Server
+	}
+
+	serviceV2 struct {
+		apiV2.UnimplementedGrpcRestMultipartServiceServer
+		srv *Server
+	}
+
+	// stepError is a scripted gRPC status with an optional HTTP status for REST
+	stepError struct {
+		st         *status.Status
+		httpStatus int
+	}
+)
+
+// New creates a mock server that logs calls to log
+func New(scenario *Scenario, log *logger.Logger) *Server {
+	return &Server{
+		scenario: scenario,
+		log:      log,
+		calls:    make(map[string]int),
+	}
+}
+
+// Calls is a number of calls of a method over both transports
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[method]
+}
+
+// NewGrpcServer registers both services
+func (s *Server) NewGrpcServer(opts ...grpc.ServerOption) *grpc.Server {
+
+	grpcServer := grpc.NewServer(opts...)
+	apiV1.RegisterGrpcRestServiceServer(grpcServer, &serviceV1{srv: s})
+	apiV2.RegisterGrpcRestMultipartServiceServer(grpcServer, &serviceV2{srv: s})
+
+	return grpcServer
+}
+
+// Run serves gRPC on grpcHost and REST on restHost until ctx is done
+func (s *Server) Run(ctx context.Context, grpcHost, restHost string, opts ...grpc.ServerOption) error {
+
+	grpcLis, err := net.Listen("tcp", grpcHost)
+	if err != nil {
+		return fmt.Errorf("listening grpc host: %w", err)
+	}
+
+	restLis, err := net.Listen("tcp", restHost)
+	if err != nil {
+		_ = grpcLis.Close()
+		return fmt.Errorf("listening rest host: %w", err)
+	}
+
+	grpcServer := s.NewGrpcServer(opts...)
+
+	handler, err := s.RestHandler()
+	if err != nil {
+		_ = grpcLis.Close()
+		_ = restLis.Close()
+		return err
+	}
+	httpServer := &http.Server{Handler: handler}
+
+	chanGrpcErr := make(chan error, 1)
+	go func() { chanGrpcErr <- grpcServer.Serve(grpcLis) }()
+
+	chanRestErr := make(chan error, 1)
+	go func() { chanRestErr <- httpServer.Serve(restLis) }()
+
+	s.log.Infof("mock server: grpc on %s, rest on %s", grpcLis.Addr(), restLis.Addr())
+
+	defer func() {
+		grpcServer.Stop()
+		_ = httpServer.Close()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil
+
+	case err := <-chanGrpcErr:
+		return fmt.Errorf("grpc handler: %w", err)
+
+	case err := <-chanRestErr:
+		return fmt.Errorf("rest handler: %w", err)
+	}
+}
+
+// play takes the next step of a method, waits for its delay and returns its response or error
+func (s *Server) play(ctx context.Context, method string) (proto.Message, error) {
+
+	s.mu.Lock()
+	call := s.calls[method]
+	s.calls[method]++
+	s.mu.Unlock()
+
+	steps := s.scenario.Methods[method]
+	if len(steps) == 0 {
+		s.log.Warnf("mock %s: no steps", method)
+		return nil, status.Errorf(codes.Unimplemented, "no steps for method %s in scenario", method)
+	}
+
+	n := call
+	if n >= len(steps) {
+		n = len(steps) - 1
+	}
+	step := steps[n]
+
+	s.log.Debugf("mock %s: call %d, step %d", method, call+1, n)
+
+	if step.Delay > 0 {
+		timer := time.NewTimer(step.Delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	if step.Error != nil {
+		return nil, &stepError{
+			st:         status.New(step.Error.code, step.Error.Message),
+			httpStatus: step.Error.HTTPStatus,
+		}
+	}
+
+	return proto.Clone(step.response), nil
+}
+
+func (e *stepError) Error() string {
+	return e.st.Err().Error()
+}
+
+// GRPCStatus lets gRPC and the gateway take the scripted status
+func (e *stepError) GRPCStatus() *status.Status {
+	return e.st
+}
+
+func (s *serviceV1) SayHello(ctx context.Context, _ *apiV1.SayHelloRequest) (*apiV1.SayHelloResponse, error) {
+
+	resp, err := s.srv.play(ctx, MethodV1SayHello)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*apiV1.SayHelloResponse), nil
+}
+
+func (s *serviceV2) SayHello(ctx context.Context, _ *apiV2.SayHelloRequest) (*apiV2.SayHelloResponse, error) {
+
+	resp, err := s.srv.play(ctx, MethodV2SayHello)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*apiV2.SayHelloResponse), nil
+}
+
+func (s *serviceV2) ListAttachments(ctx context.Context, _ *apiV2.ListAttachmentsRequest) (*apiV2.ListAttachmentsResponse, error) {
+
+	resp, err := s.srv.play(ctx, MethodV2ListAttachments)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*apiV2.ListAttachmentsResponse), nil
+}
+
+func (s *serviceV2) GetUpload(ctx context.Context, _ *apiV2.GetUploadRequest) (*apiV2.Upload, error) {
+
+	resp, err := s.srv.play(ctx, MethodV2GetUpload)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*apiV2.Upload), nil
+}
+
+// httpStatusError takes the scripted HTTP status of an error, if there is one
+func httpStatusError(err error) (int, bool) {
+
+	var stepErr *stepError
+	if errors.As(err, &stepErr) && stepErr.httpStatus > 0 {
+		return stepErr.httpStatus, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,60 @@
+package mockserver
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// TestServer is a mock server on loopback ports. Clients connect to it as to real servers.
+type TestServer struct {
+	*Server
+
+	// GrpcHost is the host:port of both gRPC services
+	GrpcHost string
+
+	// URL is the base URL of both REST APIs
+	URL string
+}
+
+// Start runs a mock server for a test. It's stopped when the test ends.
+func Start(t testing.TB, scenario *Scenario) *TestServer {
+	t.Helper()
+
+	s := TestServer{Server: New(scenario, logger.New())}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening grpc: %v", err)
+	}
+	s.GrpcHost = lis.Addr().String()
+
+	grpcServer := s.NewGrpcServer()
+	go func() { _ = grpcServer.Serve(lis) }()
+	t.Cleanup(grpcServer.Stop)
+
+	handler, err := s.RestHandler()
+	if err != nil {
+		t.Fatalf("creating rest handler: %v", err)
+	}
+
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	s.URL = ts.URL
+
+	return &s
+}
+
+// MustParse parses a YAML scenario or fails the test
+func MustParse(t testing.TB, doc string) *Scenario {
+	t.Helper()
+
+	sc, err := ParseScenario([]byte(doc))
+	if err != nil {
+		t.Fatalf("parsing scenario: %v", err)
+	}
+
+	return sc
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/audit"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
+
+	logger "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+)
+
+// adminStorage exposes the store to the admin server
+type adminStorage struct {
+	svc *service.Service
+}
+
+func (a adminStorage) StorageStats(context.Context) (*api.StorageStats, error) {
+
+	stats, err := a.svc.StorageStats()
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.StorageStats{
+		StoreLocation:   stats.StoreLocation,
+		Files:           stats.Files,
+		Bytes:           stats.Bytes,
+		TrashFiles:      stats.TrashFiles,
+		TrashBytes:      stats.TrashBytes,
+		QuarantineFiles: stats.QuarantineFiles,
+		QuarantineBytes: stats.QuarantineBytes,
+		Uploads:         stats.Uploads,
+		Attachments:     stats.Attachments,
+	}, nil
+}
+
+func (a adminStorage) RunRetention(_ context.Context, dryRun bool) (*api.RetentionReport, error) {
+
+	report, err := a.svc.EnforceRetention(time.Now(), dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.RetentionReport{
+		DryRun:         report.DryRun,
+		Trashed:        int64(report.Trashed),
+		TrashedBytes:   report.TrashedBytes,
+		Removed:        int64(report.Removed),
+		ReclaimedBytes: report.ReclaimedBytes,
+	}, nil
+}
+
+// auditAdmin records admin calls that change the server and calls without a valid token
+func auditAdmin(trail *audit.Trail, log *logger.Logger) admin.AuditFunc {
+	return func(_ context.Context, ev admin.Event) {
+
+		e := audit.Event{
+			Action:     audit.ActionAdmin,
+			Principal:  audit.ActorAdmin,
+			Target:     ev.Method,
+			RemoteAddr: ev.RemoteAddr,
+			Detail:     ev.Request,
+		}
+
+		if ev.Code == codes.Unauthenticated {
+			e.Action = audit.ActionAuthFailure
+			e.Principal = ""
+		}
+		if ev.Code != codes.OK {
+			e.Detail = strings.TrimSpace(ev.Code.String() + " " + e.Detail)
+		}
+
+		if err := trail.Record(e); err != nil {
+			log.Errorf("recording audit event: %v", err)
+		}
+	}
+}
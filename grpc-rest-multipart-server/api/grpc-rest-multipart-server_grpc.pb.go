@@ -23,6 +23,8 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type GrpcRestMultipartServiceClient interface {
 	SayHello(ctx context.Context, in *SayHelloRequest, opts ...grpc.CallOption) (*SayHelloResponse, error)
+	ListAttachments(ctx context.Context, in *ListAttachmentsRequest, opts ...grpc.CallOption) (*ListAttachmentsResponse, error)
+	GetUpload(ctx context.Context, in *GetUploadRequest, opts ...grpc.CallOption) (*Upload, error)
 }
 
 type grpcRestMultipartServiceClient struct {
@@ -42,11 +44,31 @@ func (c *grpcRestMultipartServiceClient) SayHello(ctx context.Context, in *SayHe
 	return out, nil
 }
 
+func (c *grpcRestMultipartServiceClient) ListAttachments(ctx context.Context, in *ListAttachmentsRequest, opts ...grpc.CallOption) (*ListAttachmentsResponse, error) {
+	out := new(ListAttachmentsResponse)
+	err := c.cc.Invoke(ctx, "/grpc_rest.v2.GrpcRestMultipartService/ListAttachments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *grpcRestMultipartServiceClient) GetUpload(ctx context.Context, in *GetUploadRequest, opts ...grpc.CallOption) (*Upload, error) {
+	out := new(Upload)
+	err := c.cc.Invoke(ctx, "/grpc_rest.v2.GrpcRestMultipartService/GetUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GrpcRestMultipartServiceServer is the server API for GrpcRestMultipartService service.
 // All implementations must embed UnimplementedGrpcRestMultipartServiceServer
 // for forward compatibility
 type GrpcRestMultipartServiceServer interface {
 	SayHello(context.Context, *SayHelloRequest) (*SayHelloResponse, error)
+	ListAttachments(context.Context, *ListAttachmentsRequest) (*ListAttachmentsResponse, error)
+	GetUpload(context.Context, *GetUploadRequest) (*Upload, error)
 	mustEmbedUnimplementedGrpcRestMultipartServiceServer()
 }
 
@@ -57,6 +79,12 @@ type UnimplementedGrpcRestMultipartServiceServer struct {
 func (UnimplementedGrpcRestMultipartServiceServer) SayHello(context.Context, *SayHelloRequest) (*SayHelloResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SayHello not implemented")
 }
+func (UnimplementedGrpcRestMultipartServiceServer) ListAttachments(context.Context, *ListAttachmentsRequest) (*ListAttachmentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAttachments not implemented")
+}
+func (UnimplementedGrpcRestMultipartServiceServer) GetUpload(context.Context, *GetUploadRequest) (*Upload, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUpload not implemented")
+}
 func (UnimplementedGrpcRestMultipartServiceServer) mustEmbedUnimplementedGrpcRestMultipartServiceServer() {
 }
 
@@ -89,6 +117,42 @@ func _GrpcRestMultipartService_SayHello_Handler(srv interface{}, ctx context.Con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GrpcRestMultipartService_ListAttachments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAttachmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GrpcRestMultipartServiceServer).ListAttachments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc_rest.v2.GrpcRestMultipartService/ListAttachments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GrpcRestMultipartServiceServer).ListAttachments(ctx, req.(*ListAttachmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GrpcRestMultipartService_GetUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GrpcRestMultipartServiceServer).GetUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc_rest.v2.GrpcRestMultipartService/GetUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GrpcRestMultipartServiceServer).GetUpload(ctx, req.(*GetUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // GrpcRestMultipartService_ServiceDesc is the grpc.ServiceDesc for GrpcRestMultipartService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -100,6 +164,14 @@ var GrpcRestMultipartService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SayHello",
 			Handler:    _GrpcRestMultipartService_SayHello_Handler,
 		},
+		{
+			MethodName: "ListAttachments",
+			Handler:    _GrpcRestMultipartService_ListAttachments_Handler,
+		},
+		{
+			MethodName: "GetUpload",
+			Handler:    _GrpcRestMultipartService_GetUpload_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "grpc-rest-multipart-server.proto",
@@ -0,0 +1,446 @@
+// Code generated by openapi-sdkgen from grpc-rest-multipart-server.swagger.json. DO NOT EDIT.
+
+// Package sdk is a REST client for GRPC REST Multipart Server (2.0.0).
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+type ProtobufAny map[string]interface{}
+
+type RpcStatus struct {
+	Code    int32         `json:"code,omitempty"`
+	Details []ProtobufAny `json:"details,omitempty"`
+	Message string        `json:"message,omitempty"`
+}
+
+type Attachment struct {
+	BinaryData  []byte `json:"binaryData,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+}
+
+type AttachmentInfo struct {
+	DeclaredType    string        `json:"declaredType,omitempty"`
+	Derived         []DerivedFile `json:"derived,omitempty"`
+	DetectedType    string        `json:"detectedType,omitempty"`
+	EncryptionKeyId string        `json:"encryptionKeyId,omitempty"`
+	FileName        string        `json:"fileName,omitempty"`
+	ScanSignature   string        `json:"scanSignature,omitempty"`
+	ScanStatus      string        `json:"scanStatus,omitempty"`
+	Sha256          string        `json:"sha256,omitempty"`
+	Size            int64         `json:"size,omitempty,string"`
+	StoredEncoding  string        `json:"storedEncoding,omitempty"`
+	StoredName      string        `json:"storedName,omitempty"`
+}
+
+type AttachmentRecord struct {
+	CreatedAt       string        `json:"createdAt,omitempty"`
+	DeclaredType    string        `json:"declaredType,omitempty"`
+	Derived         []DerivedFile `json:"derived,omitempty"`
+	DetectedType    string        `json:"detectedType,omitempty"`
+	EncryptionKeyId string        `json:"encryptionKeyId,omitempty"`
+	FileName        string        `json:"fileName,omitempty"`
+	Id              string        `json:"id,omitempty"`
+	Principal       string        `json:"principal,omitempty"`
+	ScanSignature   string        `json:"scanSignature,omitempty"`
+	ScanStatus      string        `json:"scanStatus,omitempty"`
+	Sha256          string        `json:"sha256,omitempty"`
+	Size            int64         `json:"size,omitempty,string"`
+	StoredEncoding  string        `json:"storedEncoding,omitempty"`
+	StoredName      string        `json:"storedName,omitempty"`
+	Title           string        `json:"title,omitempty"`
+	TrashedAt       string        `json:"trashedAt,omitempty"`
+	UploadId        string        `json:"uploadId,omitempty"`
+}
+
+type DerivedFile struct {
+	FileName string `json:"fileName,omitempty"`
+	Height   int32  `json:"height,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	Width    int32  `json:"width,omitempty"`
+}
+
+type ListAttachmentsResponse struct {
+	Attachments   []AttachmentRecord `json:"attachments,omitempty"`
+	NextPageToken string             `json:"nextPageToken,omitempty"`
+}
+
+type SayHelloRequest struct {
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Description string       `json:"description,omitempty"`
+	IntValue    int64        `json:"intValue,omitempty,string"`
+	Title       string       `json:"title,omitempty"`
+}
+
+type SayHelloResponse struct {
+	Attachments []AttachmentInfo `json:"attachments,omitempty"`
+	Response    string           `json:"response,omitempty"`
+	UploadId    string           `json:"uploadId,omitempty"`
+}
+
+type Upload struct {
+	Attachments []AttachmentRecord `json:"attachments,omitempty"`
+	CreatedAt   string             `json:"createdAt,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Id          string             `json:"id,omitempty"`
+	IntValue    int64              `json:"intValue,omitempty,string"`
+	Principal   string             `json:"principal,omitempty"`
+	Title       string             `json:"title,omitempty"`
+}
+
+// HttpDoer is satisfied by *http.Client. It allows to add retries, tracing etc.
+type HttpDoer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+type Client struct {
+	baseURL    string
+	httpClient HttpDoer
+}
+
+type Option func(*Client)
+
+// WithHttpClient sets a client that sends requests. http.DefaultClient is used by default.
+func WithHttpClient(httpClient HttpDoer) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func NewClient(baseURL string, opts ...Option) (*Client, error) {
+
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("bad base url [%s]: %w", baseURL, err)
+	}
+
+	c := Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return &c, nil
+}
+
+// Error is returned when server responds with an unexpected status code.
+type Error struct {
+	StatusCode int
+	Body       []byte
+
+	// Status is a decoded error response. It's nil if the body doesn't match the schema.
+	Status *RpcStatus
+}
+
+func (e *Error) Error() string {
+	if e.Status != nil {
+		return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Status.Message)
+	}
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, string(e.Body))
+}
+
+// FilePart is a file that is streamed to the server as a multipart part.
+type FilePart struct {
+	FileName    string
+	ContentType string
+	Reader      io.Reader
+}
+
+type ListAttachmentsParams struct {
+	UploadId       string
+	Principal      string
+	FileName       string
+	ContentType    string
+	ScanStatus     string
+	MinSize        int64
+	MaxSize        int64
+	CreatedAfter   string
+	CreatedBefore  string
+	OrderBy        string
+	Desc           bool
+	PageSize       int32
+	PageToken      string
+	IncludeTrashed bool
+}
+
+func (p *ListAttachmentsParams) values() url.Values {
+
+	v := url.Values{}
+
+	if len(p.UploadId) > 0 {
+		v.Set("uploadId", p.UploadId)
+	}
+
+	if len(p.Principal) > 0 {
+		v.Set("principal", p.Principal)
+	}
+
+	if len(p.FileName) > 0 {
+		v.Set("fileName", p.FileName)
+	}
+
+	if len(p.ContentType) > 0 {
+		v.Set("contentType", p.ContentType)
+	}
+
+	if len(p.ScanStatus) > 0 {
+		v.Set("scanStatus", p.ScanStatus)
+	}
+
+	if p.MinSize != 0 {
+		v.Set("minSize", fmt.Sprint(p.MinSize))
+	}
+
+	if p.MaxSize != 0 {
+		v.Set("maxSize", fmt.Sprint(p.MaxSize))
+	}
+
+	if len(p.CreatedAfter) > 0 {
+		v.Set("createdAfter", p.CreatedAfter)
+	}
+
+	if len(p.CreatedBefore) > 0 {
+		v.Set("createdBefore", p.CreatedBefore)
+	}
+
+	if len(p.OrderBy) > 0 {
+		v.Set("orderBy", p.OrderBy)
+	}
+
+	if p.Desc {
+		v.Set("desc", fmt.Sprint(p.Desc))
+	}
+
+	if p.PageSize != 0 {
+		v.Set("pageSize", fmt.Sprint(p.PageSize))
+	}
+
+	if len(p.PageToken) > 0 {
+		v.Set("pageToken", p.PageToken)
+	}
+
+	if p.IncludeTrashed {
+		v.Set("includeTrashed", fmt.Sprint(p.IncludeTrashed))
+	}
+
+	return v
+}
+
+// ListAttachments sends /v2/attachments
+// lists recorded attachments with filtering, sorting and cursor pagination
+func (c *Client) ListAttachments(ctx context.Context, params *ListAttachmentsParams) (*ListAttachmentsResponse, error) {
+
+	var reqBody io.Reader
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/v2/attachments", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	if params != nil {
+		req.URL.RawQuery = params.values().Encode()
+	}
+
+	resp := ListAttachmentsResponse{}
+	if err := c.do(req, &resp, 200); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// SayHello sends /v2/sayhello
+// sends hello object with a binary attachments
+func (c *Client) SayHello(ctx context.Context, body *SayHelloRequest) (*SayHelloResponse, error) {
+
+	var reqBody io.Reader
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+	reqBody = bytes.NewReader(buf)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v2/sayhello", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := SayHelloResponse{}
+	if err := c.do(req, &resp, 200, 201); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+type SayHelloMultipartBody struct {
+	Object     *SayHelloRequest
+	Attachment []FilePart
+}
+
+// SayHelloMultipart sends /v2/sayhello as multipart/form-data. Files are streamed, so the body isn't buffered in memory.
+// sends hello object with a binary attachments
+func (c *Client) SayHelloMultipart(ctx context.Context, body *SayHelloMultipartBody) (*SayHelloResponse, error) {
+
+	pr, pw := io.Pipe()
+	mpw := multipart.NewWriter(pw)
+
+	go func() {
+		_ = pw.CloseWithError(writeSayHelloMultipartBody(mpw, body))
+	}()
+
+	// The writer stops when the call returns, even if an HttpDoer hasn't read or closed the body
+	defer func() { _ = pr.Close() }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v2/sayhello", pr)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", mpw.FormDataContentType())
+
+	resp := SayHelloResponse{}
+	if err := c.do(req, &resp, 200, 201); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+func writeSayHelloMultipartBody(mpw *multipart.Writer, body *SayHelloMultipartBody) error {
+
+	if body.Object != nil {
+		if err := writeJsonPart(mpw, "object", "application/json", body.Object); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range body.Attachment {
+		if err := writeFilePart(mpw, "attachment", "application/octet-stream", f); err != nil {
+			return err
+		}
+	}
+
+	return mpw.Close()
+}
+
+// GetUpload sends /v2/uploads/{id}
+// gets a recorded upload with its attachments
+func (c *Client) GetUpload(ctx context.Context, id string) (*Upload, error) {
+
+	var reqBody io.Reader
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/v2/uploads/"+url.PathEscape(fmt.Sprint(id)), reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp := Upload{}
+	if err := c.do(req, &resp, 200); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+func (c *Client) do(req *http.Request, res interface{}, successCodes ...int) error {
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	for _, code := range successCodes {
+		if resp.StatusCode != code {
+			continue
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading error response: %w", err)
+	}
+
+	resErr := Error{
+		StatusCode: resp.StatusCode,
+		Body:       body,
+	}
+
+	status := RpcStatus{}
+	if err := json.Unmarshal(body, &status); err == nil {
+		resErr.Status = &status
+	}
+
+	return &resErr
+}
+
+func writeJsonPart(mpw *multipart.Writer, name, contentType string, v interface{}) error {
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, name))
+	h.Set("Content-Type", contentType)
+
+	w, err := mpw.CreatePart(h)
+	if err != nil {
+		return fmt.Errorf("creating part [%s]: %w", name, err)
+	}
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("writing part [%s]: %w", name, err)
+	}
+
+	return nil
+}
+
+func writeFilePart(mpw *multipart.Writer, name, contentType string, f FilePart) error {
+
+	if len(f.ContentType) > 0 {
+		contentType = f.ContentType
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, name, escapeQuotes(f.FileName)))
+	h.Set("Content-Type", contentType)
+
+	w, err := mpw.CreatePart(h)
+	if err != nil {
+		return fmt.Errorf("creating part [%s]: %w", name, err)
+	}
+
+	if _, err := io.Copy(w, f.Reader); err != nil {
+		return fmt.Errorf("writing part [%s]: %w", name, err)
+	}
+
+	return nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
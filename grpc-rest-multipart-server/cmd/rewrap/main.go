@@ -0,0 +1,253 @@
+// rewrap re-wraps data keys of encrypted attachments, including trashed ones, with the active master key,
+// so old master keys could be removed from config. File contents are not re-encrypted, key IDs of attachments
+// are updated in the metadata store. It runs offline against the store and takes the same config as the server:
+//
+//	CONFIG_FILE=config.yaml go run ./cmd/rewrap -dry-run
+//	go run ./cmd/rewrap -- -config config.yaml -encryption.active-key=2024-10
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/encryption"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/metadata"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/opts"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
+)
+
+func main() {
+
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report files that would be re-wrapped without changing them")
+	_ = flags.Parse(os.Args[1:])
+
+	if err := run(context.Background(), flags.Args(), *dryRun); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run takes server config flags that go after "--"
+func run(ctx context.Context, args []string, dryRun bool) error {
+
+	cfg, _, err := opts.Load(args)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	if !cfg.Encryption.Enabled {
+		return errors.New("encryption is not enabled in config")
+	}
+
+	keyring, err := encryption.New(cfg.Encryption)
+	if err != nil {
+		return fmt.Errorf("creating keyring: %w", err)
+	}
+
+	// The server keeps the database locked, so it should be stopped
+	store, err := metadata.Open(cfg.Metadata.FilePath(cfg.Service.StoreLocation))
+	if err != nil {
+		return fmt.Errorf("opening metadata store: %w", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	rw := rewrapper{
+		keyring: keyring,
+		store:   store,
+		active:  keyring.ActiveKeyID(),
+		dryRun:  dryRun,
+	}
+
+	if rw.attachments, err = attachmentsByPath(store); err != nil {
+		return fmt.Errorf("reading metadata: %w", err)
+	}
+
+	// Trash is hidden like quarantine and temporary files, but trashed files could be restored, so they are re-wrapped too
+	for _, dir := range []string{cfg.Service.StoreLocation, filepath.Join(cfg.Service.StoreLocation, service.TrashDir)} {
+		if err := rw.walk(ctx, dir); err != nil {
+			return fmt.Errorf("walking %s: %w", dir, err)
+		}
+	}
+
+	fmt.Printf("re-wrapped: %d, metadata updated: %d, failed: %d\n", rw.rewrapped, rw.updated, rw.failed)
+
+	if rw.failed > 0 {
+		return fmt.Errorf("%d files are not re-wrapped", rw.failed)
+	}
+
+	return nil
+}
+
+type rewrapper struct {
+	keyring *encryption.Keyring
+	store   *metadata.Store
+	active  string
+	dryRun  bool
+
+	// attachments are keyed by stored paths, trash keeps the same paths
+	attachments map[string]metadata.Attachment
+
+	rewrapped, updated, failed int
+}
+
+// attachmentsByPath indexes encrypted attachments by their file paths relative to the store
+func attachmentsByPath(store *metadata.Store) (map[string]metadata.Attachment, error) {
+
+	uploads, err := store.Uploads()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]metadata.Attachment)
+	for _, u := range uploads {
+		for _, at := range u.Attachments {
+			if len(at.StoredName) > 0 && len(at.KeyID) > 0 {
+				res[service.StoredPath(at)] = at
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// walk re-wraps encrypted files of dir and updates key IDs of their attachments. Hidden entries are skipped.
+func (rw *rewrapper) walk(ctx context.Context, dir string) error {
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() || !service.IsEncrypted(path) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if err := rw.rewrap(ctx, path, filepath.ToSlash(rel)); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			rw.failed++
+		}
+
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// rewrap re-wraps a file if it's wrapped by an old key. Metadata is updated after the file is replaced,
+// and also if a previous run was interrupted between the two.
+func (rw *rewrapper) rewrap(ctx context.Context, path, storedPath string) error {
+
+	keyID, err := readKeyID(path)
+	if err != nil {
+		return err
+	}
+
+	if keyID != rw.active {
+		if rw.dryRun {
+			fmt.Printf("%s: %s -> %s (dry run)\n", path, keyID, rw.active)
+			rw.rewrapped++
+			return nil
+		}
+
+		if err := rewrapFile(ctx, rw.keyring, path); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s: %s -> %s\n", path, keyID, rw.active)
+		rw.rewrapped++
+	}
+
+	at, ok := rw.attachments[storedPath]
+	if !ok || at.KeyID == rw.active || rw.dryRun {
+		return nil
+	}
+
+	err = rw.store.Update(at.UploadID, func(u *metadata.Upload) error {
+		for i := range u.Attachments {
+			if u.Attachments[i].ID == at.ID {
+				u.Attachments[i].KeyID = rw.active
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("updating metadata of [%s]: %w", at.ID, err)
+	}
+
+	rw.updated++
+
+	return nil
+}
+
+func readKeyID(path string) (string, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h, err := encryption.ReadHeader(f)
+	if err != nil {
+		return "", err
+	}
+
+	return h.KeyID, nil
+}
+
+// rewrapFile writes a copy with the new header next to the file and replaces the file with it,
+// so an interrupted run leaves either the old or the new version.
+func rewrapFile(ctx context.Context, keyring *encryption.Keyring, path string) error {
+
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.CreateTemp(filepath.Dir(path), ".rewrap-*")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+
+	tmpPath := out.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	if _, _, err := keyring.Rewrap(ctx, in, out); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("syncing temp file: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,326 @@
+// Package audit keeps an append-only trail of uploads, downloads, deletions, config reloads and admin calls.
+// The trail is JSON lines where every event has a hash of itself and of the previous event, so edits, removed
+// and reordered lines break the chain. Files are rotated by size and named by their first seq, the chain
+// continues across files. See Verify.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Actions of events
+const (
+	ActionUpload       = "upload"
+	ActionDownload     = "download"
+	ActionTrash        = "trash"
+	ActionDelete       = "delete"
+	ActionRollback     = "rollback"
+	ActionConfigReload = "config-reload"
+	ActionAdmin        = "admin"
+	ActionAuthFailure  = "auth-failure"
+)
+
+// ActorSystem is the principal of events that aren't caused by a request, e.g. retention and config reloads
+const ActorSystem = "system"
+
+// ActorAdmin is the principal of calls with the admin token
+const ActorAdmin = "admin"
+
+// DefaultFileName is the trail file in the store location if no path is configured
+const DefaultFileName = ".audit.jsonl"
+
+var ErrClosed = errors.New("audit trail is closed")
+
+type (
+	Config struct {
+		Enabled bool `json:"enabled" yaml:"enabled" default:"false"`
+
+		// Path is <store-location>/.audit.jsonl by default
+		Path string `json:"path" yaml:"path"`
+
+		// MaxSize is bytes of a file that is rotated to <path>.<first seq>. Rotated files are never removed.
+		MaxSize int64 `json:"maxSize" yaml:"max-size" split_words:"true" default:"104857600" validate:"gte=0"`
+	}
+
+	Event struct {
+		Seq       uint64    `json:"seq"`
+		Time      time.Time `json:"time"`
+		Action    string    `json:"action"`
+		Principal string    `json:"principal,omitempty"`
+		RequestID string    `json:"requestId,omitempty"`
+
+		// Target is a gRPC method of admin events
+		Target     string `json:"target,omitempty"`
+		RemoteAddr string `json:"remoteAddr,omitempty"`
+		UploadID   string `json:"uploadId,omitempty"`
+		Files      []File `json:"files,omitempty"`
+		Detail     string `json:"detail,omitempty"`
+
+		PrevHash string `json:"prevHash"`
+		Hash     string `json:"hash"`
+	}
+
+	File struct {
+		Name       string `json:"name,omitempty"`
+		StoredName string `json:"storedName,omitempty"`
+		Size       int64  `json:"size,omitempty"`
+		SHA256     string `json:"sha256,omitempty"`
+	}
+
+	// Trail appends events to the audit file. A nil trail records nothing, so it could be passed if audit is disabled.
+	Trail struct {
+		path    string
+		maxSize int64
+
+		mu       sync.Mutex
+		f        *os.File
+		size     int64
+		firstSeq uint64
+		seq      uint64
+		lastHash string
+	}
+)
+
+// Open continues the trail at path. It fails if the last event of the trail is damaged.
+func Open(config Config) (*Trail, error) {
+
+	t := Trail{
+		path:    config.Path,
+		maxSize: config.MaxSize,
+	}
+
+	// The chain continues from the last rotated file if the current one is new
+	files, err := Files(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(files) - 1; i >= 0; i-- {
+		last, err := lastEvent(files[i])
+		if err != nil {
+			return nil, fmt.Errorf("reading audit file [%s]: %w", files[i], err)
+		}
+		if last != nil {
+			if hash, err := last.hash(); err != nil || hash != last.Hash {
+				return nil, fmt.Errorf("%w: last event %d of [%s] doesn't match its hash", ErrBroken, last.Seq, files[i])
+			}
+
+			t.seq = last.Seq
+			t.lastHash = last.Hash
+			break
+		}
+	}
+
+	f, err := os.OpenFile(config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit file: %w", err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("opening audit file: %w", err)
+	}
+
+	t.f = f
+	t.size = fi.Size()
+
+	if first, err := firstEvent(config.Path); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("reading audit file: %w", err)
+	} else if first != nil {
+		t.firstSeq = first.Seq
+	}
+
+	return &t, nil
+}
+
+// Record sets seq, time and hashes of ev and appends it. The event is synced to disk before Record returns.
+func (t *Trail) Record(ev Event) error {
+
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.f == nil {
+		return ErrClosed
+	}
+
+	ev.Seq = t.seq + 1
+	ev.Time = time.Now().UTC()
+	ev.PrevHash = t.lastHash
+
+	hash, err := ev.hash()
+	if err != nil {
+		return err
+	}
+	ev.Hash = hash
+
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshalling audit event: %w", err)
+	}
+	line = append(line, '\n')
+
+	if t.maxSize > 0 && t.size > 0 && t.size+int64(len(line)) > t.maxSize {
+		if err := t.rotate(); err != nil {
+			return fmt.Errorf("rotating audit file: %w", err)
+		}
+	}
+
+	if _, err := t.f.Write(line); err != nil {
+		return fmt.Errorf("writing audit event: %w", err)
+	}
+
+	if err := t.f.Sync(); err != nil {
+		return fmt.Errorf("syncing audit file: %w", err)
+	}
+
+	if t.size == 0 {
+		t.firstSeq = ev.Seq
+	}
+
+	t.size += int64(len(line))
+	t.seq = ev.Seq
+	t.lastHash = ev.Hash
+
+	return nil
+}
+
+func (t *Trail) rotate() error {
+
+	if err := t.f.Close(); err != nil {
+		return err
+	}
+	t.f = nil
+
+	if err := os.Rename(t.path, rotatedName(t.path, t.firstSeq)); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_WRONLY|os.O_EXCL|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	t.f = f
+	t.size = 0
+
+	return nil
+}
+
+func (t *Trail) Close() error {
+
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.f == nil {
+		return nil
+	}
+
+	err := t.f.Close()
+	t.f = nil
+
+	return err
+}
+
+// hash is SHA-256 of the previous hash and the event without its own hash
+func (ev Event) hash() (string, error) {
+
+	ev.Hash = ""
+
+	buf, err := json.Marshal(ev)
+	if err != nil {
+		return "", fmt.Errorf("marshalling audit event: %w", err)
+	}
+
+	h := sha256.New()
+	_, _ = io.WriteString(h, ev.PrevHash)
+	_, _ = h.Write([]byte{'\n'})
+	_, _ = h.Write(buf)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func firstEvent(path string) (*Event, error) {
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	sc := newScanner(f)
+	if !sc.Scan() {
+		return nil, sc.Err()
+	}
+
+	ev := Event{}
+	if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+		return nil, fmt.Errorf("line 1: %w", err)
+	}
+
+	return &ev, nil
+}
+
+// lastEvent reads the last line of a file. Files are scanned, so the whole file is read.
+func lastEvent(path string) (*Event, error) {
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var last []byte
+	line := 0
+
+	sc := newScanner(f)
+	for sc.Scan() {
+		last = append(last[:0], sc.Bytes()...)
+		line++
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(last)) == 0 {
+		return nil, nil
+	}
+
+	ev := Event{}
+	if err := json.Unmarshal(last, &ev); err != nil {
+		return nil, fmt.Errorf("line %d: %w", line, err)
+	}
+
+	return &ev, nil
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	return sc
+}
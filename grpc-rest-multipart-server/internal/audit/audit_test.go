@@ -0,0 +1,125 @@
+package audit_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/audit"
+)
+
+// writeTrail records n events with rotation after every few of them and returns the trail path
+func writeTrail(t *testing.T, n int) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), audit.DefaultFileName)
+
+	trail, err := audit.Open(audit.Config{Path: path, MaxSize: 1024})
+	if err != nil {
+		t.Fatalf("opening trail: %v", err)
+	}
+
+	for i := 0; i < n; i++ {
+		err := trail.Record(audit.Event{
+			Action:    audit.ActionUpload,
+			Principal: "alice",
+			UploadID:  "upload",
+			Files:     []audit.File{{Name: "notes.txt", Size: int64(i), SHA256: "abc"}},
+		})
+		if err != nil {
+			t.Fatalf("recording event %d: %v", i, err)
+		}
+	}
+
+	if err := trail.Close(); err != nil {
+		t.Fatalf("closing trail: %v", err)
+	}
+
+	return path
+}
+
+func TestVerify(t *testing.T) {
+
+	path := writeTrail(t, 20)
+
+	// Reopened trail continues the chain
+	trail, err := audit.Open(audit.Config{Path: path, MaxSize: 1024})
+	if err != nil {
+		t.Fatalf("reopening trail: %v", err)
+	}
+	if err := trail.Record(audit.Event{Action: audit.ActionDelete, Principal: audit.ActorSystem}); err != nil {
+		t.Fatalf("recording event: %v", err)
+	}
+	_ = trail.Close()
+
+	report, err := audit.Verify(path)
+	if err != nil {
+		t.Fatalf("verifying: %v", err)
+	}
+
+	if len(report.Files) < 2 {
+		t.Errorf("expected rotated files, got %v", report.Files)
+	}
+	if report.Events != 21 || report.FirstSeq != 1 || report.LastSeq != 21 || !report.Anchored {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		tamper func(lines [][]byte) [][]byte
+	}{
+		{
+			name: "edited",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(`"alice"`), []byte(`"mallory"`), 1)
+				return lines
+			},
+		},
+		{
+			name: "removed",
+			tamper: func(lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+		},
+		{
+			name: "reordered",
+			tamper: func(lines [][]byte) [][]byte {
+				lines[0], lines[1] = lines[1], lines[0]
+				return lines
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			path := writeTrail(t, 20)
+
+			files, err := audit.Files(path)
+			if err != nil || len(files) < 2 {
+				t.Fatalf("listing files: %v %v", files, err)
+			}
+
+			// The second file is tampered, so the chain breaks in the middle of the trail
+			buf, err := os.ReadFile(files[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lines := tt.tamper(bytes.Split(bytes.TrimSpace(buf), []byte("\n")))
+
+			if err := os.WriteFile(files[1], append(bytes.Join(lines, []byte("\n")), '\n'), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := audit.Verify(path); !errors.Is(err, audit.ErrBroken) {
+				t.Errorf("expected broken chain, got %v", err)
+			}
+		})
+	}
+}
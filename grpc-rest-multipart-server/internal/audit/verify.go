@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrBroken = errors.New("audit chain is broken")
+
+// Report describes a verified trail
+type Report struct {
+	Files    []string
+	Events   int
+	FirstSeq uint64
+	LastSeq  uint64
+
+	// Anchored is true if the trail starts with the first event ever recorded. Otherwise the first
+	// event's previous hash can't be checked, e.g. because older files were removed.
+	Anchored bool
+}
+
+// Files lists rotated files of the trail at path in order and the current file if it exists
+func Files(path string) ([]string, error) {
+
+	matches, err := filepath.Glob(globEscape(path) + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("listing audit files: %w", err)
+	}
+
+	type rotated struct {
+		name     string
+		firstSeq uint64
+	}
+
+	var files []rotated
+	for _, m := range matches {
+		seq, err := strconv.ParseUint(strings.TrimPrefix(m, path+"."), 10, 64)
+		if err != nil {
+			continue
+		}
+		files = append(files, rotated{name: m, firstSeq: seq})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].firstSeq < files[j].firstSeq })
+
+	res := make([]string, 0, len(files)+1)
+	for _, f := range files {
+		res = append(res, f.name)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		res = append(res, path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("checking audit file: %w", err)
+	}
+
+	return res, nil
+}
+
+// Verify checks hashes and order of all events of the trail at path. Errors of a broken chain are ErrBroken.
+func Verify(path string) (Report, error) {
+
+	files, err := Files(path)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Files: files}
+
+	var prev *Event
+
+	for _, name := range files {
+		if err := verifyFile(name, &prev, &report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+func verifyFile(name string, prev **Event, report *Report) error {
+
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("opening audit file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	sc := newScanner(f)
+
+	for line := 1; sc.Scan(); line++ {
+
+		broken := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%w: %s line %d: %s", ErrBroken, name, line, fmt.Sprintf(format, args...))
+		}
+
+		ev := Event{}
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			return broken("%v", err)
+		}
+
+		hash, err := ev.hash()
+		if err != nil {
+			return err
+		}
+		if hash != ev.Hash {
+			return broken("event %d doesn't match its hash", ev.Seq)
+		}
+
+		if *prev == nil {
+			report.FirstSeq = ev.Seq
+			report.Anchored = ev.Seq == 1 && len(ev.PrevHash) == 0
+		} else {
+			if ev.Seq != (*prev).Seq+1 {
+				return broken("event %d follows event %d", ev.Seq, (*prev).Seq)
+			}
+			if ev.PrevHash != (*prev).Hash {
+				return broken("event %d doesn't chain to event %d", ev.Seq, (*prev).Seq)
+			}
+		}
+
+		*prev = &ev
+		report.LastSeq = ev.Seq
+		report.Events++
+	}
+
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("reading audit file [%s]: %w", name, err)
+	}
+
+	return nil
+}
+
+// globEscape escapes glob metacharacters of a path
+func globEscape(path string) string {
+
+	var b strings.Builder
+
+	for _, r := range path {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// rotatedName is a name of a rotated file that starts with firstSeq. Seqs are padded, so names sort in order.
+func rotatedName(path string, firstSeq uint64) string {
+	return fmt.Sprintf("%s.%012d", path, firstSeq)
+}
@@ -1,21 +1,70 @@
 package docs
 
 import (
-	"os"
+	"encoding/json"
+	"fmt"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/openapi"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
 
 	"github.com/swaggo/swag"
 )
 
+// InstanceName is the swag instance of the document. Servers have their own, so both could run in one process.
+const InstanceName = "grpc-rest-multipart-server"
+
+var Docs *openapi.Docs
+
 type s struct{}
 
 func (s *s) ReadDoc() string {
-	doc, err := os.ReadFile("./internal/docs/swagger.yaml")
+	return string(Docs.V2)
+}
+
+func init() {
+
+	// Document is embedded, so it can fail only if generated document is broken
+	doc, err := dropDefaultSuccess(api.SwaggerDoc)
 	if err != nil {
-		panic("cannot load swagger.yaml")
+		panic("cannot load grpc-rest-multipart-server.swagger.json: " + err.Error())
 	}
-	return string(doc)
+
+	docs, err := openapi.NewDocs(doc)
+	if err != nil {
+		panic("cannot load grpc-rest-multipart-server.swagger.json: " + err.Error())
+	}
+
+	Docs = docs
+
+	swag.Register(InstanceName, &s{})
 }
 
-func init() {
-	swag.Register(swag.Name, &s{})
+// dropDefaultSuccess removes "200" response that protoc-gen-openapiv2 always adds
+// from operations that declare "201". Gateway answers 201 for those (see grpc.createdResponse).
+func dropDefaultSuccess(doc []byte) ([]byte, error) {
+
+	var swagger map[string]interface{}
+	if err := json.Unmarshal(doc, &swagger); err != nil {
+		return nil, fmt.Errorf("unmarshalling document: %w", err)
+	}
+
+	paths, _ := swagger["paths"].(map[string]interface{})
+	for _, item := range paths {
+		ops, _ := item.(map[string]interface{})
+		for _, op := range ops {
+			opObj, _ := op.(map[string]interface{})
+			responses, _ := opObj["responses"].(map[string]interface{})
+
+			if _, ok := responses["201"]; ok {
+				delete(responses, "200")
+			}
+		}
+	}
+
+	res, err := json.MarshalIndent(swagger, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshalling document: %w", err)
+	}
+
+	return res, nil
 }
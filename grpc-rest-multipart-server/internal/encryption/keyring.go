@@ -0,0 +1,305 @@
+package encryption
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ProviderLocal = "local"
+	ProviderKMS   = "kms"
+
+	kmsKeyPrefix = "kms:"
+)
+
+var ErrUnknownKey = errors.New("unknown master key")
+
+type (
+	Config struct {
+		Enabled bool `json:"enabled" yaml:"enabled"`
+
+		// Provider wraps data keys of new files: local master keys or KMS. Files wrapped by
+		// any of them could be read as long as the key is configured.
+		Provider string `json:"provider" yaml:"provider" default:"local" validate:"oneof=local kms"`
+
+		// ActiveKey is ID of the local master key new files are wrapped with
+		ActiveKey string `json:"activeKey" yaml:"active-key" split_words:"true"`
+
+		// Keys are "<id>:<base64 of 32 bytes>" items. An item or its part after "<id>:" could be a secret reference.
+		// Old keys stay in the list until files are re-wrapped.
+		Keys []string `json:"keys" yaml:"keys" secret:"true"`
+
+		KMS KMSConfig `json:"kms" yaml:"kms"`
+
+		ChunkSize int `json:"chunkSize" yaml:"chunk-size" split_words:"true" default:"65536" validate:"gt=0"`
+	}
+
+	// KeyWrapper encrypts file data keys with a master key
+	KeyWrapper interface {
+		// Wrap returns ID of the master key and the wrapped data key
+		Wrap(ctx context.Context, dataKey []byte) (string, []byte, error)
+		Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+	}
+
+	// Keyring encrypts files with per-file data keys that are wrapped by the active master key
+	Keyring struct {
+		active    KeyWrapper
+		local     *LocalKeys
+		kms       *KMS
+		chunkSize int
+	}
+)
+
+func New(config Config) (*Keyring, error) {
+
+	local, err := NewLocalKeys(config.Keys, config.ActiveKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading master keys: %w", err)
+	}
+
+	k := Keyring{
+		local:     local,
+		chunkSize: config.ChunkSize,
+	}
+
+	if len(config.KMS.Address) > 0 {
+		k.kms = NewKMS(config.KMS)
+	}
+
+	switch config.Provider {
+	case ProviderKMS:
+		if k.kms == nil {
+			return nil, errors.New("kms address is not set")
+		}
+		k.active = k.kms
+
+	default:
+		if len(config.ActiveKey) == 0 {
+			return nil, errors.New("active key is not set")
+		}
+		k.active = local
+	}
+
+	if k.chunkSize <= 0 {
+		k.chunkSize = DefaultChunkSize
+	}
+
+	return &k, nil
+}
+
+// Encrypt writes the header with a new wrapped data key and returns a writer for the plaintext.
+// Close of the writer seals the last chunk and doesn't close w.
+func (k *Keyring) Encrypt(ctx context.Context, w io.Writer) (io.WriteCloser, string, error) {
+
+	dataKey := make([]byte, dataKeySize)
+	prefix := make([]byte, noncePrefixSize)
+
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, "", fmt.Errorf("generating data key: %w", err)
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, "", fmt.Errorf("generating nonce: %w", err)
+	}
+
+	keyID, wrapped, err := k.active.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("wrapping data key: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = writeHeader(w, Header{
+		KeyID:       keyID,
+		WrappedKey:  wrapped,
+		NoncePrefix: prefix,
+		ChunkSize:   k.chunkSize,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &streamWriter{
+		dst:    w,
+		aead:   aead,
+		prefix: prefix,
+		chunk:  k.chunkSize,
+	}, keyID, nil
+}
+
+// Decrypt returns a reader of the plaintext. It fails on read if the file is truncated or modified.
+func (k *Keyring) Decrypt(ctx context.Context, r io.Reader) (io.Reader, error) {
+
+	h, err := ReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := k.unwrap(ctx, h.KeyID, h.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{
+		src:    bufio.NewReaderSize(r, h.ChunkSize+aead.Overhead()),
+		aead:   aead,
+		prefix: h.NoncePrefix,
+		sealed: make([]byte, h.ChunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Rewrap copies an encrypted file with the data key wrapped by the active master key.
+// Chunks are copied as is. It returns IDs of the previous and the new master key.
+func (k *Keyring) Rewrap(ctx context.Context, r io.Reader, w io.Writer) (string, string, error) {
+
+	h, err := ReadHeader(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	dataKey, err := k.unwrap(ctx, h.KeyID, h.WrappedKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	prevKeyID := h.KeyID
+
+	h.KeyID, h.WrappedKey, err = k.active.Wrap(ctx, dataKey)
+	if err != nil {
+		return "", "", fmt.Errorf("wrapping data key: %w", err)
+	}
+
+	if err := writeHeader(w, h); err != nil {
+		return "", "", err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return "", "", fmt.Errorf("copying chunks: %w", err)
+	}
+
+	return prevKeyID, h.KeyID, nil
+}
+
+// ActiveKeyID is the key ID new files are wrapped with
+func (k *Keyring) ActiveKeyID() string {
+	switch a := k.active.(type) {
+	case *LocalKeys:
+		return a.active
+	case *KMS:
+		return kmsKeyPrefix + a.key
+	}
+
+	return ""
+}
+
+func (k *Keyring) unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+
+	var (
+		dataKey []byte
+		err     error
+	)
+
+	if strings.HasPrefix(keyID, kmsKeyPrefix) {
+		if k.kms == nil {
+			return nil, fmt.Errorf("%w: %s, kms is not configured", ErrUnknownKey, keyID)
+		}
+		dataKey, err = k.kms.Unwrap(ctx, keyID, wrapped)
+	} else {
+		dataKey, err = k.local.Unwrap(ctx, keyID, wrapped)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key: %w", err)
+	}
+
+	if len(dataKey) != dataKeySize {
+		return nil, fmt.Errorf("%w: bad data key size", ErrCorrupted)
+	}
+
+	return dataKey, nil
+}
+
+// LocalKeys wraps data keys with AES-256-GCM master keys from config
+type LocalKeys struct {
+	keys   map[string][]byte
+	active string
+}
+
+func NewLocalKeys(items []string, active string) (*LocalKeys, error) {
+
+	l := LocalKeys{
+		keys:   make(map[string][]byte, len(items)),
+		active: active,
+	}
+
+	for i, item := range items {
+		id, encoded, ok := strings.Cut(item, ":")
+		if !ok || len(id) == 0 || strings.HasPrefix(item, kmsKeyPrefix) {
+			return nil, fmt.Errorf("key %d: expected <id>:<base64 key>", i)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: decoding: %w", id, err)
+		}
+
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("key %s: expected %d bytes, got %d", id, dataKeySize, len(key))
+		}
+
+		l.keys[id] = key
+	}
+
+	if _, ok := l.keys[active]; len(active) > 0 && !ok {
+		return nil, fmt.Errorf("%w: active key %s is not in the list", ErrUnknownKey, active)
+	}
+
+	return &l, nil
+}
+
+func (l *LocalKeys) Wrap(_ context.Context, dataKey []byte) (string, []byte, error) {
+
+	aead, err := newAEAD(l.keys[l.active])
+	if err != nil {
+		return "", nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("generating nonce: %w", err)
+	}
+
+	return l.active, aead.Seal(nonce, nonce, dataKey, []byte(l.active)), nil
+}
+
+func (l *LocalKeys) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+
+	key, ok := l.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: wrapped key is too short", ErrCorrupted)
+	}
+
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+}
@@ -0,0 +1,122 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultKMSTimeout = 10 * time.Second
+
+type (
+	// KMSConfig points to a service with Vault Transit API: POST /v1/transit/{encrypt,decrypt}/<key>.
+	// Key versions are tracked by the service, so rotation there doesn't change the key ID.
+	KMSConfig struct {
+		Address string `json:"address" yaml:"address"`
+		Token   string `json:"token" yaml:"token" secret:"true"`
+		Key     string `json:"key" yaml:"key"`
+	}
+
+	KMS struct {
+		address    string
+		token      string
+		key        string
+		httpClient *http.Client
+	}
+
+	kmsResponse struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+			Plaintext  string `json:"plaintext"`
+		} `json:"data"`
+		Errors []string `json:"errors"`
+	}
+)
+
+func NewKMS(config KMSConfig) *KMS {
+	return &KMS{
+		address:    strings.TrimSuffix(config.Address, "/"),
+		token:      config.Token,
+		key:        config.Key,
+		httpClient: &http.Client{Timeout: defaultKMSTimeout},
+	}
+}
+
+func (k *KMS) Wrap(ctx context.Context, dataKey []byte) (string, []byte, error) {
+
+	resp, err := k.call(ctx, "encrypt", k.key, map[string]string{
+		"plaintext": base64.StdEncoding.EncodeToString(dataKey),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return kmsKeyPrefix + k.key, []byte(resp.Data.Ciphertext), nil
+}
+
+func (k *KMS) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+
+	resp, err := k.call(ctx, "decrypt", strings.TrimPrefix(keyID, kmsKeyPrefix), map[string]string{
+		"ciphertext": string(wrapped),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("decoding plaintext: %w", err)
+	}
+
+	return dataKey, nil
+}
+
+func (k *KMS) call(ctx context.Context, op, key string, body interface{}) (*kmsResponse, error) {
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/transit/%s/%s", k.address, op, key)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(buf))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if len(k.token) > 0 {
+		req.Header.Set("X-Vault-Token", k.token)
+	}
+
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kms %s: %w", op, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading kms response: %w", err)
+	}
+
+	var kmsResp kmsResponse
+	errJson := json.Unmarshal(respBody, &kmsResp)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kms %s responded %s: %s", op, resp.Status, strings.Join(kmsResp.Errors, "; "))
+	}
+
+	if errJson != nil {
+		return nil, fmt.Errorf("decoding kms response: %w", errJson)
+	}
+
+	return &kmsResp, nil
+}
@@ -0,0 +1,233 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted file layout:
+//
+//	magic "GRENC" | version byte | header length uint32 | JSON header | chunks
+//
+// Every chunk is up to header.ChunkSize bytes of plaintext sealed with AES-256-GCM by the file data key.
+// Nonce is a random prefix, the chunk counter and a flag of the last chunk, so chunks can't be
+// reordered or dropped, and a truncated file fails to decrypt. The header is not a part of
+// the authenticated data, so re-wrapping the data key doesn't touch the chunks.
+const (
+	magic   = "GRENC"
+	version = 1
+
+	dataKeySize     = 32
+	noncePrefixSize = 7
+	maxHeaderSize   = 64 * 1024
+
+	DefaultChunkSize = 64 * 1024
+)
+
+var (
+	ErrNotEncrypted = errors.New("file is not encrypted")
+	ErrCorrupted    = errors.New("encrypted file is corrupted")
+
+	chunkAAD = []byte{'G', 'R', 'E', 'N', 'C', version}
+)
+
+// Header is stored in plain text at the beginning of an encrypted file
+type Header struct {
+	KeyID       string `json:"kid"`
+	WrappedKey  []byte `json:"key"`
+	NoncePrefix []byte `json:"nonce"`
+	ChunkSize   int    `json:"chunk"`
+}
+
+func writeHeader(w io.Writer, h Header) error {
+
+	buf, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("marshalling header: %w", err)
+	}
+
+	prefix := make([]byte, len(magic)+1+4)
+	copy(prefix, magic)
+	prefix[len(magic)] = version
+	binary.BigEndian.PutUint32(prefix[len(magic)+1:], uint32(len(buf)))
+
+	if _, err := w.Write(append(prefix, buf...)); err != nil {
+		return fmt.Errorf("writing header: %w", err)
+	}
+
+	return nil
+}
+
+// ReadHeader reads the header and leaves r at the first chunk
+func ReadHeader(r io.Reader) (Header, error) {
+
+	prefix := make([]byte, len(magic)+1+4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return Header{}, fmt.Errorf("%w: %v", ErrNotEncrypted, err)
+	}
+
+	if string(prefix[:len(magic)]) != magic {
+		return Header{}, ErrNotEncrypted
+	}
+
+	if prefix[len(magic)] != version {
+		return Header{}, fmt.Errorf("unsupported encryption version %d", prefix[len(magic)])
+	}
+
+	size := binary.BigEndian.Uint32(prefix[len(magic)+1:])
+	if size > maxHeaderSize {
+		return Header{}, fmt.Errorf("%w: header is too big", ErrCorrupted)
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return Header{}, fmt.Errorf("%w: reading header: %v", ErrCorrupted, err)
+	}
+
+	var h Header
+	if err := json.Unmarshal(buf, &h); err != nil {
+		return Header{}, fmt.Errorf("%w: decoding header: %v", ErrCorrupted, err)
+	}
+
+	if len(h.NoncePrefix) != noncePrefixSize || h.ChunkSize <= 0 {
+		return Header{}, fmt.Errorf("%w: bad header", ErrCorrupted)
+	}
+
+	return h, nil
+}
+
+func newAEAD(dataKey []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+
+	nonce := make([]byte, noncePrefixSize+4+1)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+
+	return nonce
+}
+
+type streamWriter struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	chunk   int
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+// Write buffers a chunk. The last chunk is sealed on Close, so a full buffer is kept until more data comes.
+func (w *streamWriter) Write(p []byte) (int, error) {
+
+	if w.closed {
+		return 0, errors.New("write to closed encrypter")
+	}
+
+	n := len(p)
+	w.buf = append(w.buf, p...)
+
+	for len(w.buf) > w.chunk {
+		if err := w.seal(w.buf[:w.chunk], false); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[w.chunk:]
+	}
+
+	return n, nil
+}
+
+// Close seals the last chunk. It doesn't close the underlying writer.
+func (w *streamWriter) Close() error {
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	return w.seal(w.buf, true)
+}
+
+func (w *streamWriter) seal(plain []byte, last bool) error {
+
+	sealed := w.aead.Seal(nil, chunkNonce(w.prefix, w.counter, last), plain, chunkAAD)
+	w.counter++
+
+	if _, err := w.dst.Write(sealed); err != nil {
+		return fmt.Errorf("writing chunk: %w", err)
+	}
+
+	return nil
+}
+
+type streamReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	sealed  []byte
+	plain   []byte
+	done    bool
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+
+	return n, nil
+}
+
+func (r *streamReader) next() error {
+
+	n, err := io.ReadFull(r.src, r.sealed)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading chunk: %w", err)
+	}
+
+	// A short chunk or the end of the file right after a full one means the last chunk
+	last := n < len(r.sealed)
+	if !last {
+		if _, errPeek := r.src.Peek(1); errors.Is(errPeek, io.EOF) {
+			last = true
+		}
+	}
+
+	plain, err := r.aead.Open(r.sealed[:0:0], chunkNonce(r.prefix, r.counter, last), r.sealed[:n], chunkAAD)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d: %v", ErrCorrupted, r.counter, err)
+	}
+
+	r.counter++
+	r.plain = plain
+	r.done = last
+
+	return nil
+}
@@ -0,0 +1,487 @@
+package grpc_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api/sdk"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/grpc"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/naming"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/testserver"
+
+	goGrpc "google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files of the conformance suite")
+
+const conformanceDir = "testdata/conformance"
+
+type (
+	// fixture is a request in testdata/conformance/<name>.json. Attachment data is text or base64.
+	fixture struct {
+		Title       string              `json:"title"`
+		Description string              `json:"description"`
+		IntValue    int64               `json:"intValue"`
+		Attachments []fixtureAttachment `json:"attachments"`
+	}
+
+	fixtureAttachment struct {
+		FileName    string `json:"fileName"`
+		ContentType string `json:"contentType"`
+		Text        string `json:"text"`
+		Base64      []byte `json:"base64"`
+	}
+
+	// golden is what every transport should produce for a fixture: service input, response and stored files
+	golden struct {
+		Input    helloInput   `json:"input"`
+		Response string       `json:"response"`
+		Stored   []storedFile `json:"stored"`
+	}
+
+	helloInput struct {
+		Title       string            `json:"title"`
+		Description string            `json:"description"`
+		IntValue    int               `json:"intValue"`
+		Attachments []inputAttachment `json:"attachments"`
+	}
+
+	inputAttachment struct {
+		FileName    string `json:"fileName"`
+		ContentType string `json:"contentType"`
+		Size        int    `json:"size"`
+		SHA256      string `json:"sha256"`
+	}
+
+	// storedFile has the extension of the stored name only, names are generated
+	storedFile struct {
+		FileName       string `json:"fileName"`
+		DeclaredType   string `json:"declaredType"`
+		DetectedType   string `json:"detectedType"`
+		ScanStatus     string `json:"scanStatus"`
+		Size           int64  `json:"size"`
+		SHA256         string `json:"sha256"`
+		StoredExt      string `json:"storedExt"`
+		StoredEncoding string `json:"storedEncoding"`
+		ServedSHA256   string `json:"servedSha256"`
+	}
+
+	// transport sends a fixture and returns the response text
+	transport struct {
+		name string
+		send func(t *testing.T, srv *testserver.Server, fx fixture) string
+	}
+)
+
+func (a fixtureAttachment) data() []byte {
+	if a.Base64 != nil {
+		return a.Base64
+	}
+	return []byte(a.Text)
+}
+
+var transports = []transport{
+	{name: "grpc", send: sendGrpc},
+	{name: "gateway-json", send: sendGatewayJson(protojson.MarshalOptions{})},
+	{name: "gateway-json-proto-names", send: sendGatewayJson(protojson.MarshalOptions{UseProtoNames: true})},
+	{name: "gateway-multipart", send: sendMultipart(api.EndpointV2SayHello, protojson.MarshalOptions{})},
+	{name: "v2handler-multipart", send: sendMultipart("/v2/hello", protojson.MarshalOptions{})},
+	{name: "v2handler-multipart-proto-names", send: sendMultipart("/v2/hello", protojson.MarshalOptions{UseProtoNames: true})},
+	{name: "sdk-json", send: sendSdkJson},
+	{name: "sdk-multipart", send: sendSdkMultipart},
+}
+
+// TestConformance checks that every transport passes the same input to the service and stores the same files.
+// Run with -update to rewrite golden files from the gRPC transport.
+func TestConformance(t *testing.T) {
+
+	fixtures, err := filepath.Glob(filepath.Join(conformanceDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fileName := range fixtures {
+		if strings.HasSuffix(fileName, ".golden.json") {
+			continue
+		}
+
+		name := strings.TrimSuffix(filepath.Base(fileName), ".json")
+
+		t.Run(name, func(t *testing.T) {
+
+			fx := readFixture(t, fileName)
+			goldenFile := filepath.Join(conformanceDir, name+".golden.json")
+
+			rec := &recorder{}
+			srv := testserver.Start(t, testserver.WrapService(func(svc grpc.Service) grpc.Service {
+				rec.Service = svc
+				return rec
+			}))
+
+			var want *golden
+
+			if !*update {
+				want = readGolden(t, goldenFile)
+			}
+
+			for _, tr := range transports {
+
+				resp := tr.send(t, srv, fx)
+				got := rec.golden(t, srv, resp)
+
+				if want == nil {
+					want = &got
+					writeGolden(t, goldenFile, got)
+					continue
+				}
+
+				if g, w := marshalGolden(t, got), marshalGolden(t, *want); !bytes.Equal(g, w) {
+					t.Errorf("%s differs from %s\ngot:\n%s\nwant:\n%s", tr.name, goldenFile, g, w)
+				}
+			}
+		})
+	}
+}
+
+// recorder keeps the last ReactOnHello call
+type recorder struct {
+	grpc.Service
+
+	mu     sync.Mutex
+	input  *helloInput
+	result *service.HelloResult
+}
+
+func (r *recorder) ReactOnHello(
+	ctx context.Context,
+	title, description string, intValue int,
+	attachments []service.Attachment,
+) (*service.HelloResult, error) {
+
+	input := helloInput{
+		Title:       title,
+		Description: description,
+		IntValue:    intValue,
+		Attachments: make([]inputAttachment, 0, len(attachments)),
+	}
+
+	for _, at := range attachments {
+		input.Attachments = append(input.Attachments, inputAttachment{
+			FileName:    at.FileName,
+			ContentType: at.ContentType,
+			Size:        len(at.FileData),
+			SHA256:      digest(at.FileData),
+		})
+	}
+
+	res, err := r.Service.ReactOnHello(ctx, title, description, intValue, attachments)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.input = &input
+	r.result = res
+
+	return res, err
+}
+
+// golden takes the last call. Every transport should make exactly one.
+func (r *recorder) golden(t *testing.T, srv *testserver.Server, resp string) golden {
+	t.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.input == nil || r.result == nil {
+		t.Fatal("service wasn't called")
+	}
+
+	res := golden{
+		Input:    *r.input,
+		Response: resp,
+		Stored:   make([]storedFile, 0, len(r.result.Attachments)),
+	}
+
+	for _, at := range r.result.Attachments {
+		_, ext := naming.SplitExt(path.Base(at.StoredName))
+
+		res.Stored = append(res.Stored, storedFile{
+			FileName:       at.FileName,
+			DeclaredType:   at.DeclaredType,
+			DetectedType:   at.DetectedType,
+			ScanStatus:     at.ScanStatus,
+			Size:           at.Size,
+			SHA256:         at.SHA256,
+			StoredExt:      ext,
+			StoredEncoding: at.StoredEncoding,
+			ServedSHA256:   digest(srv.ReadStored(t, at.StoredName)),
+		})
+	}
+
+	r.input = nil
+	r.result = nil
+
+	return res
+}
+
+func apiRequest(fx fixture) *api.SayHelloRequest {
+
+	req := api.SayHelloRequest{
+		Title:       fx.Title,
+		Description: fx.Description,
+		IntValue:    fx.IntValue,
+	}
+
+	for _, at := range fx.Attachments {
+		req.Attachments = append(req.Attachments, &api.Attachment{
+			FileName:    at.FileName,
+			BinaryData:  at.data(),
+			ContentType: at.ContentType,
+		})
+	}
+
+	return &req
+}
+
+func sendGrpc(t *testing.T, srv *testserver.Server, fx fixture) string {
+	t.Helper()
+
+	conn, err := goGrpc.DialContext(context.Background(), testserver.Target, srv.DialOptions()...)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	resp, err := api.NewGrpcRestMultipartServiceClient(conn).SayHello(context.Background(), apiRequest(fx))
+	if err != nil {
+		t.Fatalf("grpc: %v", err)
+	}
+
+	return resp.Response
+}
+
+func sendGatewayJson(opts protojson.MarshalOptions) func(t *testing.T, srv *testserver.Server, fx fixture) string {
+	return func(t *testing.T, srv *testserver.Server, fx fixture) string {
+		t.Helper()
+
+		body, err := opts.Marshal(apiRequest(fx))
+		if err != nil {
+			t.Fatalf("marshalling request: %v", err)
+		}
+
+		return post(t, srv.URL+api.EndpointV2SayHello, "application/json", body)
+	}
+}
+
+// sendMultipart sends the object part as JSON without attachments and an "attachment" part per file.
+// Parts of attachments without a content type have no Content-Type header.
+func sendMultipart(endpoint string, opts protojson.MarshalOptions) func(t *testing.T, srv *testserver.Server, fx fixture) string {
+	return func(t *testing.T, srv *testserver.Server, fx fixture) string {
+		t.Helper()
+
+		object := apiRequest(fx)
+		object.Attachments = nil
+
+		objectJson, err := opts.Marshal(object)
+		if err != nil {
+			t.Fatalf("marshalling object: %v", err)
+		}
+
+		var body bytes.Buffer
+		mpw := multipart.NewWriter(&body)
+
+		if err := mpw.WriteField(grpc.PartObject, string(objectJson)); err != nil {
+			t.Fatalf("writing object: %v", err)
+		}
+
+		for _, at := range fx.Attachments {
+			h := make(textproto.MIMEHeader)
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, grpc.PartAttachment, at.FileName))
+			if len(at.ContentType) > 0 {
+				h.Set("Content-Type", at.ContentType)
+			}
+
+			w, err := mpw.CreatePart(h)
+			if err != nil {
+				t.Fatalf("creating part: %v", err)
+			}
+
+			if _, err := w.Write(at.data()); err != nil {
+				t.Fatalf("writing part: %v", err)
+			}
+		}
+
+		if err := mpw.Close(); err != nil {
+			t.Fatalf("closing multipart body: %v", err)
+		}
+
+		return post(t, srv.URL+endpoint, mpw.FormDataContentType(), body.Bytes())
+	}
+}
+
+func sendSdkJson(t *testing.T, srv *testserver.Server, fx fixture) string {
+	t.Helper()
+
+	req := sdk.SayHelloRequest{
+		Title:       fx.Title,
+		Description: fx.Description,
+		IntValue:    fx.IntValue,
+	}
+
+	for _, at := range fx.Attachments {
+		req.Attachments = append(req.Attachments, sdk.Attachment{
+			FileName:    at.FileName,
+			BinaryData:  at.data(),
+			ContentType: at.ContentType,
+		})
+	}
+
+	resp, err := sdkClient(t, srv).SayHello(context.Background(), &req)
+	if err != nil {
+		t.Fatalf("sdk: %v", err)
+	}
+
+	return resp.Response
+}
+
+func sendSdkMultipart(t *testing.T, srv *testserver.Server, fx fixture) string {
+	t.Helper()
+
+	body := sdk.SayHelloMultipartBody{
+		Object: &sdk.SayHelloRequest{
+			Title:       fx.Title,
+			Description: fx.Description,
+			IntValue:    fx.IntValue,
+		},
+	}
+
+	for _, at := range fx.Attachments {
+		body.Attachment = append(body.Attachment, sdk.FilePart{
+			FileName:    at.FileName,
+			ContentType: at.ContentType,
+			Reader:      bytes.NewReader(at.data()),
+		})
+	}
+
+	resp, err := sdkClient(t, srv).SayHelloMultipart(context.Background(), &body)
+	if err != nil {
+		t.Fatalf("sdk: %v", err)
+	}
+
+	return resp.Response
+}
+
+func sdkClient(t *testing.T, srv *testserver.Server) *sdk.Client {
+	t.Helper()
+
+	client, err := sdk.NewClient(srv.URL)
+	if err != nil {
+		t.Fatalf("creating sdk client: %v", err)
+	}
+
+	return client
+}
+
+// post sends a body and returns "response" of a successful JSON response
+func post(t *testing.T, url, contentType string, body []byte) string {
+	t.Helper()
+
+	resp, err := http.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("posting: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status %d: %s", resp.StatusCode, buf)
+	}
+
+	var res struct {
+		Response string `json:"response"`
+	}
+
+	if err := json.Unmarshal(buf, &res); err != nil {
+		t.Fatalf("unmarshalling response: %v", err)
+	}
+
+	return res.Response
+}
+
+func readFixture(t *testing.T, fileName string) fixture {
+	t.Helper()
+
+	buf, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fx := fixture{}
+	if err := json.Unmarshal(buf, &fx); err != nil {
+		t.Fatalf("unmarshalling fixture: %v", err)
+	}
+
+	return fx
+}
+
+func readGolden(t *testing.T, fileName string) *golden {
+	t.Helper()
+
+	buf, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("reading golden file, run with -update to create it: %v", err)
+	}
+
+	g := golden{}
+	if err := json.Unmarshal(buf, &g); err != nil {
+		t.Fatalf("unmarshalling golden file: %v", err)
+	}
+
+	return &g
+}
+
+func writeGolden(t *testing.T, fileName string, g golden) {
+	t.Helper()
+
+	if err := os.WriteFile(fileName, marshalGolden(t, g), 0644); err != nil {
+		t.Fatalf("writing golden file: %v", err)
+	}
+}
+
+func marshalGolden(t *testing.T, g golden) []byte {
+	t.Helper()
+
+	buf, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(buf, '\n')
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
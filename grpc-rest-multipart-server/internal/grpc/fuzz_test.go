@@ -0,0 +1,172 @@
+package grpc
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// fuzzBoundary is the boundary of seed bodies. Fuzzed bodies are sent with it too.
+const fuzzBoundary = "fuzzboundary"
+
+// fuzzLimits are small, so the fuzzer gets to the limits easily
+var fuzzLimits = service.UploadLimits{
+	MaxAttachments: 3,
+	MaxFileSize:    64,
+	MaxTotalSize:   128,
+}
+
+// seedBodies are multipart bodies with the fuzzBoundary. Malformed ones are in testdata/fuzz.
+var seedBodies = []string{
+	"--fuzzboundary\r\n" +
+		"Content-Disposition: form-data; name=\"object\"\r\n\r\n" +
+		"{\"title\": \"hello\", \"description\": \"desc\", \"intValue\": \"42\"}\r\n" +
+		"--fuzzboundary\r\n" +
+		"Content-Disposition: form-data; name=\"attachment\"; filename=\"notes.txt\"\r\n" +
+		"Content-Type: text/plain\r\n\r\n" +
+		"plain text notes\r\n" +
+		"--fuzzboundary--\r\n",
+
+	"--fuzzboundary\r\n" +
+		"Content-Disposition: form-data; name=\"object\"\r\n\r\n" +
+		"{\"title\": \"hello\", \"int_value\": 7}\r\n" +
+		"--fuzzboundary--\r\n",
+
+	"--fuzzboundary\r\n" +
+		"Content-Disposition: form-data; name=\"attachment\"; filename=\"../../etc/passwd\"\r\n\r\n" +
+		"root:x:0:0\r\n" +
+		"--fuzzboundary\r\n" +
+		"Content-Disposition: form-data; name=\"attachment\"; filename=\"..\\\\..\\\\boot.ini\"\r\n\r\n" +
+		"[boot]\r\n" +
+		"--fuzzboundary--\r\n",
+
+	"preamble\r\n" +
+		"--fuzzboundary\r\n" +
+		"Content-Disposition: form-data; name=\"attachment\"; filename*=UTF-8''r%C3%A9sum%C3%A9.txt\r\n\r\n" +
+		"\r\n" +
+		"--fuzzboundary--\r\n",
+}
+
+func FuzzReadHelloForm(f *testing.F) {
+
+	for _, body := range seedBodies {
+		f.Add([]byte(body))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+
+		req := httptest.NewRequest(http.MethodPost, "/v2/hello", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "multipart/form-data; boundary="+fuzzBoundary)
+
+		apiReq, err := readHelloForm(req, fuzzLimits)
+		if err != nil {
+			return
+		}
+
+		checkLimits(t, apiReq)
+	})
+}
+
+func FuzzMultipartDecode(f *testing.F) {
+
+	for _, body := range seedBodies {
+		f.Add([]byte(body))
+	}
+
+	gwmux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(MIMEMultipartForm, NewMultipartMarshaler()),
+		runtime.WithErrorHandler(gatewayErrorHandler),
+	)
+
+	uploadLimits := func() service.UploadLimits { return fuzzLimits }
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+
+		// The gateway decodes what withMultipartForm has read
+		var decoded *api.SayHelloRequest
+		handler := withMultipartForm(gwmux, uploadLimits, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			decoded = &api.SayHelloRequest{}
+			if err := NewMultipartMarshaler().NewDecoder(r.Body).Decode(decoded); err != nil {
+				t.Fatalf("decoding read request: %v", err)
+			}
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/v2/sayhello", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "multipart/form-data; boundary="+fuzzBoundary)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if decoded == nil {
+			if rec.Code != http.StatusBadRequest && rec.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("rejected with %d: %s", rec.Code, rec.Body)
+			}
+			return
+		}
+
+		for _, at := range decoded.Attachments {
+			if at == nil {
+				t.Fatal("nil attachment")
+			}
+		}
+
+		checkLimits(t, decoded)
+	})
+}
+
+func FuzzUnmarshalObject(f *testing.F) {
+
+	for _, object := range []string{
+		`{"title": "hello", "description": "desc", "intValue": "42"}`,
+		`{"title": "hello", "int_value": 9007199254740993}`,
+		`{"attachments": [{"fileName": "x.txt", "binaryData": "eA=="}]}`,
+		`{"unknown": {"nested": [1, 2, 3]}}`,
+		`{"intValue": 1e400}`,
+		`{"title": "\ud800"}`,
+		`[]`,
+		`{`,
+	} {
+		f.Add([]byte(object))
+	}
+
+	f.Fuzz(func(t *testing.T, object []byte) {
+
+		req := api.SayHelloRequest{}
+		if err := unmarshalObject(object, &req); err != nil {
+			return
+		}
+
+		// Attachments come in their own parts only
+		if len(req.Attachments) > 0 {
+			t.Fatalf("object has set attachments: %v", req.Attachments)
+		}
+	})
+}
+
+func checkLimits(t *testing.T, req *api.SayHelloRequest) {
+	t.Helper()
+
+	if len(req.Attachments) > fuzzLimits.MaxAttachments {
+		t.Fatalf("%d attachments are read, limit is %d", len(req.Attachments), fuzzLimits.MaxAttachments)
+	}
+
+	var total int64
+
+	for _, at := range req.Attachments {
+		size := int64(len(at.BinaryData))
+		if size > fuzzLimits.MaxFileSize {
+			t.Fatalf("attachment [%s] of %d bytes is read, limit is %d", at.FileName, size, fuzzLimits.MaxFileSize)
+		}
+		total += size
+	}
+
+	if total > fuzzLimits.MaxTotalSize {
+		t.Fatalf("attachments of %d bytes are read, limit is %d", total, fuzzLimits.MaxTotalSize)
+	}
+}
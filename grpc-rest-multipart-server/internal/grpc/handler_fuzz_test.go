@@ -0,0 +1,147 @@
+package grpc_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/grpc"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/naming"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/testserver"
+)
+
+// quoteEscaper escapes file names the way mime/multipart does
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// FuzzV2Handler sends hostile file names, objects and truncated bodies to /v2/hello.
+// Whatever is sent, the server responds with a client error or stores files under StoreDir only.
+func FuzzV2Handler(f *testing.F) {
+
+	for _, seed := range []struct {
+		fileName string
+		object   string
+		data     []byte
+		cut      uint16
+	}{
+		{fileName: "notes.txt", object: `{"title": "hello", "intValue": "42"}`, data: []byte("plain text notes\n")},
+		{fileName: "../../outside.txt", object: `{"title": "traversal"}`, data: []byte("x")},
+		{fileName: `..\..\outside.txt`, object: `{}`, data: []byte("x")},
+		{fileName: "/tmp/absolute.txt", object: `{}`, data: []byte("x")},
+		{fileName: "..", object: `{}`, data: []byte("x")},
+		{fileName: ".hidden", object: `{}`, data: []byte("x")},
+		{fileName: "‮txt.exe", object: `{}`, data: []byte("MZ")},
+		{fileName: "bad\r\nContent-Type: text/html", object: `{}`, data: []byte("<html>")},
+		{fileName: "notes.txt", object: `{"title": `, data: []byte("x")},
+		{fileName: "notes.txt", object: `{"intValue": "not a number"}`, data: []byte("x")},
+		{fileName: "notes.txt", object: `{}`, data: bytes.Repeat([]byte("x"), 1024), cut: 100},
+		{fileName: strings.Repeat("a", 1000) + ".txt", object: `{}`, data: []byte("x")},
+	} {
+		f.Add(seed.fileName, seed.object, seed.data, seed.cut)
+	}
+
+	srv := testserver.Start(f, testserver.WithYAML("service: {limits: {max-attachments: 3, max-file-size: 4096, max-total-size: 8192}}"))
+
+	f.Fuzz(func(t *testing.T, fileName, object string, data []byte, cut uint16) {
+
+		body, contentType := hostileBody(t, fileName, object, data)
+
+		// Non-zero cut truncates the body
+		if cut > 0 && int(cut) < len(body) {
+			body = body[:cut]
+		}
+
+		resp, err := http.Post(srv.URL+"/v2/hello", contentType, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("posting: %v", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		buf, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("reading response: %v", err)
+		}
+
+		switch resp.StatusCode {
+
+		case http.StatusCreated:
+			var res grpc.SayHelloResponse
+			if err := json.Unmarshal(buf, &res); err != nil {
+				t.Fatalf("unmarshalling response: %v", err)
+			}
+
+			for _, at := range res.Attachments {
+				if !naming.IsValidStoredName(at.StoredName) {
+					t.Fatalf("file [%q] is stored as [%q]", at.FileName, at.StoredName)
+				}
+			}
+
+		case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+
+		default:
+			t.Fatalf("status %d: %s", resp.StatusCode, buf)
+		}
+
+		checkStore(t, srv)
+	})
+}
+
+// hostileBody builds a multipart body with an object and an attachment. The file name is escaped
+// the way clients do it, but it can still break the part header.
+func hostileBody(t *testing.T, fileName, object string, data []byte) ([]byte, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	mpw := multipart.NewWriter(&body)
+
+	if err := mpw.WriteField(grpc.PartObject, object); err != nil {
+		t.Fatalf("writing object: %v", err)
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, grpc.PartAttachment, quoteEscaper.Replace(fileName)))
+
+	w, err := mpw.CreatePart(h)
+	if err != nil {
+		t.Fatalf("creating part: %v", err)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("writing part: %v", err)
+	}
+
+	if err := mpw.Close(); err != nil {
+		t.Fatalf("closing multipart body: %v", err)
+	}
+
+	return body.Bytes(), mpw.FormDataContentType()
+}
+
+// checkStore makes sure that nothing is written next to the store and that all stored files have valid names
+func checkStore(t *testing.T, srv *testserver.Server) {
+	t.Helper()
+
+	entries, err := os.ReadDir(filepath.Dir(srv.StoreDir))
+	if err != nil {
+		t.Fatalf("reading store parent: %v", err)
+	}
+
+	for _, e := range entries {
+		if e.Name() != filepath.Base(srv.StoreDir) {
+			t.Fatalf("[%s] is written out of the store", e.Name())
+		}
+	}
+
+	for _, name := range srv.StoredFiles(t) {
+		if !naming.IsValidStoredName(name) {
+			t.Fatalf("[%s] is stored with an invalid name", name)
+		}
+	}
+}
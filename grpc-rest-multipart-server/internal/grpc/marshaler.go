@@ -0,0 +1,131 @@
+package grpc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/limits"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	MIMEMultipartForm = "multipart/form-data"
+	MIMEOctetStream   = "application/octet-stream"
+
+	PartObject     = "object"
+	PartAttachment = "attachment"
+)
+
+// MultipartMarshaler lets the gateway accept multipart/form-data requests.
+// The "object" part is decoded as a JSON message and every "attachment" part becomes an api.Attachment.
+// The parts are read by withMultipartForm in front of the gateway. Responses are encoded as JSON.
+type MultipartMarshaler struct {
+	runtime.JSONPb
+}
+
+func NewMultipartMarshaler() *MultipartMarshaler {
+	return &MultipartMarshaler{
+		JSONPb: runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		},
+	}
+}
+
+func (m *MultipartMarshaler) NewDecoder(r io.Reader) runtime.Decoder {
+	return runtime.DecoderFunc(func(v interface{}) error {
+		return m.decode(r, v)
+	})
+}
+
+// decode reads the request that withMultipartForm has read from the multipart body
+func (m *MultipartMarshaler) decode(r io.Reader, v interface{}) error {
+
+	req, ok := v.(*api.SayHelloRequest)
+	if !ok {
+		return fmt.Errorf("multipart is not supported for %T", v)
+	}
+
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading request: %w", err)
+	}
+
+	if err := proto.Unmarshal(buf, req); err != nil {
+		return fmt.Errorf("unmarshalling request: %w", err)
+	}
+
+	return nil
+}
+
+// objectUnmarshaler accepts proto and JSON field names, e.g. int_value and intValue, and int64 as numbers or strings
+var objectUnmarshaler = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// unmarshalObject decodes the "object" part into req. Both multipart handlers use it, so they accept the same JSON.
+// Attachments are coming in separate parts only.
+func unmarshalObject(buf []byte, req *api.SayHelloRequest) error {
+
+	object := api.SayHelloRequest{}
+	if err := objectUnmarshaler.Unmarshal(buf, &object); err != nil {
+		return fmt.Errorf("unmarshalling request object: %w", err)
+	}
+
+	req.Title = object.Title
+	req.Description = object.Description
+	req.IntValue = object.IntValue
+
+	return nil
+}
+
+// withMultipartForm reads multipart requests before the gateway, which buffers whole bodies.
+// The form is streamed with upload limits like in V2Handler, so an oversized upload is rejected with 413
+// as soon as it's over a limit. The gateway gets the read request as a protobuf message.
+func withMultipartForm(gwmux *runtime.ServeMux, uploadLimits func() service.UploadLimits, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != MIMEMultipartForm {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		apiReq, err := readHelloForm(r, uploadLimits())
+		if err != nil {
+			code := codes.InvalidArgument
+			if errors.Is(err, service.ErrTooLarge) || limits.TooLarge(r) {
+				code = codes.ResourceExhausted
+			}
+
+			_, outbound := runtime.MarshalerForRequest(gwmux, r)
+			gatewayErrorHandler(r.Context(), gwmux, outbound, w, r, status.Error(code, err.Error()))
+			return
+		}
+
+		buf, err := proto.Marshal(apiReq)
+		if err != nil {
+			_, outbound := runtime.MarshalerForRequest(gwmux, r)
+			gatewayErrorHandler(r.Context(), gwmux, outbound, w, r, status.Errorf(codes.Internal, "marshalling request: %v", err))
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(buf))
+		r.ContentLength = int64(len(buf))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *MultipartMarshaler) ContentType(_ interface{}) string {
+	return "application/json"
+}
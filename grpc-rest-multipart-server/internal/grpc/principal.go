@@ -0,0 +1,108 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/gateway"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// PrincipalConfig tells whose X-Principal header and x-principal metadata are trusted.
+// Any caller could set them, so they are dropped unless an authenticating proxy is configured.
+type PrincipalConfig struct {
+	// TrustHeader takes the caller identity from requests. Without TrustedProxies it's taken from any peer,
+	// so it's enabled only when the server is reachable through the proxy alone.
+	TrustHeader bool `json:"trustHeader" yaml:"trust-header" split_words:"true"`
+
+	// TrustedProxies are CIDRs of proxies that set the identity, e.g. 10.0.0.0/8
+	TrustedProxies []string `json:"trustedProxies" yaml:"trusted-proxies" split_words:"true" validate:"dive,cidr"`
+}
+
+// principalTrust drops the caller identity of requests that aren't from a trusted proxy
+type principalTrust struct {
+	enabled bool
+	proxies []netip.Prefix
+}
+
+func newPrincipalTrust(config PrincipalConfig) (principalTrust, error) {
+
+	p := principalTrust{enabled: config.TrustHeader}
+
+	for _, cidr := range config.TrustedProxies {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return principalTrust{}, fmt.Errorf("parsing trusted proxy %s: %w", cidr, err)
+		}
+		p.proxies = append(p.proxies, prefix.Masked())
+	}
+
+	return p, nil
+}
+
+// trusted tells if a peer with the host:port address could set the caller identity
+func (p principalTrust) trusted(addr string) bool {
+
+	if !p.enabled {
+		return false
+	}
+
+	if len(p.proxies) == 0 {
+		return true
+	}
+
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return false
+	}
+
+	for _, prefix := range p.proxies {
+		if prefix.Contains(ap.Addr().Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Middleware drops X-Principal of untrusted REST requests. Handlers and the gateway see trusted identities only.
+func (p principalTrust) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if !p.trusted(r.RemoteAddr) {
+			r.Header.Del(headerPrincipal)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryInterceptor drops x-principal metadata of calls that are neither from the gateway nor from a trusted proxy.
+// The gateway forwards X-Principal that Middleware has kept.
+func (p principalTrust) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok || len(md.Get(mdPrincipal)) == 0 || gateway.IsGatewayCall(ctx) {
+			return handler(ctx, req)
+		}
+
+		var addr string
+		if pr, ok := peer.FromContext(ctx); ok {
+			addr = pr.Addr.String()
+		}
+
+		if !p.trusted(addr) {
+			md = md.Copy()
+			md.Delete(mdPrincipal)
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,131 @@
+package grpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/testserver"
+
+	goGrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TestPrincipalTrust checks whose caller identity is recorded with uploads. REST requests of the test come
+// from 127.0.0.1, gRPC calls come over bufconn that has no IP address.
+func TestPrincipalTrust(t *testing.T) {
+
+	tests := []struct {
+		name string
+		yaml string
+
+		// Expected principals of uploads
+		rest string
+		grpc string
+	}{
+		{
+			name: "not trusted by default",
+			rest: service.PrincipalAnonymous,
+			grpc: service.PrincipalAnonymous,
+		},
+		{
+			name: "trusted from any peer",
+			yaml: "grpc: {principal: {trust-header: true}}",
+			rest: "alice",
+			grpc: "alice",
+		},
+		{
+			name: "trusted proxy",
+			yaml: "grpc: {principal: {trust-header: true, trusted-proxies: [127.0.0.0/8]}}",
+			rest: "alice",
+			grpc: service.PrincipalAnonymous,
+		},
+		{
+			name: "other proxy",
+			yaml: "grpc: {principal: {trust-header: true, trusted-proxies: [10.0.0.0/8]}}",
+			rest: service.PrincipalAnonymous,
+			grpc: service.PrincipalAnonymous,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			srv := testserver.Start(t, testserver.WithYAML(tt.yaml))
+
+			conn, err := goGrpc.DialContext(context.Background(), testserver.Target, srv.DialOptions()...)
+			if err != nil {
+				t.Fatalf("dialing: %v", err)
+			}
+			defer func() { _ = conn.Close() }()
+
+			client := api.NewGrpcRestMultipartServiceClient(conn)
+
+			principal := func(uploadID string) string {
+				t.Helper()
+
+				upload, err := client.GetUpload(context.Background(), &api.GetUploadRequest{Id: uploadID})
+				if err != nil {
+					t.Fatalf("getting upload: %v", err)
+				}
+				return upload.Principal
+			}
+
+			restID := postHello(t, srv.URL, http.Header{"X-Principal": {"alice"}})
+			if got := principal(restID); got != tt.rest {
+				t.Errorf("REST principal is %q, expected %q", got, tt.rest)
+			}
+
+			// The gateway doesn't forward the identity in its metadata header
+			metadataID := postHello(t, srv.URL, http.Header{"Grpc-Metadata-X-Principal": {"alice"}})
+			if got := principal(metadataID); got != service.PrincipalAnonymous {
+				t.Errorf("principal of metadata header is %q", got)
+			}
+
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "x-principal", "alice")
+			resp, err := client.SayHello(ctx, &api.SayHelloRequest{Title: "principal"})
+			if err != nil {
+				t.Fatalf("calling SayHello: %v", err)
+			}
+			if got := principal(resp.UploadId); got != tt.grpc {
+				t.Errorf("gRPC principal is %q, expected %q", got, tt.grpc)
+			}
+		})
+	}
+}
+
+func postHello(t *testing.T, url string, header http.Header) string {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url+api.EndpointV2SayHello, strings.NewReader(`{"title": "principal"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("posting: %v", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	buf, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("status is %d: %s", res.StatusCode, buf)
+	}
+
+	var resp struct {
+		UploadID string `json:"uploadId"`
+	}
+	if err := json.Unmarshal(buf, &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	return resp.UploadID
+}
@@ -2,16 +2,29 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"io"
 
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/media"
+	meta "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/metadata"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/naming"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
 
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
+// mdPrincipal is metadata with the caller identity that is set by an authenticating proxy
+const mdPrincipal = "x-principal"
+
 type Service interface {
-	ReactOnHello(context.Context, string, string, int, []service.Attachment) (string, error)
+	ReactOnHello(context.Context, string, string, int, []service.Attachment) (*service.HelloResult, error)
+	OpenAttachment(ctx context.Context, storedName string) (io.ReadCloser, error)
+	ListAttachments(ctx context.Context, q meta.Query) (*meta.Page, error)
+	GetUpload(ctx context.Context, id string) (*meta.Upload, error)
+	UploadLimits() service.UploadLimits
 }
 
 type Resolver struct {
@@ -29,12 +42,89 @@ func (r *Resolver) SayHello(ctx context.Context, req *api.SayHelloRequest) (*api
 
 	attachments := FromApiAttachments(req.Attachments)
 
-	resp, err := r.svc.ReactOnHello(ctx, req.Title, req.Description, int(req.IntValue), attachments)
+	res, err := r.svc.ReactOnHello(withPrincipal(ctx), req.Title, req.Description, int(req.IntValue), attachments)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, status.Error(errorCode(err), err.Error())
 	}
 
 	return &api.SayHelloResponse{
-		Response: resp,
+		UploadId:    res.UploadID,
+		Response:    res.Response,
+		Attachments: ToApiAttachmentInfos(res.Attachments),
 	}, nil
 }
+
+func (r *Resolver) ListAttachments(ctx context.Context, req *api.ListAttachmentsRequest) (*api.ListAttachmentsResponse, error) {
+
+	page, err := r.svc.ListAttachments(ctx, FromApiListAttachmentsRequest(req))
+	if err != nil {
+		return nil, status.Error(errorCode(err), err.Error())
+	}
+
+	return &api.ListAttachmentsResponse{
+		Attachments:   ToApiAttachmentRecords(page.Attachments),
+		NextPageToken: page.NextPageToken,
+	}, nil
+}
+
+func (r *Resolver) GetUpload(ctx context.Context, req *api.GetUploadRequest) (*api.Upload, error) {
+
+	upload, err := r.svc.GetUpload(ctx, req.Id)
+	if err != nil {
+		return nil, status.Error(errorCode(err), err.Error())
+	}
+
+	return ToApiUpload(upload), nil
+}
+
+// withPrincipal takes the caller from x-principal metadata unless a transport has already set it.
+// The gateway forwards X-Principal header as this metadata.
+func withPrincipal(ctx context.Context) context.Context {
+
+	if _, ok := service.PrincipalFromContext(ctx); ok {
+		return ctx
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(mdPrincipal); len(values) > 0 {
+		return service.WithPrincipal(ctx, values[0])
+	}
+
+	return ctx
+}
+
+// OpenAttachment reads a stored file for REST downloads
+func (r *Resolver) OpenAttachment(ctx context.Context, storedName string) (io.ReadCloser, error) {
+	return r.svc.OpenAttachment(ctx, storedName)
+}
+
+// UploadLimits are checked by REST handlers that stream request bodies
+func (r *Resolver) UploadLimits() service.UploadLimits {
+	return r.svc.UploadLimits()
+}
+
+func errorCode(err error) codes.Code {
+
+	switch {
+	case errors.Is(err, service.ErrContentTypeNotAllowed),
+		errors.Is(err, service.ErrContentTypeMismatch),
+		errors.Is(err, media.ErrMalformed),
+		errors.Is(err, naming.ErrInvalidName),
+		errors.Is(err, meta.ErrBadQuery):
+		return codes.InvalidArgument
+
+	case errors.Is(err, meta.ErrNotFound):
+		return codes.NotFound
+
+	case errors.Is(err, service.ErrFileExists):
+		return codes.AlreadyExists
+
+	case errors.Is(err, service.ErrTooLarge):
+		return codes.ResourceExhausted
+
+	case errors.Is(err, service.ErrScanFailed):
+		return codes.Unavailable
+	}
+
+	return codes.Internal
+}
@@ -2,130 +2,407 @@ package grpc
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
-	"net/http/httputil"
-	"time"
-
+	"net/textproto"
+	"path"
+	"path/filepath"
+	"strings"
+
+	// compress also registers gzip and zstd gRPC compressors, the server responds with the compressor of a request
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/compress"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/gateway"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/limits"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/openapi"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/docs"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
 
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/labstack/echo/v4"
-	logger "github.com/sirupsen/logrus"
 	echoSwagger "github.com/swaggo/echo-swagger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
-func (s *Server) ServeHttp(_ context.Context) error {
-	e := echo.New()
+func (s *Server) ServeHttp(ctx context.Context) error {
+
+	conn, err := s.dialGrpc(ctx)
+	if err != nil {
+		return err
+	}
 
-	e.POST("/v2/*", s.V2Handler)
-	e.GET("/swagger/*", echoSwagger.WrapHandler)
+	e, err := s.newEcho(conn)
+	if err != nil {
+		return err
+	}
 
 	return e.Start(s.restHost)
 }
 
-func (s *Server) V2Handler(ec echo.Context) error {
+// RestHandler serves REST API over conn to the gRPC server. It lets REST run without listening on RestHost.
+func (s *Server) RestHandler(conn *grpc.ClientConn) (http.Handler, error) {
+	return s.newEcho(conn)
+}
 
-	req := ec.Request()
+func (s *Server) newEcho(conn *grpc.ClientConn) (*echo.Echo, error) {
+
+	gwmux, err := s.buildGateway(conn)
+	if err != nil {
+		return nil, fmt.Errorf("building gateway: %w", err)
+	}
 
-	fmt.Println()
-	fmt.Printf("----------- NEW REQUEST (%v) -----------\n", time.Now().UTC().Format(time.RFC3339))
-	fmt.Println()
+	e := echo.New()
+	if s.tracker != nil {
+		e.Server.ConnState = s.tracker.ConnState
+	}
 
-	bodyDump, err := httputil.DumpRequest(req, true)
-	if err != nil && !errors.Is(err, io.EOF) {
-		logger.Errorf("dumping request: %v", err)
-		return ec.NoContent(http.StatusInternalServerError)
+	e.Use(echo.WrapMiddleware(s.principals.Middleware))
+	e.Use(echo.WrapMiddleware(func(h http.Handler) http.Handler {
+		return logging.Middleware(s.log, h)
+	}))
+	e.Use(echo.WrapMiddleware(s.tracker.Middleware))
+	e.Use(echo.WrapMiddleware(func(h http.Handler) http.Handler {
+		return compress.Middleware(int64(s.limits.MaxRequestSize), limits.Middleware(int64(s.limits.MaxRequestSize), h))
+	}))
+	if s.recorder != nil {
+		e.Use(echo.WrapMiddleware(s.recorder.Middleware))
 	}
+	e.Use(echo.WrapMiddleware(s.dumper.Middleware))
+
+	// Gateway handles /v2/sayhello both for JSON and multipart bodies, V2Handler is the older multipart route
+	e.POST(api.EndpointV2SayHello, echo.WrapHandler(withMultipartForm(gwmux, s.resolver.UploadLimits, s.withGatewayTimeout(gwmux))))
+	e.POST(pathV2Hello, s.V2Handler)
+	e.GET(pathV2Files, s.FileHandler)
+	e.GET(pathV2Attachments, echo.WrapHandler(s.withGatewayTimeout(gwmux)))
+	e.GET(pathV2Upload, echo.WrapHandler(s.withGatewayTimeout(gwmux)))
+	e.GET(pathDebugVars, echo.WrapHandler(expvar.Handler()))
+	e.GET("/swagger/*", echoSwagger.EchoWrapHandler(echoSwagger.InstanceName(docs.InstanceName)))
+	e.GET(openapi.PathSwagger, echo.WrapHandler(docs.Docs.SwaggerHandler()))
+	e.GET(openapi.PathOpenAPI, echo.WrapHandler(docs.Docs.OpenAPIHandler()))
+
+	return e, nil
+}
+
+const (
+	pathV2Hello = "/v2/hello"
 
-	// For small requests it might be interesting to see the whole body
-	// fmt.Println(string(bodyDump))
+	// pathV2Files matches stored names with shard subdirectories, e.g. /v2/files/2024/10/19/<id>.jpg
+	pathV2Files       = "/v2/files/*"
+	pathV2Attachments = "/v2/attachments"
+	pathV2Upload      = "/v2/uploads/:id"
+
+	// pathDebugVars publishes expvar metrics, e.g. retention counters
+	pathDebugVars = "/debug/vars"
+
+	// maxObjectSize limits the JSON part of a multipart request
+	maxObjectSize = 64 << 10
+
+	// headerPrincipal is the caller identity that is set by an authenticating proxy
+	headerPrincipal = "X-Principal"
+)
+
+// inlineTypes are served with their content type. Other stored files are served as attachments.
+var inlineTypes = map[string]struct{}{
+	"image/png":  {},
+	"image/jpeg": {},
+	"image/gif":  {},
+	"image/webp": {},
+}
 
-	// For big requests the size will be enough
-	fmt.Println("bodyLen:", len(bodyDump))
+// FileHandler downloads a stored attachment or its thumbnail by the stored name.
+// Files compressed at rest are decompressed, and the response is compressed per Accept-Encoding.
+func (s *Server) FileHandler(ec echo.Context) error {
 
-	fmt.Println()
-	fmt.Println()
+	name := ec.Param("*")
 
-	mpf, err := ec.MultipartForm()
+	ctx := ec.Request().Context()
+	log := logging.FromContext(ctx, s.log)
+
+	if principal := ec.Request().Header.Get(headerPrincipal); len(principal) > 0 {
+		ctx = service.WithPrincipal(ctx, principal)
+	}
+
+	f, err := s.resolver.OpenAttachment(ctx, name)
+	if errors.Is(err, service.ErrNotFound) {
+		return ec.NoContent(http.StatusNotFound)
+	}
 	if err != nil {
-		logger.Errorf("getting multipart form: %v", err)
+		log.Errorf("opening attachment: %v", err)
 		return ec.NoContent(http.StatusInternalServerError)
 	}
+	defer func() { _ = f.Close() }()
+
+	// The extension comes from the client, so only passive types are served inline. Anything else, e.g. html or svg,
+	// is downloaded, otherwise it would run as active content on the API origin.
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	disposition := "inline"
+	if _, ok := inlineTypes[contentType]; !ok {
+		contentType = echo.MIMEOctetStream
+		disposition = "attachment"
+	}
 
-	apiReq := api.SayHelloRequest{}
+	header := ec.Response().Header()
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": path.Base(name)}))
+	header.Set(echo.HeaderContentSecurityPolicy, "default-src 'none'; sandbox")
 
-	// Processing only the first object
+	// Encrypted files are authenticated by chunks, so a damaged file fails in the middle of the response
+	if err := ec.Stream(http.StatusOK, contentType, f); err != nil {
+		log.Errorf("streaming attachment [%s]: %v", name, err)
+		return err
+	}
 
-	object := mpf.Value["object"]
-	if len(object) > 0 {
+	return nil
+}
 
-		if errJson := json.Unmarshal([]byte(object[0]), &apiReq); errJson != nil {
-			logger.Errorf("unmarshalling request object: %v", errJson)
-			return ec.NoContent(http.StatusInternalServerError)
-		}
+// GatewayDialOptions are options of the gateway connection to the gRPC server
+func (s *Server) GatewayDialOptions() []grpc.DialOption {
+	return append(gateway.DialOptions(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(s.limits.CallOptions()...),
+	)
+}
+
+func (s *Server) dialGrpc(ctx context.Context) (*grpc.ClientConn, error) {
+
+	ctxDial, cancel := context.WithTimeout(ctx, s.GatewayTimeout())
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctxDial, s.host, append(s.GatewayDialOptions(), grpc.WithBlock())...)
+	if err != nil {
+		return nil, fmt.Errorf("dialing grpc connection: %w", err)
 	}
 
-	// Processing all attachments
+	return conn, nil
+}
 
-	attachments := mpf.File["attachment"]
-	apiAttachments := make([]*api.Attachment, 0, len(attachments))
+func (s *Server) buildGateway(conn *grpc.ClientConn) (*runtime.ServeMux, error) {
 
-	for _, at := range attachments {
+	gwmux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(MIMEMultipartForm, NewMultipartMarshaler()),
+		runtime.WithForwardResponseOption(createdResponse),
+		runtime.WithIncomingHeaderMatcher(incomingHeader),
+		runtime.WithErrorHandler(gatewayErrorHandler),
+	)
 
-		fileBuf, errRead := readPartFile(attachments[0])
-		if errRead != nil {
-			logger.Errorf("getting attachment from multiparts: %v", errRead)
-			return ec.NoContent(http.StatusInternalServerError)
-		}
+	err := api.RegisterGrpcRestMultipartServiceHandler(context.Background(), gwmux, conn)
+	if err != nil {
+		return nil, fmt.Errorf("registering gateway handler: %w", err)
+	}
 
-		apiAttachment := api.Attachment{
-			FileName:   at.Filename,
-			BinaryData: fileBuf,
-		}
+	return gwmux, nil
+}
 
-		apiAttachments = append(apiAttachments, &apiAttachment)
+// createdResponse keeps the status code that V2Handler and REST clients use for a successful hello.
+func createdResponse(_ context.Context, w http.ResponseWriter, msg proto.Message) error {
+
+	if _, ok := msg.(*api.SayHelloResponse); ok {
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	return nil
+}
+
+// incomingHeader forwards X-Principal and the request ID to gRPC metadata in addition to the default headers.
+// Grpc-Metadata-X-Principal isn't forwarded, so the caller identity comes from X-Principal that is checked by principalTrust.
+func incomingHeader(key string) (string, bool) {
+	switch textproto.CanonicalMIMEHeaderKey(key) {
+	case headerPrincipal:
+		return mdPrincipal, true
+	case runtime.MetadataHeaderPrefix + headerPrincipal:
+		return "", false
+	case logging.HeaderRequestID:
+		return logging.MetadataRequestID, true
+	}
+
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+func (s *Server) V2Handler(ec echo.Context) error {
+
+	req := ec.Request()
+	log := logging.FromContext(req.Context(), s.log)
+
+	apiReq, err := readHelloForm(req, s.resolver.UploadLimits())
+	if errors.Is(err, service.ErrTooLarge) || limits.TooLarge(req) {
+		log.Warnf("rejecting request: %v", err)
+		return ec.JSON(http.StatusRequestEntityTooLarge, echo.Map{"message": err.Error()})
+	}
+	if err != nil {
+		// Malformed bodies and objects are client errors, the same as in the gateway
+		log.Warnf("reading multipart form: %v", err)
+		return ec.JSON(http.StatusBadRequest, echo.Map{"message": err.Error()})
 	}
 
-	apiReq.Attachments = apiAttachments
+	ctx := logging.WithEntry(context.Background(), log)
+	if principal := req.Header.Get(headerPrincipal); len(principal) > 0 {
+		ctx = service.WithPrincipal(ctx, principal)
+	}
 
-	resolverResp, err := s.resolver.SayHello(context.Background(), &apiReq)
+	resolverResp, err := s.resolver.SayHello(ctx, apiReq)
 	if err != nil {
-		logger.Errorf("resolver: %v", err)
-		return ec.NoContent(http.StatusInternalServerError)
+		log.Errorf("resolver: %v", err)
+
+		st := status.Convert(err)
+		if st.Code() == codes.Internal {
+			return ec.NoContent(http.StatusInternalServerError)
+		}
+
+		return ec.JSON(httpStatusFromCode(st.Code()), echo.Map{"message": st.Message()})
 	}
 
 	resp := SayHelloResponse{
-		Response: resolverResp.Response,
+		UploadID:    resolverResp.UploadId,
+		Response:    resolverResp.Response,
+		Attachments: make([]AttachmentInfo, 0, len(resolverResp.Attachments)),
+	}
+
+	for _, at := range resolverResp.Attachments {
+		info := AttachmentInfo{
+			FileName:       at.FileName,
+			DeclaredType:   at.DeclaredType,
+			DetectedType:   at.DetectedType,
+			Size:           at.Size,
+			SHA256:         at.Sha256,
+			ScanStatus:     at.ScanStatus,
+			ScanSignature:  at.ScanSignature,
+			StoredName:     at.StoredName,
+			StoredEncoding: at.StoredEncoding,
+			KeyID:          at.EncryptionKeyId,
+			Derived:        make([]DerivedFile, 0, len(at.Derived)),
+		}
+
+		for _, d := range at.Derived {
+			info.Derived = append(info.Derived, DerivedFile{
+				Kind:     d.Kind,
+				FileName: d.FileName,
+				Width:    d.Width,
+				Height:   d.Height,
+			})
+		}
+
+		resp.Attachments = append(resp.Attachments, info)
 	}
 
 	ec.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 
 	if err := ec.JSON(http.StatusCreated, resp); err != nil {
-		logger.Errorf("creating response: %v", err)
+		log.Errorf("creating response: %v", err)
 		return ec.NoContent(http.StatusInternalServerError)
 	}
 
 	return nil
 }
 
-func readPartFile(fh *multipart.FileHeader) ([]byte, error) {
+// readHelloForm streams a multipart body. Attachments are checked against limits while they are read,
+// so an oversized upload is rejected without reading the rest of it.
+func readHelloForm(req *http.Request, lim service.UploadLimits) (*api.SayHelloRequest, error) {
 
-	f, err := fh.Open()
+	mpr, err := req.MultipartReader()
 	if err != nil {
-		return nil, fmt.Errorf("opening file: %w", err)
+		return nil, fmt.Errorf("reading multipart body: %w", err)
 	}
 
-	buf := make([]byte, fh.Size)
+	apiReq := api.SayHelloRequest{}
+	hasObject := false
+	var total int64
 
-	n, err := f.Read(buf)
-	if err != nil {
-		return nil, fmt.Errorf("reading file: %w", err)
+	for {
+		part, errPart := mpr.NextPart()
+		if errors.Is(errPart, io.EOF) {
+			return &apiReq, nil
+		}
+		if errPart != nil {
+			return nil, fmt.Errorf("reading part: %w", errPart)
+		}
+
+		switch part.FormName() {
+
+		case PartObject:
+			// Processing only the first object
+			if hasObject {
+				continue
+			}
+
+			buf, errRead := readPart(part, maxObjectSize)
+			if errRead != nil {
+				return nil, fmt.Errorf("reading request object: %w", errRead)
+			}
+			if len(buf) > maxObjectSize {
+				return nil, fmt.Errorf("%w: request object is over %d bytes", service.ErrTooLarge, maxObjectSize)
+			}
+
+			if err := unmarshalObject(buf, &apiReq); err != nil {
+				return nil, err
+			}
+			hasObject = true
+
+		case PartAttachment:
+			if err := lim.CheckCount(len(apiReq.Attachments) + 1); err != nil {
+				return nil, err
+			}
+
+			buf, errRead := readPart(part, lim.MaxFileSize)
+			if errRead != nil {
+				return nil, fmt.Errorf("reading attachment [%s]: %w", part.FileName(), errRead)
+			}
+			if err := lim.CheckFile(part.FileName(), int64(len(buf))); err != nil {
+				return nil, err
+			}
+
+			total += int64(len(buf))
+			if err := lim.CheckTotal(total); err != nil {
+				return nil, err
+			}
+
+			apiReq.Attachments = append(apiReq.Attachments, &api.Attachment{
+				FileName:    part.FileName(),
+				BinaryData:  buf,
+				ContentType: part.Header.Get(echo.HeaderContentType),
+			})
+		}
+	}
+}
+
+// readPart reads a part up to one byte over maxSize, so the caller could tell that it's too large
+// without reading the rest. Zero maxSize doesn't limit it.
+func readPart(part *multipart.Part, maxSize int64) ([]byte, error) {
+
+	if maxSize <= 0 {
+		return io.ReadAll(part)
+	}
+
+	return io.ReadAll(io.LimitReader(part, maxSize+1))
+}
+
+// gatewayErrorHandler responds 413 to bodies that are cut by the size limit and to requests
+// that are over upload limits. The gateway reports them as 400 and 429 otherwise.
+func gatewayErrorHandler(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+
+	if limits.TooLarge(r) || status.Code(err) == codes.ResourceExhausted {
+		err = &runtime.HTTPStatusError{
+			HTTPStatus: http.StatusRequestEntityTooLarge,
+			Err:        status.Error(codes.ResourceExhausted, status.Convert(err).Message()),
+		}
+	}
+
+	runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+}
 
+func httpStatusFromCode(code codes.Code) int {
+	if code == codes.ResourceExhausted {
+		return http.StatusRequestEntityTooLarge
 	}
 
-	return buf[:n], nil
+	return runtime.HTTPStatusFromCode(code)
 }
@@ -0,0 +1,219 @@
+package grpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/testserver"
+
+	goGrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestFileHandlerHeaders checks that uploaded active content isn't served as such from the API origin
+func TestFileHandlerHeaders(t *testing.T) {
+
+	srv := testserver.Start(t)
+
+	conn, err := goGrpc.DialContext(context.Background(), testserver.Target, srv.DialOptions()...)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	tests := []struct {
+		fileName    string
+		data        []byte
+		contentType string
+		disposition string
+	}{
+		{
+			fileName:    "x.html",
+			data:        []byte("<html><script>alert(document.cookie)</script></html>"),
+			contentType: "application/octet-stream",
+			disposition: "attachment",
+		},
+		{
+			fileName:    "x.svg",
+			data:        []byte(`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"></svg>`),
+			contentType: "application/octet-stream",
+			disposition: "attachment",
+		},
+		{
+			fileName:    "x.png",
+			data:        []byte("\x89PNG\r\n\x1a\n"),
+			contentType: "image/png",
+			disposition: "inline",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+
+			resp, err := api.NewGrpcRestMultipartServiceClient(conn).SayHello(context.Background(), &api.SayHelloRequest{
+				Title:       "files",
+				Attachments: []*api.Attachment{{FileName: tt.fileName, BinaryData: tt.data}},
+			})
+			if err != nil {
+				t.Fatalf("uploading: %v", err)
+			}
+			if len(resp.Attachments) != 1 || len(resp.Attachments[0].StoredName) == 0 {
+				t.Fatalf("attachment is not stored: %v", resp)
+			}
+
+			res, err := http.Get(srv.URL + "/v2/files/" + resp.Attachments[0].StoredName)
+			if err != nil {
+				t.Fatalf("downloading: %v", err)
+			}
+			defer func() { _ = res.Body.Close() }()
+			_, _ = io.Copy(io.Discard, res.Body)
+
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("unexpected status %d", res.StatusCode)
+			}
+
+			if got := res.Header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type is %q, expected %q", got, tt.contentType)
+			}
+			if got := res.Header.Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("X-Content-Type-Options is %q", got)
+			}
+			if got := res.Header.Get("Content-Disposition"); !strings.HasPrefix(got, tt.disposition) {
+				t.Errorf("Content-Disposition is %q, expected %s", got, tt.disposition)
+			}
+		})
+	}
+}
+
+// TestGatewayMultipartBoundary checks that the gateway takes the boundary from Content-Type, so a preamble
+// with a delimiter-like line doesn't break the body
+func TestGatewayMultipartBoundary(t *testing.T) {
+
+	srv := testserver.Start(t)
+
+	var body bytes.Buffer
+	body.WriteString("--not-the-boundary\r\nThis is a preamble.\r\n")
+
+	mpw := multipart.NewWriter(&body)
+	if err := mpw.WriteField("object", `{"title": "preamble"}`); err != nil {
+		t.Fatal(err)
+	}
+	w, err := mpw.CreateFormFile("attachment", "notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("notes"))
+	if err := mpw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		status      int
+	}{
+		{name: "boundary", contentType: mpw.FormDataContentType(), status: http.StatusCreated},
+		{name: "no boundary", contentType: "multipart/form-data", status: http.StatusBadRequest},
+		{name: "other boundary", contentType: "multipart/form-data; boundary=not-the-boundary", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			res, err := http.Post(srv.URL+api.EndpointV2SayHello, tt.contentType, bytes.NewReader(body.Bytes()))
+			if err != nil {
+				t.Fatalf("posting: %v", err)
+			}
+			defer func() { _ = res.Body.Close() }()
+
+			buf, _ := io.ReadAll(res.Body)
+			if res.StatusCode != tt.status {
+				t.Fatalf("status is %d, expected %d: %s", res.StatusCode, tt.status, buf)
+			}
+			if tt.status != http.StatusCreated {
+				return
+			}
+
+			var resp struct {
+				Attachments []struct {
+					FileName string `json:"fileName"`
+				} `json:"attachments"`
+			}
+			if err := json.Unmarshal(buf, &resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if len(resp.Attachments) != 1 || resp.Attachments[0].FileName != "notes.txt" {
+				t.Errorf("unexpected response: %s", buf)
+			}
+		})
+	}
+}
+
+// TestGatewayUploadLimits checks that the gateway rejects multipart uploads over the limits with 413
+func TestGatewayUploadLimits(t *testing.T) {
+
+	srv := testserver.Start(t, testserver.WithYAML("service: {limits: {max-attachments: 2, max-file-size: 1024, max-total-size: 1536}}"))
+
+	tests := []struct {
+		name   string
+		files  []int
+		status int
+	}{
+		{name: "under limits", files: []int{1024, 512}, status: http.StatusCreated},
+		{name: "file over limit", files: []int{4 << 20}, status: http.StatusRequestEntityTooLarge},
+		{name: "total over limit", files: []int{1024, 1024}, status: http.StatusRequestEntityTooLarge},
+		{name: "too many files", files: []int{1, 1, 1}, status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var body bytes.Buffer
+			mpw := multipart.NewWriter(&body)
+
+			if err := mpw.WriteField("object", `{"title": "limits"}`); err != nil {
+				t.Fatal(err)
+			}
+			for i, size := range tt.files {
+				w, err := mpw.CreateFormFile("attachment", fmt.Sprintf("file-%d.txt", i))
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, _ = w.Write(bytes.Repeat([]byte("x"), size))
+			}
+			if err := mpw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := http.Post(srv.URL+api.EndpointV2SayHello, mpw.FormDataContentType(), &body)
+			if err != nil {
+				t.Fatalf("posting: %v", err)
+			}
+			defer func() { _ = res.Body.Close() }()
+
+			buf, _ := io.ReadAll(res.Body)
+			if res.StatusCode != tt.status {
+				t.Fatalf("status is %d, expected %d: %s", res.StatusCode, tt.status, buf)
+			}
+			if tt.status == http.StatusCreated {
+				return
+			}
+
+			// Rejections are gateway errors, the same as for JSON requests
+			var resp struct {
+				Code int `json:"code"`
+			}
+			if err := json.Unmarshal(buf, &resp); err != nil || codes.Code(resp.Code) != codes.ResourceExhausted {
+				t.Errorf("unexpected response: %s", buf)
+			}
+		})
+	}
+}
@@ -4,41 +4,155 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
+	"sync/atomic"
 	"time"
 
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/compress"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/debugdump"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/limits"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/recording"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
-	_ "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/docs"
 
+	logger "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 )
 
 type (
 	Server struct {
 		host           string
-		gatewayTimeout time.Duration
+		gatewayTimeout atomic.Int64
 		restHost       string
+		limits         limits.Config
 		resolver       *Resolver
+		janitor        Janitor
+		recorder       *recording.Recorder
+		dumper         *debugdump.Dumper
+		tracker        *admin.Tracker
+		principals     principalTrust
+		log            *logger.Logger
+	}
+
+	// Janitor is a background job that runs while the server is running
+	Janitor interface {
+		Run(ctx context.Context) error
 	}
 
 	Config struct {
 		Host           string        `json:"host" yaml:"host" split_words:"true"`
-		GatewayTimeout time.Duration `json:"gatewayTimeout" yaml:"gateway-timeout" split_words:"true"`
+		GatewayTimeout time.Duration `json:"gatewayTimeout" yaml:"gateway-timeout" split_words:"true" default:"10s"`
 		RestHost       string        `json:"restHost" yaml:"rest-host" split_words:"true"`
+
+		// Limits are applied on start. Attachments are limited by the service config.
+		Limits limits.Config `json:"limits" yaml:"limits"`
+
+		// Recording captures incoming requests to an archive that grpc-rest-client replays
+		Recording recording.Config `json:"recording" yaml:"recording"`
+
+		// DebugDump logs requests and responses. It's reloadable, so dumps could be enabled for a route at runtime.
+		DebugDump debugdump.Config `json:"debugDump" yaml:"debug-dump" split_words:"true"`
+
+		// Principal is applied on start
+		Principal PrincipalConfig `json:"principal" yaml:"principal"`
 	}
 
 	SayHelloResponse struct {
-		Response string `json:"response"`
+		UploadID    string           `json:"uploadId"`
+		Response    string           `json:"response"`
+		Attachments []AttachmentInfo `json:"attachments"`
+	}
+
+	AttachmentInfo struct {
+		FileName       string        `json:"fileName"`
+		DeclaredType   string        `json:"declaredType"`
+		DetectedType   string        `json:"detectedType"`
+		Size           int64         `json:"size"`
+		SHA256         string        `json:"sha256"`
+		ScanStatus     string        `json:"scanStatus"`
+		ScanSignature  string        `json:"scanSignature"`
+		StoredName     string        `json:"storedName"`
+		Derived        []DerivedFile `json:"derived"`
+		StoredEncoding string        `json:"storedEncoding"`
+		KeyID          string        `json:"encryptionKeyId"`
+	}
+
+	DerivedFile struct {
+		Kind     string `json:"kind"`
+		FileName string `json:"fileName"`
+		Width    int32  `json:"width"`
+		Height   int32  `json:"height"`
 	}
 )
 
-func NewServer(config Config, resolver *Resolver) (*Server, error) {
+// NewServer creates a server. janitor and tracker could be nil. tracker keeps connections and requests
+// for the admin server.
+func NewServer(
+	config Config,
+	resolver *Resolver,
+	janitor Janitor,
+	tracker *admin.Tracker,
+	log *logger.Logger,
+) (*Server, error) {
+
+	s := Server{
+		host:     config.Host,
+		restHost: config.RestHost,
+		limits:   config.Limits,
+		resolver: resolver,
+		dumper:   debugdump.New(config.DebugDump, log),
+		tracker:  tracker,
+		log:      log,
+		janitor:  janitor,
+	}
+	s.gatewayTimeout.Store(int64(config.GatewayTimeout))
+
+	principals, err := newPrincipalTrust(config.Principal)
+	if err != nil {
+		return nil, err
+	}
+	s.principals = principals
+
+	if config.Recording.Enabled {
+		recorder, err := recording.NewRecorder(config.Recording, "grpc-rest-multipart-server", log)
+		if err != nil {
+			return nil, fmt.Errorf("creating recorder: %w", err)
+		}
+		s.recorder = recorder
+
+		log.Infof("recording requests to %s", recorder.Dir())
+	}
+
+	return &s, nil
+}
+
+// ApplyConfig updates reloadable settings of a running server. Hosts can't be changed without restart.
+func (s *Server) ApplyConfig(config Config) error {
+	s.gatewayTimeout.Store(int64(config.GatewayTimeout))
+	s.dumper.Apply(config.DebugDump)
+	return nil
+}
 
-	return &Server{
-		host:           config.Host,
-		gatewayTimeout: config.GatewayTimeout,
-		restHost:       config.RestHost,
-		resolver:       resolver,
-	}, nil
+func (s *Server) GatewayTimeout() time.Duration {
+	return time.Duration(s.gatewayTimeout.Load())
+}
+
+// withGatewayTimeout limits gateway requests with the current gateway timeout
+func (s *Server) withGatewayTimeout(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		timeout := s.GatewayTimeout()
+		if timeout <= 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
 func (s *Server) ServeGrpc(grpcServer *grpc.Server) error {
@@ -51,16 +165,56 @@ func (s *Server) ServeGrpc(grpcServer *grpc.Server) error {
 	return grpcServer.Serve(lis)
 }
 
-func (s *Server) Run(ctx context.Context) error {
+// NewGrpcServer creates a gRPC server with the service registered. It's served by Run or on any listener.
+func (s *Server) NewGrpcServer() *grpc.Server {
 
-	var opts []grpc.ServerOption
+	interceptors := []grpc.UnaryServerInterceptor{
+		s.principals.UnaryInterceptor(),
+		logging.UnaryInterceptor(s.log),
+		s.tracker.UnaryInterceptor(),
+		s.dumper.UnaryInterceptor(),
+	}
+	if s.recorder != nil {
+		interceptors = append(interceptors, s.recorder.UnaryInterceptor())
+	}
+
+	// zstd messages are decoded before gRPC checks their size, so decoders are limited too
+	compress.SetGrpcMaxMessageSize(s.limits.MaxRequestSize)
+
+	opts := append(s.limits.ServerOptions(), grpc.ChainUnaryInterceptor(interceptors...))
+	if s.tracker != nil {
+		opts = append(opts, grpc.StatsHandler(s.tracker.StatsHandler()))
+	}
 
 	grpcServer := grpc.NewServer(opts...)
 	api.RegisterGrpcRestMultipartServiceServer(grpcServer, s.resolver)
 
+	return grpcServer
+}
+
+func (s *Server) Run(ctx context.Context) error {
+
+	if s.recorder != nil {
+		defer func() {
+			if err := s.recorder.Close(); err != nil {
+				s.log.Errorf("closing recorder: %v", err)
+			}
+		}()
+	}
+
+	grpcServer := s.NewGrpcServer()
+
 	termChan := make(chan struct{})
 	defer close(termChan)
 
+	if s.janitor != nil {
+		go func() {
+			if err := s.janitor.Run(ctx); err != nil {
+				s.log.Errorf("janitor: %v", err)
+			}
+		}()
+	}
+
 	chanGrpcErr := make(chan error)
 	go func() {
 		defer close(chanGrpcErr)
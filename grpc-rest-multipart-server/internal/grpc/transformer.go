@@ -2,9 +2,15 @@ package grpc
 
 import (
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/api"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/media"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/metadata"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// FromApiAttachments converts attachments of any transport. An attachment without a content type is
+// application/octet-stream, the same as a multipart file part that clients send without a type.
 func FromApiAttachments(src []*api.Attachment) []service.Attachment {
 	if src == nil {
 		return nil
@@ -13,11 +19,147 @@ func FromApiAttachments(src []*api.Attachment) []service.Attachment {
 	res := make([]service.Attachment, 0, len(src))
 
 	for _, at := range src {
+		contentType := at.ContentType
+		if len(contentType) == 0 {
+			contentType = MIMEOctetStream
+		}
+
 		res = append(res, service.Attachment{
-			FileName: at.FileName,
-			FileData: at.BinaryData,
+			FileName:    at.FileName,
+			FileData:    at.BinaryData,
+			ContentType: contentType,
+		})
+	}
+
+	return res
+}
+
+func ToApiAttachmentInfos(src []service.AttachmentInfo) []*api.AttachmentInfo {
+	if src == nil {
+		return nil
+	}
+
+	res := make([]*api.AttachmentInfo, 0, len(src))
+
+	for _, at := range src {
+		res = append(res, &api.AttachmentInfo{
+			FileName:        at.FileName,
+			DeclaredType:    at.DeclaredType,
+			DetectedType:    at.DetectedType,
+			ScanStatus:      at.ScanStatus,
+			ScanSignature:   at.ScanSignature,
+			StoredName:      at.StoredName,
+			Derived:         ToApiDerivedFiles(at.Derived),
+			StoredEncoding:  at.StoredEncoding,
+			EncryptionKeyId: at.KeyID,
+			Size:            at.Size,
+			Sha256:          at.SHA256,
 		})
 	}
 
 	return res
 }
+
+func ToApiDerivedFiles(src []media.Derived) []*api.DerivedFile {
+	if src == nil {
+		return nil
+	}
+
+	res := make([]*api.DerivedFile, 0, len(src))
+
+	for _, d := range src {
+		res = append(res, &api.DerivedFile{
+			Kind:     d.Kind,
+			FileName: d.FileName,
+			Width:    int32(d.Width),
+			Height:   int32(d.Height),
+		})
+	}
+
+	return res
+}
+
+func FromApiListAttachmentsRequest(src *api.ListAttachmentsRequest) metadata.Query {
+
+	q := metadata.Query{
+		UploadID:       src.UploadId,
+		Principal:      src.Principal,
+		FileName:       src.FileName,
+		ContentType:    src.ContentType,
+		ScanStatus:     src.ScanStatus,
+		IncludeTrashed: src.IncludeTrashed,
+		MinSize:        src.MinSize,
+		MaxSize:        src.MaxSize,
+		OrderBy:        src.OrderBy,
+		Desc:           src.Desc,
+		PageSize:       int(src.PageSize),
+		PageToken:      src.PageToken,
+	}
+
+	if src.CreatedAfter != nil {
+		q.CreatedAfter = src.CreatedAfter.AsTime()
+	}
+
+	if src.CreatedBefore != nil {
+		q.CreatedBefore = src.CreatedBefore.AsTime()
+	}
+
+	return q
+}
+
+func ToApiUpload(src *metadata.Upload) *api.Upload {
+	return &api.Upload{
+		Id:          src.ID,
+		Title:       src.Title,
+		Description: src.Description,
+		IntValue:    int64(src.IntValue),
+		Principal:   src.Principal,
+		CreatedAt:   timestamppb.New(src.CreatedAt),
+		Attachments: ToApiAttachmentRecords(src.Attachments),
+	}
+}
+
+func ToApiAttachmentRecords(src []metadata.Attachment) []*api.AttachmentRecord {
+	if src == nil {
+		return nil
+	}
+
+	res := make([]*api.AttachmentRecord, 0, len(src))
+
+	for _, at := range src {
+		rec := api.AttachmentRecord{
+			Id:              at.ID,
+			UploadId:        at.UploadID,
+			FileName:        at.FileName,
+			Size:            at.Size,
+			Sha256:          at.SHA256,
+			DeclaredType:    at.DeclaredType,
+			DetectedType:    at.DetectedType,
+			ScanStatus:      at.ScanStatus,
+			ScanSignature:   at.ScanSignature,
+			StoredName:      at.StoredName,
+			StoredEncoding:  at.StoredEncoding,
+			EncryptionKeyId: at.KeyID,
+			Title:           at.Title,
+			Principal:       at.Principal,
+			CreatedAt:       timestamppb.New(at.CreatedAt),
+		}
+
+		if at.TrashedAt != nil {
+			rec.TrashedAt = timestamppb.New(*at.TrashedAt)
+		}
+
+		for _, d := range at.Derived {
+			rec.Derived = append(rec.Derived, &api.DerivedFile{
+				Kind:     d.Kind,
+				FileName: d.FileName,
+				Width:    int32(d.Width),
+				Height:   int32(d.Height),
+			})
+		}
+
+		res = append(res, &rec)
+	}
+
+	return res
+}
@@ -0,0 +1,225 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	jpegMarkerSOI  = 0xD8
+	jpegMarkerSOS  = 0xDA
+	jpegMarkerAPP0 = 0xE0
+	jpegMarkerAPP1 = 0xE1
+	jpegMarkerIPTC = 0xED
+	jpegMarkerCOM  = 0xFE
+
+	exifOrientationTag = 0x0112
+	orientationNormal  = 1
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n")
+
+	// PNG chunks that carry EXIF, text comments and timestamps
+	pngMetadataChunks = map[string]struct{}{
+		"eXIf": {},
+		"tEXt": {},
+		"zTXt": {},
+		"iTXt": {},
+		"tIME": {},
+	}
+
+	ErrMalformed = errors.New("malformed image")
+)
+
+// StripJPEGMetadata removes EXIF (including GPS), XMP, IPTC and comment segments without re-encoding the image.
+// Orientation is the only EXIF tag that is kept, since without it the image is displayed rotated.
+func StripJPEGMetadata(data []byte) ([]byte, error) {
+
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegMarkerSOI {
+		return nil, fmt.Errorf("%w: no jpeg start marker", ErrMalformed)
+	}
+
+	orientation := orientationNormal
+
+	var segments [][]byte
+	pos := 2
+
+	for {
+		if pos+4 > len(data) || data[pos] != 0xFF {
+			return nil, fmt.Errorf("%w: bad segment at %d", ErrMalformed, pos)
+		}
+
+		marker := data[pos+1]
+
+		// Markers could be padded with fill bytes
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+
+		// Image data follows the scan header and is copied as is
+		if marker == jpegMarkerSOS {
+			segments = append(segments, data[pos:])
+			break
+		}
+
+		segLen := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + segLen
+		if segLen < 2 || end > len(data) {
+			return nil, fmt.Errorf("%w: bad segment length at %d", ErrMalformed, pos)
+		}
+
+		segment := data[pos:end]
+		payload := segment[4:]
+
+		switch marker {
+		case jpegMarkerAPP1:
+			if bytes.HasPrefix(payload, exifHeader) {
+				if o, ok := exifOrientation(payload[len(exifHeader):]); ok {
+					orientation = o
+				}
+			}
+
+		case jpegMarkerIPTC, jpegMarkerCOM:
+
+		default:
+			segments = append(segments, segment)
+		}
+
+		pos = end
+	}
+
+	res := make([]byte, 0, len(data))
+	res = append(res, 0xFF, jpegMarkerSOI)
+
+	for i, seg := range segments {
+		// JFIF header should stay the first segment, so orientation goes after it
+		if orientation != orientationNormal && (i > 0 || seg[1] != jpegMarkerAPP0) {
+			res = append(res, orientationSegment(orientation)...)
+			orientation = orientationNormal
+		}
+
+		res = append(res, seg...)
+	}
+
+	return res, nil
+}
+
+// JPEGOrientation returns EXIF orientation of a JPEG image, 1 if it's not set.
+func JPEGOrientation(data []byte) int {
+
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegMarkerSOI {
+		return orientationNormal
+	}
+
+	for pos := 2; pos+4 <= len(data) && data[pos] == 0xFF && data[pos+1] != jpegMarkerSOS; {
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end > len(data) {
+			break
+		}
+
+		payload := data[pos+4 : end]
+		if data[pos+1] == jpegMarkerAPP1 && bytes.HasPrefix(payload, exifHeader) {
+			if o, ok := exifOrientation(payload[len(exifHeader):]); ok {
+				return o
+			}
+		}
+
+		pos = end
+	}
+
+	return orientationNormal
+}
+
+// exifOrientation looks for the orientation tag in IFD0 of a TIFF structure
+func exifOrientation(tiff []byte) (int, bool) {
+
+	if len(tiff) < 8 {
+		return 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0, false
+	}
+
+	count := int(order.Uint16(tiff[ifd:]))
+
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0, false
+		}
+
+		if order.Uint16(tiff[entry:]) == exifOrientationTag {
+			o := int(order.Uint16(tiff[entry+8:]))
+			return o, o >= 1 && o <= 8
+		}
+	}
+
+	return 0, false
+}
+
+// orientationSegment builds APP1 segment with a single orientation tag
+func orientationSegment(orientation int) []byte {
+
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // header, IFD0 at offset 8
+		0x00, 0x01, // one entry
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, // orientation, SHORT, count 1
+		0x00, byte(orientation), 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, // no next IFD
+	}
+
+	payload := append(append([]byte{}, exifHeader...), tiff...)
+
+	seg := []byte{0xFF, jpegMarkerAPP1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+
+	return append(seg, payload...)
+}
+
+// StripPNGMetadata removes EXIF, text and time chunks of a PNG image.
+func StripPNGMetadata(data []byte) ([]byte, error) {
+
+	if !bytes.HasPrefix(data, pngHeader) {
+		return nil, fmt.Errorf("%w: no png signature", ErrMalformed)
+	}
+
+	res := make([]byte, 0, len(data))
+	res = append(res, pngHeader...)
+
+	for pos := len(pngHeader); pos < len(data); {
+		if pos+12 > len(data) {
+			return nil, fmt.Errorf("%w: truncated chunk at %d", ErrMalformed, pos)
+		}
+
+		chunkLen := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + chunkLen
+		if chunkLen < 0 || end > len(data) {
+			return nil, fmt.Errorf("%w: bad chunk length at %d", ErrMalformed, pos)
+		}
+
+		if _, ok := pngMetadataChunks[string(data[pos+4:pos+8])]; !ok {
+			res = append(res, data[pos:end]...)
+		}
+
+		pos = end
+	}
+
+	return res, nil
+}
@@ -0,0 +1,177 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	MIMEJpeg = "image/jpeg"
+	MIMEPng  = "image/png"
+	MIMEGif  = "image/gif"
+
+	KindThumbnail = "thumbnail"
+
+	thumbnailQuality = 85
+)
+
+// ErrTooLarge is returned for images with more pixels than allowed
+var ErrTooLarge = errors.New("image is too large")
+
+type (
+	// Config of post-upload processing of raster images. Other files, e.g. SVG, are stored as is.
+	Config struct {
+		Enabled        bool  `json:"enabled" yaml:"enabled" split_words:"true"`
+		StripMetadata  bool  `json:"stripMetadata" yaml:"strip-metadata" split_words:"true" default:"true"`
+		ThumbnailSizes []int `json:"thumbnailSizes" yaml:"thumbnail-sizes" split_words:"true" default:"128,512" validate:"dive,gt=0"`
+
+		// MaxPixels limits width x height of images that are decoded. Every pixel takes 4 bytes once decoded,
+		// so a small file that declares a huge image can't exhaust memory.
+		MaxPixels int `json:"maxPixels" yaml:"max-pixels" split_words:"true" default:"40000000" validate:"gt=0"`
+	}
+
+	// WriteFunc stores a derived artefact next to the original file
+	WriteFunc func(fileName string, data []byte) error
+
+	// Derived is an artefact that is stored next to the original file
+	Derived struct {
+		Kind     string
+		FileName string
+		Width    int
+		Height   int
+	}
+)
+
+// IsRaster reports image types that could be processed
+func IsRaster(contentType string) bool {
+	switch contentType {
+	case MIMEJpeg, MIMEPng, MIMEGif:
+		return true
+	}
+
+	return false
+}
+
+// StripMetadata returns the file content without EXIF, GPS and text metadata. Unsupported types are returned as is.
+func StripMetadata(data []byte, contentType string) ([]byte, error) {
+
+	switch contentType {
+	case MIMEJpeg:
+		return StripJPEGMetadata(data)
+	case MIMEPng:
+		return StripPNGMetadata(data)
+	}
+
+	return data, nil
+}
+
+// MakeThumbnails encodes thumbnails of the image and passes them to write with <name>.thumb-<size>.<ext> names,
+// where name is the stored file name without extension. Sizes that are not smaller than the image are skipped.
+// Images with more than maxPixels pixels are rejected with ErrTooLarge before they are decoded.
+func MakeThumbnails(data []byte, contentType, storedName string, sizes []int, maxPixels int, write WriteFunc) ([]Derived, error) {
+
+	img, err := decode(data, contentType, maxPixels)
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+
+	orientation := orientationNormal
+	if contentType == MIMEJpeg {
+		orientation = JPEGOrientation(data)
+	}
+
+	ext := ".png"
+	if contentType == MIMEJpeg {
+		ext = ".jpg"
+	}
+
+	// A full-resolution copy takes 4 bytes a pixel, so it's made once for all sizes
+	src := ToRGBA(img)
+
+	base := strings.TrimSuffix(storedName, filepath.Ext(storedName))
+	bounds := src.Bounds()
+
+	derived := make([]Derived, 0, len(sizes))
+
+	for _, size := range sizes {
+		if bounds.Dx() <= size && bounds.Dy() <= size {
+			continue
+		}
+
+		thumb := Thumbnail(src, size, orientation)
+
+		encoded, err := encode(thumb, contentType)
+		if err != nil {
+			return derived, fmt.Errorf("encoding %d thumbnail: %w", size, err)
+		}
+
+		fileName := fmt.Sprintf("%s.thumb-%d%s", base, size, ext)
+		if err := write(fileName, encoded); err != nil {
+			return derived, fmt.Errorf("writing %d thumbnail: %w", size, err)
+		}
+
+		derived = append(derived, Derived{
+			Kind:     KindThumbnail,
+			FileName: fileName,
+			Width:    thumb.Bounds().Dx(),
+			Height:   thumb.Bounds().Dy(),
+		})
+	}
+
+	return derived, nil
+}
+
+func decode(data []byte, contentType string, maxPixels int) (image.Image, error) {
+
+	var decodeConfig func(io.Reader) (image.Config, error)
+	var decodeImage func(io.Reader) (image.Image, error)
+
+	switch contentType {
+	case MIMEJpeg:
+		decodeConfig, decodeImage = jpeg.DecodeConfig, jpeg.Decode
+	case MIMEPng:
+		decodeConfig, decodeImage = png.DecodeConfig, png.Decode
+	case MIMEGif:
+		decodeConfig, decodeImage = gif.DecodeConfig, gif.Decode
+	default:
+		return nil, fmt.Errorf("unsupported image type: %s", contentType)
+	}
+
+	// The header is checked first, as decoding allocates the whole image
+	cfg, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > int64(maxPixels) {
+		return nil, fmt.Errorf("%w: %dx%d, max %d pixels", ErrTooLarge, cfg.Width, cfg.Height, maxPixels)
+	}
+
+	return decodeImage(bytes.NewReader(data))
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+
+	var buf bytes.Buffer
+	var err error
+
+	if contentType == MIMEJpeg {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: thumbnailQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
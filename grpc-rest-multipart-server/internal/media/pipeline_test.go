@@ -0,0 +1,105 @@
+package media_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"runtime"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/media"
+)
+
+// pngBomb returns a PNG that declares a width x height image in its header but has no pixel data
+func pngBomb(width, height uint32) []byte {
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // RGBA
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+
+	return buf.Bytes()
+}
+
+func TestMakeThumbnailsLimit(t *testing.T) {
+
+	noWrite := func(string, []byte) error {
+		t.Fatal("nothing should be written")
+		return nil
+	}
+
+	_, err := media.MakeThumbnails(pngBomb(50000, 50000), media.MIMEPng, "bomb.png", []int{128}, 40000000, noWrite)
+	if !errors.Is(err, media.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 200))); err != nil {
+		t.Fatal(err)
+	}
+
+	var written []string
+	write := func(fileName string, _ []byte) error {
+		written = append(written, fileName)
+		return nil
+	}
+
+	// The same image is rejected or processed depending on the limit
+	if _, err := media.MakeThumbnails(buf.Bytes(), media.MIMEPng, "img.png", []int{128}, 300*200-1, write); !errors.Is(err, media.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+
+	derived, err := media.MakeThumbnails(buf.Bytes(), media.MIMEPng, "img.png", []int{128}, 300*200, write)
+	if err != nil {
+		t.Fatalf("making thumbnails: %v", err)
+	}
+	if len(derived) != 1 || len(written) != 1 || derived[0].Width != 128 {
+		t.Errorf("unexpected thumbnails: %+v, %v", derived, written)
+	}
+}
+
+// TestMakeThumbnailsMemory checks that thumbnails of all sizes are made from one full-resolution copy
+func TestMakeThumbnailsMemory(t *testing.T) {
+
+	const side = 2000
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, side, side))); err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := []int{64, 128, 256, 512}
+	write := func(string, []byte) error { return nil }
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	derived, err := media.MakeThumbnails(buf.Bytes(), media.MIMEPng, "img.png", sizes, side*side, write)
+	if err != nil {
+		t.Fatalf("making thumbnails: %v", err)
+	}
+
+	runtime.ReadMemStats(&after)
+
+	if len(derived) != len(sizes) {
+		t.Fatalf("unexpected thumbnails: %+v", derived)
+	}
+
+	// The decoded image and its RGBA copy take 4 bytes a pixel each, thumbnails take much less
+	fullSize := uint64(side * side * 4)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 3*fullSize {
+		t.Errorf("allocated %d bytes, full-resolution image is %d bytes", allocated, fullSize)
+	}
+}
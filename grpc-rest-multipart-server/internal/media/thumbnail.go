@@ -0,0 +1,139 @@
+package media
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Thumbnail scales an image down to fit maxSize x maxSize and applies EXIF orientation. src isn't changed,
+// so thumbnails of all sizes are made from one ToRGBA copy.
+// Every thumbnail pixel is an average of the source pixels it covers, which is good enough for downscaling.
+func Thumbnail(src *image.RGBA, maxSize, orientation int) *image.RGBA {
+
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := fit(sw, sh, maxSize)
+
+	return orient(downscale(src, dw, dh), orientation)
+}
+
+// ToRGBA returns img as RGBA with the origin at 0,0. Decoded RGBA images are returned as is, others are copied.
+func ToRGBA(img image.Image) *image.RGBA {
+
+	b := img.Bounds()
+
+	if rgba, ok := img.(*image.RGBA); ok && b.Min == (image.Point{}) {
+		return rgba
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+
+	return rgba
+}
+
+func fit(w, h, maxSize int) (int, int) {
+
+	if w <= maxSize && h <= maxSize {
+		return w, h
+	}
+
+	if w >= h {
+		return maxSize, max1(h * maxSize / w)
+	}
+
+	return max1(w * maxSize / h), maxSize
+}
+
+func max1(v int) int {
+	if v < 1 {
+		return 1
+	}
+	return v
+}
+
+func downscale(src *image.RGBA, dw, dh int) *image.RGBA {
+
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw == dw && sh == dh {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		sy0, sy1 := y*sh/dh, (y+1)*sh/dh
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+
+		for x := 0; x < dw; x++ {
+			sx0, sx1 := x*sw/dw, (x+1)*sw/dw
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, bl, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := sx0; sx < sx1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					bl += uint32(p[2])
+					a += uint32(p[3])
+					n++
+				}
+			}
+
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(bl/n), uint8(a/n)
+		}
+	}
+
+	return dst
+}
+
+// orient rotates and flips an image according to EXIF orientation 1..8
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+
+	if orientation <= orientationNormal || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+	// Orientations 5..8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:y*src.Stride+x*4+4])
+		}
+	}
+
+	return dst
+}
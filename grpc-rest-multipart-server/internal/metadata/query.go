@@ -0,0 +1,249 @@
+package metadata
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	OrderByCreatedAt = "created_at"
+	OrderBySize      = "size"
+	OrderByFileName  = "file_name"
+
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+var ErrBadQuery = errors.New("bad query")
+
+type (
+	// Query selects attachments. Empty fields don't filter.
+	Query struct {
+		UploadID  string
+		Principal string
+
+		// FileName is a case-insensitive substring of the original file name
+		FileName string
+
+		// ContentType is a detected type. image/* like wildcards are supported
+		ContentType string
+
+		ScanStatus string
+
+		// IncludeTrashed lists attachments that are waiting for removal by retention
+		IncludeTrashed bool
+
+		MinSize int64
+		MaxSize int64
+
+		// CreatedAfter is inclusive and CreatedBefore is exclusive
+		CreatedAfter  time.Time
+		CreatedBefore time.Time
+
+		// OrderBy is created_at by default. Attachments with equal values are ordered by ID
+		OrderBy string
+		Desc    bool
+
+		PageSize int
+
+		// PageToken is NextPageToken of the previous page. The query should be the same except for page size.
+		PageToken string
+	}
+
+	Page struct {
+		Attachments []Attachment
+
+		// NextPageToken is empty for the last page
+		NextPageToken string
+	}
+
+	// cursor is the last attachment of a page. The next page starts after it, so pages stay consistent
+	// when attachments are added or removed between requests.
+	cursor struct {
+		OrderBy   string    `json:"o"`
+		Desc      bool      `json:"d"`
+		ID        string    `json:"id"`
+		CreatedAt time.Time `json:"t,omitempty"`
+		Size      int64     `json:"s,omitempty"`
+		FileName  string    `json:"f,omitempty"`
+	}
+)
+
+// ListAttachments filters all attachments and sorts them in memory, that's fine for volumes of an embedded store
+func (s *Store) ListAttachments(q Query) (*Page, error) {
+
+	if len(q.OrderBy) == 0 {
+		q.OrderBy = OrderByCreatedAt
+	}
+
+	switch q.OrderBy {
+	case OrderByCreatedAt, OrderBySize, OrderByFileName:
+	default:
+		return nil, fmt.Errorf("%w: unknown order [%s]", ErrBadQuery, q.OrderBy)
+	}
+
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		q.PageSize = MaxPageSize
+	}
+
+	var after *Attachment
+	if len(q.PageToken) > 0 {
+		c, err := decodeCursor(q.PageToken)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.OrderBy != q.OrderBy || c.Desc != q.Desc {
+			return nil, fmt.Errorf("%w: page token is issued for another order", ErrBadQuery)
+		}
+
+		after = &Attachment{ID: c.ID, CreatedAt: c.CreatedAt, Size: c.Size, FileName: c.FileName}
+	}
+
+	var found []Attachment
+
+	err := s.forEach(func(u *Upload) error {
+		for _, at := range u.Attachments {
+			if q.matches(at) {
+				found = append(found, at)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading uploads: %w", err)
+	}
+
+	less := func(a, b Attachment) bool {
+		c := compare(a, b, q.OrderBy)
+		if q.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+
+	sort.Slice(found, func(i, j int) bool { return less(found[i], found[j]) })
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(found), func(i int) bool { return less(*after, found[i]) })
+	}
+
+	end := start + q.PageSize
+	if end > len(found) {
+		end = len(found)
+	}
+
+	page := Page{Attachments: found[start:end]}
+
+	if end < len(found) {
+		last := found[end-1]
+
+		page.NextPageToken, err = encodeCursor(cursor{
+			OrderBy:   q.OrderBy,
+			Desc:      q.Desc,
+			ID:        last.ID,
+			CreatedAt: last.CreatedAt,
+			Size:      last.Size,
+			FileName:  last.FileName,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &page, nil
+}
+
+func (q Query) matches(at Attachment) bool {
+
+	switch {
+	case len(q.UploadID) > 0 && at.UploadID != q.UploadID,
+		len(q.Principal) > 0 && at.Principal != q.Principal,
+		len(q.ScanStatus) > 0 && at.ScanStatus != q.ScanStatus,
+		at.TrashedAt != nil && !q.IncludeTrashed,
+		len(q.FileName) > 0 && !strings.Contains(strings.ToLower(at.FileName), strings.ToLower(q.FileName)),
+		len(q.ContentType) > 0 && !matchType(q.ContentType, at.DetectedType),
+		q.MinSize > 0 && at.Size < q.MinSize,
+		q.MaxSize > 0 && at.Size > q.MaxSize,
+		!q.CreatedAfter.IsZero() && at.CreatedAt.Before(q.CreatedAfter),
+		!q.CreatedBefore.IsZero() && !at.CreatedAt.Before(q.CreatedBefore):
+		return false
+	}
+
+	return true
+}
+
+func matchType(pattern, contentType string) bool {
+
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*"))
+	}
+
+	return strings.EqualFold(pattern, contentType)
+}
+
+func compare(a, b Attachment, orderBy string) int {
+
+	res := 0
+
+	switch orderBy {
+	case OrderBySize:
+		res = compareInt64(a.Size, b.Size)
+	case OrderByFileName:
+		res = strings.Compare(a.FileName, b.FileName)
+	default:
+		res = a.CreatedAt.Compare(b.CreatedAt)
+	}
+
+	if res != 0 {
+		return res
+	}
+
+	return strings.Compare(a.ID, b.ID)
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+func encodeCursor(c cursor) (string, error) {
+
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("marshalling page token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func decodeCursor(token string) (cursor, error) {
+
+	c := cursor{}
+
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, fmt.Errorf("%w: bad page token", ErrBadQuery)
+	}
+
+	if err := json.Unmarshal(buf, &c); err != nil || len(c.ID) == 0 {
+		return c, fmt.Errorf("%w: bad page token", ErrBadQuery)
+	}
+
+	return c, nil
+}
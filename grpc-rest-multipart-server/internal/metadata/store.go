@@ -0,0 +1,260 @@
+package metadata
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// DefaultFileName is a database file in the store location if path is not configured
+	DefaultFileName = ".metadata.db"
+
+	openTimeout = time.Second
+)
+
+var (
+	bucketUploads = []byte("uploads")
+
+	ErrNotFound = errors.New("upload is not found")
+)
+
+type (
+	Config struct {
+		// Path of the database file. It's <store-location>/.metadata.db by default
+		Path string `json:"path" yaml:"path" split_words:"true"`
+	}
+
+	// Upload is a SayHello request with its attachments
+	Upload struct {
+		ID          string       `json:"id"`
+		Title       string       `json:"title"`
+		Description string       `json:"description"`
+		IntValue    int          `json:"intValue"`
+		Principal   string       `json:"principal"`
+		CreatedAt   time.Time    `json:"createdAt"`
+		Attachments []Attachment `json:"attachments"`
+	}
+
+	Attachment struct {
+		ID       string `json:"id"`
+		UploadID string `json:"uploadId"`
+
+		FileName     string `json:"fileName"`
+		Size         int64  `json:"size"`
+		SHA256       string `json:"sha256"`
+		DeclaredType string `json:"declaredType"`
+		DetectedType string `json:"detectedType"`
+
+		ScanStatus    string `json:"scanStatus"`
+		ScanSignature string `json:"scanSignature"`
+
+		// Storage keys. StoredName is empty for rejected files
+		StoredName     string    `json:"storedName"`
+		StoredEncoding string    `json:"storedEncoding"`
+		KeyID          string    `json:"keyId"`
+		Derived        []Derived `json:"derived"`
+
+		// Upload fields are copied, so attachments could be filtered and listed on their own
+		Title     string    `json:"title"`
+		Principal string    `json:"principal"`
+		CreatedAt time.Time `json:"createdAt"`
+
+		// TrashedAt is set when files are moved to trash by retention. They are removed after the trash period.
+		TrashedAt *time.Time `json:"trashedAt,omitempty"`
+	}
+
+	Derived struct {
+		Kind     string `json:"kind"`
+		FileName string `json:"fileName"`
+		Width    int    `json:"width"`
+		Height   int    `json:"height"`
+	}
+
+	// Store keeps uploads in an embedded bbolt database. Keys are upload IDs that are ordered by time.
+	Store struct {
+		db *bolt.DB
+	}
+)
+
+// FilePath returns the configured database path or the default file in the store location
+func (c Config) FilePath(storeLocation string) string {
+	if len(c.Path) == 0 {
+		return filepath.Join(storeLocation, DefaultFileName)
+	}
+
+	return c.Path
+}
+
+func Open(path string) (*Store, error) {
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("opening metadata db [%s]: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketUploads)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("creating buckets: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save records an upload. ID, creation time and attachment IDs are set if they are empty.
+func (s *Store) Save(u *Upload) error {
+
+	if len(u.ID) == 0 {
+		id, err := NewID(time.Now())
+		if err != nil {
+			return err
+		}
+		u.ID = id
+	}
+
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now().UTC()
+	}
+
+	for i := range u.Attachments {
+		at := &u.Attachments[i]
+
+		if len(at.ID) == 0 {
+			at.ID = u.ID + "-" + strconv.Itoa(i)
+		}
+		at.UploadID = u.ID
+		at.Title = u.Title
+		at.Principal = u.Principal
+		at.CreatedAt = u.CreatedAt
+	}
+
+	buf, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("marshalling upload: %w", err)
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUploads).Put([]byte(u.ID), buf)
+	})
+	if err != nil {
+		return fmt.Errorf("saving upload: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) Get(id string) (*Upload, error) {
+
+	var u *Upload
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		buf := tx.Bucket(bucketUploads).Get([]byte(id))
+		if buf == nil {
+			return fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+
+		u = &Upload{}
+		return json.Unmarshal(buf, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// Uploads returns all uploads in the order of IDs
+func (s *Store) Uploads() ([]Upload, error) {
+
+	var res []Upload
+
+	err := s.forEach(func(u *Upload) error {
+		res = append(res, *u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Update changes an upload in a transaction, so concurrent updates are not lost
+func (s *Store) Update(id string, f func(u *Upload) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(bucketUploads)
+
+		buf := b.Get([]byte(id))
+		if buf == nil {
+			return fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+
+		u := Upload{}
+		if err := json.Unmarshal(buf, &u); err != nil {
+			return fmt.Errorf("unmarshalling upload [%s]: %w", id, err)
+		}
+
+		if err := f(&u); err != nil {
+			return err
+		}
+
+		buf, err := json.Marshal(u)
+		if err != nil {
+			return fmt.Errorf("marshalling upload: %w", err)
+		}
+
+		return b.Put([]byte(id), buf)
+	})
+}
+
+func (s *Store) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUploads).Delete([]byte(id))
+	})
+}
+
+// forEach calls f for every upload in the order of IDs
+func (s *Store) forEach(f func(u *Upload) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUploads).ForEach(func(k, v []byte) error {
+
+			u := Upload{}
+			if err := json.Unmarshal(v, &u); err != nil {
+				return fmt.Errorf("unmarshalling upload [%s]: %w", k, err)
+			}
+
+			return f(&u)
+		})
+	})
+}
+
+// NewID makes an ID that sorts by time: 16 hex digits of Unix nanoseconds and 8 random hex digits
+func NewID(t time.Time) (string, error) {
+
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint64(buf, uint64(t.UnixNano()))
+
+	if _, err := rand.Read(buf[8:]); err != nil {
+		return "", fmt.Errorf("generating id: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,152 @@
+package naming_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/naming"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const storeRoot = "/store"
+
+var layouts = []string{naming.LayoutFlat, naming.LayoutDate, naming.LayoutHash}
+
+func FuzzSanitize(f *testing.F) {
+
+	for _, name := range []string{
+		"notes.txt",
+		"report.final.tar.gz",
+		"../../etc/passwd",
+		`..\..\boot.ini`,
+		"/etc/shadow",
+		"notes\x00.txt",
+		".",
+		"..",
+		".hidden",
+		" .. ",
+		"name. . .",
+		"é.txt",
+		"e‍́.txt",
+		"‮txt.exe",
+		"con:aux|nul?.txt",
+		"\xff\xfe.txt",
+		strings.Repeat("a", 300) + ".txt",
+		strings.Repeat("я", 200) + ".tar.gz",
+	} {
+		f.Add(name, 255)
+		f.Add(name, 8)
+	}
+
+	f.Fuzz(func(t *testing.T, name string, maxLength int) {
+
+		res, err := naming.Sanitize(name, maxLength)
+		if err != nil {
+			if !errors.Is(err, naming.ErrInvalidName) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return
+		}
+
+		if len(name) == 0 {
+			if len(res) > 0 {
+				t.Fatalf("empty name is sanitized to %q", res)
+			}
+			return
+		}
+
+		checkSanitized(t, res, maxLength)
+
+		again, err := naming.Sanitize(res, maxLength)
+		if err != nil || again != res {
+			t.Fatalf("sanitizing is not idempotent: %q -> %q, %v", res, again, err)
+		}
+
+		id, err := naming.NewID(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, layout := range layouts {
+			storedName := naming.Config{Layout: layout}.StoredName(id, res, time.Now())
+			checkStoredName(t, storedName)
+		}
+	})
+}
+
+func FuzzIsValidStoredName(f *testing.F) {
+
+	for _, name := range []string{
+		"0123456789abcdef.txt",
+		"ab/cd/0123456789abcdef.txt",
+		"2026/10/19/0123456789abcdef.tar.gz",
+		"../outside.txt",
+		"ab/../../outside.txt",
+		"/abs/path.txt",
+		"ab//cd.txt",
+		".quarantine/x.txt",
+		`ab\..\x.txt`,
+		"a/b/c/d/e.txt",
+	} {
+		f.Add(name)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		if naming.IsValidStoredName(name) {
+			checkStoredName(t, name)
+		}
+	})
+}
+
+func checkSanitized(t *testing.T, name string, maxLength int) {
+	t.Helper()
+
+	if !utf8.ValidString(name) {
+		t.Fatalf("%q is not UTF-8", name)
+	}
+
+	if !norm.NFC.IsNormalString(name) {
+		t.Fatalf("%q is not NFC", name)
+	}
+
+	if strings.ContainsAny(name, "/\\\x00<>:\"|?*") {
+		t.Fatalf("%q has reserved characters", name)
+	}
+
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, " ") ||
+		strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		t.Fatalf("%q starts or ends with a dot or a space", name)
+	}
+
+	for _, r := range name {
+		if unicode.IsControl(r) || unicode.In(r, unicode.Cf) {
+			t.Fatalf("%q has a control or format character %U", name, r)
+		}
+	}
+
+	if maxLength > 0 && len(name) > maxLength {
+		t.Fatalf("%q is over %d bytes", name, maxLength)
+	}
+}
+
+// checkStoredName makes sure a name stays in the store location when it's joined
+func checkStoredName(t *testing.T, name string) {
+	t.Helper()
+
+	if !naming.IsValidStoredName(name) {
+		t.Fatalf("stored name %q is not valid", name)
+	}
+
+	full := filepath.Join(storeRoot, filepath.FromSlash(name))
+
+	rel, err := filepath.Rel(storeRoot, full)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") || filepath.IsAbs(rel) {
+		t.Fatalf("stored name %q resolves to %q out of the store", name, full)
+	}
+}
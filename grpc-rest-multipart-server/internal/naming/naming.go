@@ -0,0 +1,208 @@
+// Package naming builds names of stored files. Stored names are generated IDs with a sanitized extension,
+// so client file names never become paths. Original names are kept in metadata only.
+package naming
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	LayoutFlat = "flat"
+	LayoutDate = "date"
+	LayoutHash = "hash"
+
+	// maxExtLength is a length of a single extension part. Longer suffixes are not treated as extensions
+	maxExtLength = 16
+
+	// maxStoredDepth is a number of path elements in a stored name: two shard levels, a date or the file itself
+	maxStoredDepth = 4
+)
+
+var ErrInvalidName = errors.New("invalid file name")
+
+// compoundExts are inner extensions that are kept together with the outer one, e.g. .tar.gz
+var compoundExts = map[string]bool{
+	".tar": true,
+}
+
+type Config struct {
+	// Layout shards stored files: flat keeps them in the store location, date makes yyyy/mm/dd subdirectories
+	// and hash makes two levels of subdirectories by ID hash
+	Layout string `json:"layout" yaml:"layout" default:"flat" validate:"oneof=flat date hash"`
+
+	// MaxLength limits sanitized original names in bytes. Extensions are preserved.
+	MaxLength int `json:"maxLength" yaml:"max-length" split_words:"true" default:"255" validate:"gte=0"`
+}
+
+// Sanitize normalizes a client file name to NFC and drops control and format characters.
+// Names with path elements are rejected rather than cut to the base name.
+// An empty name stays empty.
+func Sanitize(name string, maxLength int) (string, error) {
+
+	if len(name) == 0 {
+		return "", nil
+	}
+
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("%w: not UTF-8", ErrInvalidName)
+	}
+
+	if strings.ContainsAny(name, "/\\\x00") || name == "." || name == ".." {
+		return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		// Format characters include bidi overrides that make "exe.txt" look like "txt.exe"
+		case unicode.IsControl(r), unicode.In(r, unicode.Cf):
+			return -1
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+
+	// Dropped format characters could split a combining sequence, so it's normalized afterwards
+	name = norm.NFC.String(name)
+
+	// Leading dots make hidden files, trailing dots and spaces are dropped by Windows
+	name = strings.TrimLeft(name, ". ")
+	name = strings.TrimRight(name, ". ")
+
+	if len(name) == 0 {
+		return "", fmt.Errorf("%w: nothing left after sanitizing", ErrInvalidName)
+	}
+
+	if maxLength > 0 && len(name) > maxLength {
+		stem, ext := SplitExt(name)
+		if len(ext) >= maxLength {
+			return "", fmt.Errorf("%w: extension is too long", ErrInvalidName)
+		}
+
+		stem = truncate(stem, maxLength-len(ext))
+		if len(stem) == 0 {
+			return "", fmt.Errorf("%w: nothing left after truncating", ErrInvalidName)
+		}
+		name = stem + ext
+	}
+
+	return name, nil
+}
+
+// SplitExt splits a name into a stem and an extension. Compound extensions like .tar.gz are kept whole.
+// Suffixes that don't look like extensions, e.g. "v1.2 final", are left in the stem.
+func SplitExt(name string) (string, string) {
+
+	ext := path.Ext(name)
+	if !isExt(ext) || ext == name {
+		return name, ""
+	}
+
+	stem := strings.TrimSuffix(name, ext)
+
+	if inner := path.Ext(stem); compoundExts[strings.ToLower(inner)] && inner != stem {
+		return strings.TrimSuffix(stem, inner), inner + ext
+	}
+
+	return stem, ext
+}
+
+// NewID is a time ordered unique ID: 8 bytes of Unix nanoseconds and 8 random bytes in hex
+func NewID(t time.Time) (string, error) {
+
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf, uint64(t.UnixNano()))
+
+	if _, err := rand.Read(buf[8:]); err != nil {
+		return "", fmt.Errorf("generating file id: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// StoredName is a slash separated name of a file relative to the store location.
+// The extension of the original name is kept lowercase, so content type could be told by the name.
+func (c Config) StoredName(id, originalName string, t time.Time) string {
+
+	_, ext := SplitExt(originalName)
+	name := id + strings.ToLower(ext)
+
+	switch c.Layout {
+	case LayoutDate:
+		return path.Join(t.UTC().Format("2006/01/02"), name)
+
+	case LayoutHash:
+		// IDs start with time, so the hash spreads files evenly
+		sum := sha256.Sum256([]byte(id))
+		h := hex.EncodeToString(sum[:2])
+		return path.Join(h[:2], h[2:], name)
+	}
+
+	return name
+}
+
+// IsValidStoredName tells if a name could be safely joined to the store location.
+// Names made before sharding have any characters but are a single element.
+func IsValidStoredName(name string) bool {
+
+	if len(name) == 0 || strings.ContainsAny(name, "\\\x00") {
+		return false
+	}
+
+	parts := strings.Split(name, "/")
+	if len(parts) > maxStoredDepth {
+		return false
+	}
+
+	for _, p := range parts {
+		// Dot names are internal: quarantine, trash, metadata and also . and ..
+		if len(p) == 0 || strings.HasPrefix(p, ".") {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isExt(ext string) bool {
+
+	if len(ext) < 2 || len(ext) > maxExtLength+1 {
+		return false
+	}
+
+	for _, r := range ext[1:] {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// truncate cuts s to n bytes at most without breaking a rune
+func truncate(s string, n int) string {
+
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return strings.TrimRight(s[:n], ". ")
+}
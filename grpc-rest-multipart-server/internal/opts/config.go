@@ -1,72 +1,64 @@
 package opts
 
 import (
-	"encoding/json"
-	"fmt"
-	"gopkg.in/yaml.v3"
-	"os"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
 
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/audit"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/encryption"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/grpc"
-	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/log"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/metadata"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/scanner"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/hashicorp/go-multierror"
-	"github.com/kelseyhightower/envconfig"
 )
 
-const (
-	defaultAppPrefix = "APP"
-)
+// ReloadablePaths are config fields that are applied without restart
+var ReloadablePaths = []string{
+	"log",
+	"grpc.gateway-timeout",
+	"grpc.debug-dump",
+	"service.quarantine-location",
+	"service.content-types",
+	"service.images",
+	"service.compression",
+	"service.retention",
+	"service.naming",
+	"service.limits",
+}
 
 type Config struct {
-	Log     log.Config     `json:"log" yaml:"log"`
+	Log     logging.Config `json:"log" yaml:"log"`
 	GRPC    grpc.Config    `json:"grpc" yaml:"grpc"`
 	Service service.Config `json:"service" yaml:"service"`
-}
-
-type YamlConfigLoader interface {
-	Unmarshal([]byte) error
-}
+	Scanner scanner.Config `json:"scanner" yaml:"scanner"`
 
-func LoadConfigFromFileOrEnvs(configFile string, config interface{}) error {
+	// Encryption needs restart: files written with a key have to stay readable while the process runs
+	Encryption encryption.Config `json:"encryption" yaml:"encryption"`
 
-	if len(configFile) == 0 {
-		if err := FromEnv(config); err != nil {
-			return fmt.Errorf("locading opts from envs: %w", err)
-		}
+	Metadata metadata.Config `json:"metadata" yaml:"metadata"`
 
-		return nil
-	}
+	// Audit needs restart: the trail is a single chain that is kept open while the process runs
+	Audit audit.Config `json:"audit" yaml:"audit"`
 
-	buf, err := os.ReadFile(configFile)
-	if err != nil {
-		return fmt.Errorf("reading opts file: %w", err)
-	}
-
-	errYaml := yaml.Unmarshal(buf, config)
-	if errYaml == nil {
-		return nil
-	}
-
-	errJson := json.Unmarshal(buf, config)
-	if errJson == nil {
-		return nil
-	}
-
-	return multierror.Append(
-		fmt.Errorf("bad opts file [%s]. Failed to unmarshal YAML and JSON", configFile),
-		errYaml, errJson,
-	)
+	// Admin serves the Admin gRPC service on its own host. It needs restart.
+	Admin admin.Config `json:"admin" yaml:"admin"`
 }
 
-func FromEnv(config interface{}) error {
+// Load merges defaults, config files, APP_* env vars and command line flags.
+// See config.Loader for the precedence.
+func Load(args []string) (*Config, *config.Result, error) {
+
+	cfg := Config{}
 
-	if err := envconfig.Process(defaultAppPrefix, &config); err != nil {
-		return fmt.Errorf("failed to load opts: %w", err)
+	res, err := config.NewLoader(args).Load(&cfg)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return nil
+	return &cfg, res, nil
 }
 
 func (c *Config) Validate() error {
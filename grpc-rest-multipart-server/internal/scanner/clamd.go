@@ -0,0 +1,152 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	clamdChunkSize = 32 * 1024
+
+	clamdResponseOK    = "OK"
+	clamdSuffixFound   = " FOUND"
+	clamdSuffixError   = " ERROR"
+	clamdStreamPrefix  = "stream: "
+	clamdCommandStream = "zINSTREAM\x00"
+)
+
+// Clamd scans files with clamd INSTREAM command. Address is "tcp://host:port" or "unix:///path/to/clamd.sock".
+// Any daemon that speaks the same protocol could be used, e.g. a fake one in tests.
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+
+	network, addr, ok := strings.Cut(address, "://")
+	if !ok {
+		network, addr = "tcp", address
+	}
+
+	if network != "tcp" && network != "unix" {
+		return nil, fmt.Errorf("unsupported clamd network: %s", network)
+	}
+
+	return &Clamd{
+		network: network,
+		address: addr,
+		timeout: timeout,
+	}, nil
+}
+
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Verdict, error) {
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	dialer := net.Dialer{}
+
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("connecting to clamd: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return Verdict{}, fmt.Errorf("setting deadline: %w", err)
+		}
+	}
+
+	if err := sendStream(conn, r); err != nil {
+		return Verdict{}, err
+	}
+
+	resp, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Verdict{}, fmt.Errorf("reading clamd response: %w", err)
+	}
+
+	return parseClamdResponse(resp)
+}
+
+// sendStream writes file as chunks that are prefixed with their length. Zero length chunk ends the stream.
+func sendStream(w io.Writer, r io.Reader) error {
+
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.WriteString(clamdCommandStream); err != nil {
+		return fmt.Errorf("sending command: %w", err)
+	}
+
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+
+	for {
+		n, errRead := r.Read(buf)
+
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+
+			if _, err := bw.Write(size); err != nil {
+				return fmt.Errorf("sending chunk: %w", err)
+			}
+			if _, err := bw.Write(buf[:n]); err != nil {
+				return fmt.Errorf("sending chunk: %w", err)
+			}
+		}
+
+		if errors.Is(errRead, io.EOF) {
+			break
+		}
+		if errRead != nil {
+			return fmt.Errorf("reading file: %w", errRead)
+		}
+	}
+
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := bw.Write(size); err != nil {
+		return fmt.Errorf("ending stream: %w", err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("sending stream: %w", err)
+	}
+
+	return nil
+}
+
+// parseClamdResponse handles "stream: OK", "stream: <signature> FOUND" and "<message> ERROR" responses
+func parseClamdResponse(resp string) (Verdict, error) {
+
+	resp = strings.TrimRight(resp, "\x00\r\n")
+	result := strings.TrimPrefix(resp, clamdStreamPrefix)
+
+	switch {
+	case result == clamdResponseOK:
+		return Verdict{Status: StatusClean}, nil
+
+	case strings.HasSuffix(result, clamdSuffixFound):
+		return Verdict{
+			Status:    StatusInfected,
+			Signature: strings.TrimSuffix(result, clamdSuffixFound),
+		}, nil
+
+	case strings.HasSuffix(result, clamdSuffixError):
+		return Verdict{}, fmt.Errorf("clamd error: %s", strings.TrimSuffix(result, clamdSuffixError))
+	}
+
+	return Verdict{}, fmt.Errorf("unexpected clamd response: %q", resp)
+}
@@ -0,0 +1,157 @@
+package scanner_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/scanner"
+)
+
+const (
+	fakeStreamMaxLength = 64 * 1024
+	eicarMarker         = "EICAR-STANDARD-ANTIVIRUS-TEST-FILE"
+)
+
+// fakeClamd serves INSTREAM like clamd does: it replies with FOUND for streams with the EICAR marker,
+// with ERROR for streams over the size limit or with broken chunks and with OK for others
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { _ = lis.Close() })
+
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn.(*net.TCPConn))
+		}
+	}()
+
+	return "tcp://" + lis.Addr().String()
+}
+
+func serveClamd(conn *net.TCPConn) {
+
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReader(conn)
+
+	reply := func(resp string) {
+		_, _ = conn.Write([]byte(resp + "\x00"))
+
+		// The rest of the stream is read, so the reply isn't lost to a reset
+		_ = conn.CloseWrite()
+		_, _ = io.Copy(io.Discard, r)
+	}
+
+	command, err := r.ReadString(0)
+	if err != nil || command != "zINSTREAM\x00" {
+		reply("UNKNOWN COMMAND")
+		return
+	}
+
+	var data bytes.Buffer
+	size := make([]byte, 4)
+
+	for {
+		if _, err := io.ReadFull(r, size); err != nil {
+			reply("INSTREAM: Can't read chunk size. ERROR")
+			return
+		}
+
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			break
+		}
+
+		if data.Len()+int(n) > fakeStreamMaxLength {
+			reply("INSTREAM size limit exceeded. ERROR")
+			return
+		}
+
+		if _, err := io.CopyN(&data, r, int64(n)); err != nil {
+			reply("INSTREAM: Can't read chunk. ERROR")
+			return
+		}
+	}
+
+	switch {
+	case strings.Contains(data.String(), eicarMarker):
+		reply("stream: Eicar-Signature FOUND")
+	case strings.Contains(data.String(), "broken"):
+		reply("stream: Can't allocate memory ERROR")
+	default:
+		reply("stream: OK")
+	}
+}
+
+func TestClamd(t *testing.T) {
+
+	clamd, err := scanner.NewClamd(fakeClamd(t), 5*time.Second)
+	if err != nil {
+		t.Fatalf("creating scanner: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		verdict   scanner.Verdict
+		errSubstr string
+	}{
+		{
+			name:    "clean",
+			data:    []byte("hello"),
+			verdict: scanner.Verdict{Status: scanner.StatusClean},
+		},
+		{
+			// Chunks are 32KiB, so the marker is split between two of them
+			name:    "found",
+			data:    append(bytes.Repeat([]byte{'a'}, 32*1024-10), eicarMarker...),
+			verdict: scanner.Verdict{Status: scanner.StatusInfected, Signature: "Eicar-Signature"},
+		},
+		{
+			name:      "error",
+			data:      []byte("broken"),
+			errSubstr: "clamd error: Can't allocate memory",
+		},
+		{
+			name:      "size limit",
+			data:      bytes.Repeat([]byte{'a'}, fakeStreamMaxLength+1),
+			errSubstr: "INSTREAM size limit exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			verdict, err := clamd.Scan(context.Background(), bytes.NewReader(tt.data))
+
+			if len(tt.errSubstr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.errSubstr) {
+					t.Fatalf("expected error with %q, got %v, %+v", tt.errSubstr, err, verdict)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("scanning: %v", err)
+			}
+			if verdict != tt.verdict {
+				t.Errorf("verdict is %+v, expected %+v", verdict, tt.verdict)
+			}
+		})
+	}
+}
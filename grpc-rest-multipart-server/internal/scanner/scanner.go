@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	TypeNone  = "none"
+	TypeClamd = "clamd"
+
+	StatusClean    = "clean"
+	StatusInfected = "infected"
+	StatusSkipped  = "skipped"
+)
+
+type (
+	Config struct {
+		Type    string        `json:"type" yaml:"type" split_words:"true" default:"none" validate:"oneof=none clamd"`
+		Address string        `json:"address" yaml:"address" split_words:"true" default:"tcp://localhost:3310"`
+		Timeout time.Duration `json:"timeout" yaml:"timeout" split_words:"true" default:"30s"`
+	}
+
+	// Verdict is the result of a completed scan. Scanner failures are returned as errors instead.
+	Verdict struct {
+		Status string
+
+		// Signature is a name of the detected threat
+		Signature string
+	}
+
+	Scanner interface {
+		Scan(ctx context.Context, r io.Reader) (Verdict, error)
+	}
+)
+
+func New(config Config) (Scanner, error) {
+
+	switch strings.ToLower(config.Type) {
+	case TypeNone, "":
+		return Noop{}, nil
+
+	case TypeClamd:
+		return NewClamd(config.Address, config.Timeout)
+	}
+
+	return nil, fmt.Errorf("unknown scanner type: %s", config.Type)
+}
+
+// Noop lets all files through. Verdict is reported as skipped, so it's visible that nothing was scanned.
+type Noop struct{}
+
+func (Noop) Scan(_ context.Context, _ io.Reader) (Verdict, error) {
+	return Verdict{Status: StatusSkipped}, nil
+}
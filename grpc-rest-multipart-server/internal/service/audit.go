@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/audit"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/metadata"
+)
+
+// audit records an event of the request of ctx. A failed record is logged, it doesn't fail the request,
+// because the action has already happened.
+func (svc *Service) audit(ctx context.Context, ev audit.Event) {
+
+	if len(ev.Principal) == 0 {
+		principal, ok := PrincipalFromContext(ctx)
+		if !ok {
+			principal = PrincipalAnonymous
+		}
+		ev.Principal = principal
+	}
+
+	if len(ev.RequestID) == 0 {
+		ev.RequestID = logging.RequestID(ctx)
+	}
+
+	if err := svc.trail.Record(ev); err != nil {
+		logging.FromContext(ctx, svc.log).Errorf("recording audit event %s: %v", ev.Action, err)
+	}
+}
+
+func auditFiles(infos []AttachmentInfo) []audit.File {
+
+	files := make([]audit.File, 0, len(infos))
+
+	for _, info := range infos {
+		files = append(files, audit.File{
+			Name:       info.FileName,
+			StoredName: info.StoredName,
+			Size:       info.Size,
+			SHA256:     info.SHA256,
+		})
+	}
+
+	return files
+}
+
+func auditAttachment(at metadata.Attachment) []audit.File {
+	return []audit.File{{
+		Name:       at.FileName,
+		StoredName: at.StoredName,
+		Size:       at.Size,
+		SHA256:     at.SHA256,
+	}}
+}
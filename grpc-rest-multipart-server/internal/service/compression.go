@@ -0,0 +1,25 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/compress"
+)
+
+// Compression of stored files. Compressed files get .gz or .zst suffix and are decompressed on read,
+// so StoredName of an attachment doesn't depend on compression.
+type Compression struct {
+	Algorithm string   `json:"algorithm" yaml:"algorithm" split_words:"true" default:"none" validate:"oneof=none gzip zstd"`
+	MinSize   int      `json:"minSize" yaml:"min-size" split_words:"true" default:"1024"`
+	Types     []string `json:"types" yaml:"types" split_words:"true" default:"text/*,application/json,application/xml,image/svg+xml"`
+}
+
+// encodingFor returns compression algorithm for a file or "" if it's stored as is
+func (c Compression) encodingFor(contentType string, size int) string {
+
+	if !compress.IsSupported(c.Algorithm) || size < c.MinSize || !matchesAny(contentType, c.Types) {
+		return ""
+	}
+
+	return strings.ToLower(c.Algorithm)
+}
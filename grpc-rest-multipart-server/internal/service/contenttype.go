@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// MIMEOctetStream and MIMETextPlain are what sniffing falls back to, so they don't prove a mismatch
+	MIMEOctetStream = "application/octet-stream"
+	MIMETextPlain   = "text/plain"
+)
+
+var (
+	ErrContentTypeNotAllowed = errors.New("content type is not allowed")
+	ErrContentTypeMismatch   = errors.New("content type doesn't match file extension")
+)
+
+// ContentTypePolicy limits attachment types. Types could be exact ("image/png") or wildcards ("image/*").
+// Empty Allow list allows everything that is not denied. Deny wins over Allow.
+type ContentTypePolicy struct {
+	Allow          []string `json:"allow" yaml:"allow"`
+	Deny           []string `json:"deny" yaml:"deny"`
+	RejectMismatch bool     `json:"rejectMismatch" yaml:"reject-mismatch" split_words:"true"`
+}
+
+// DetectContentType sniffs the type with magic bytes of the content. Parameters like charset are dropped.
+func DetectContentType(data []byte) string {
+	return baseType(http.DetectContentType(data))
+}
+
+// Check returns ErrContentTypeNotAllowed or ErrContentTypeMismatch if the attachment violates the policy.
+// The first value reports a mismatch between the extension and the detected type even if it's not rejected.
+func (p ContentTypePolicy) Check(fileName, detectedType string) (bool, error) {
+
+	if matchesAny(detectedType, p.Deny) {
+		return false, fmt.Errorf("%w: %s", ErrContentTypeNotAllowed, detectedType)
+	}
+
+	if len(p.Allow) > 0 && !matchesAny(detectedType, p.Allow) {
+		return false, fmt.Errorf("%w: %s", ErrContentTypeNotAllowed, detectedType)
+	}
+
+	extType := typeByExtension(fileName)
+	mismatch := len(extType) > 0 && !compatibleTypes(extType, detectedType)
+
+	if mismatch && p.RejectMismatch {
+		return true, fmt.Errorf("%w: %s is %s", ErrContentTypeMismatch, fileName, detectedType)
+	}
+
+	return mismatch, nil
+}
+
+func typeByExtension(fileName string) string {
+	return baseType(mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))))
+}
+
+// compatibleTypes compares a type expected by the extension with the detected one.
+// Sniffing recognizes only containers for some formats, e.g. SVG is detected as XML and DOCX as ZIP.
+func compatibleTypes(extType, detectedType string) bool {
+
+	switch {
+	case extType == detectedType:
+		return true
+
+	case detectedType == MIMEOctetStream || detectedType == MIMETextPlain:
+		return true
+
+	case detectedType == "text/xml" || detectedType == "application/xml":
+		return strings.HasSuffix(extType, "+xml") || strings.HasSuffix(extType, "/xml")
+
+	case detectedType == "application/zip":
+		return strings.HasSuffix(extType, "+zip") ||
+			strings.Contains(extType, "openxmlformats") ||
+			strings.Contains(extType, "opendocument") ||
+			extType == "application/java-archive" ||
+			extType == "application/epub+zip"
+	}
+
+	return false
+}
+
+func matchesAny(contentType string, patterns []string) bool {
+
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+
+		if p == contentType || p == "*/*" {
+			return true
+		}
+
+		if strings.HasSuffix(p, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func baseType(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrTooLarge = errors.New("upload is too large")
+
+// UploadLimits cap attachments of a request. Zero values aren't applied.
+// Request bodies and gRPC messages are limited by the transport.
+type UploadLimits struct {
+	MaxAttachments int `json:"maxAttachments" yaml:"max-attachments" split_words:"true" default:"10" validate:"gte=0"`
+
+	// MaxFileSize and MaxTotalSize are in bytes
+	MaxFileSize  int64 `json:"maxFileSize" yaml:"max-file-size" split_words:"true" default:"10485760" validate:"gte=0"`
+	MaxTotalSize int64 `json:"maxTotalSize" yaml:"max-total-size" split_words:"true" default:"26214400" validate:"gte=0"`
+}
+
+// CheckCount returns ErrTooLarge if a request has more than MaxAttachments attachments
+func (l UploadLimits) CheckCount(n int) error {
+	if l.MaxAttachments > 0 && n > l.MaxAttachments {
+		return fmt.Errorf("%w: more than %d attachments", ErrTooLarge, l.MaxAttachments)
+	}
+	return nil
+}
+
+// CheckFile returns ErrTooLarge if an attachment is over MaxFileSize
+func (l UploadLimits) CheckFile(fileName string, size int64) error {
+	if l.MaxFileSize > 0 && size > l.MaxFileSize {
+		return fmt.Errorf("%w: [%s] is over %d bytes", ErrTooLarge, fileName, l.MaxFileSize)
+	}
+	return nil
+}
+
+// CheckTotal returns ErrTooLarge if attachments of a request are over MaxTotalSize together
+func (l UploadLimits) CheckTotal(total int64) error {
+	if l.MaxTotalSize > 0 && total > l.MaxTotalSize {
+		return fmt.Errorf("%w: attachments are over %d bytes", ErrTooLarge, l.MaxTotalSize)
+	}
+	return nil
+}
+
+// UploadLimits are limits of the current config. The REST server applies them while it reads a request.
+func (svc *Service) UploadLimits() UploadLimits {
+	return svc.Config().Limits
+}
+
+func (l UploadLimits) check(attachments []Attachment) error {
+
+	if err := l.CheckCount(len(attachments)); err != nil {
+		return err
+	}
+
+	var total int64
+
+	for _, at := range attachments {
+		size := int64(len(at.FileData))
+
+		if err := l.CheckFile(at.FileName, size); err != nil {
+			return err
+		}
+
+		total += size
+	}
+
+	return l.CheckTotal(total)
+}
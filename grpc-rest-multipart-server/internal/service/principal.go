@@ -0,0 +1,18 @@
+package service
+
+import "context"
+
+// PrincipalAnonymous is recorded for requests that don't identify a caller
+const PrincipalAnonymous = "anonymous"
+
+type principalKey struct{}
+
+// WithPrincipal sets the caller that is recorded with uploads. Transports get it from an authenticating proxy.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(principalKey{}).(string)
+	return p, ok && len(p) > 0
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/media"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/naming"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/scanner"
+)
+
+const (
+	defaultQuarantineDir = ".quarantine"
+	quarantinePrefix     = "upload-"
+)
+
+var ErrScanFailed = errors.New("attachment scan failed")
+
+// quarantineLocation is a directory where files wait for a scan verdict.
+// By default it's inside the store location, so promoting a file is a rename.
+func (c Config) quarantineLocation() string {
+	if len(c.QuarantineLocation) > 0 {
+		return c.QuarantineLocation
+	}
+
+	return filepath.Join(c.StoreLocation, defaultQuarantineDir)
+}
+
+// stageAttachment writes an attachment to quarantine, scans it and stages it for the store if it's not infected.
+// Rejected files are removed. Nothing becomes visible in the store before the transaction is committed.
+// Scan verdict and the stored file name are set to info.
+func (svc *Service) stageAttachment(ctx context.Context, config Config, tx *transaction, at Attachment, info *AttachmentInfo) error {
+
+	setDigest(info, at.FileData)
+
+	quarantinePath, err := writeQuarantined(config.quarantineLocation(), at.FileData)
+	if err != nil {
+		return err
+	}
+
+	staged := false
+	defer func() {
+		if staged {
+			return
+		}
+		if errRemove := os.Remove(quarantinePath); errRemove != nil && !errors.Is(errRemove, os.ErrNotExist) {
+			logging.FromContext(ctx, svc.log).Errorf("removing quarantined file [%s]: %v", quarantinePath, errRemove)
+		}
+	}()
+
+	verdict, err := scanFile(ctx, svc.scanner, quarantinePath)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrScanFailed, info.FileName, err)
+	}
+
+	info.ScanStatus = verdict.Status
+	info.ScanSignature = verdict.Signature
+
+	if verdict.Status == scanner.StatusInfected {
+		logging.FromContext(ctx, svc.log).Warnf("attachment [%s] is rejected: %s", info.FileName, verdict.Signature)
+		return nil
+	}
+
+	if config.Images.Enabled && config.Images.StripMetadata && media.IsRaster(info.DetectedType) {
+		stripped, err := stripQuarantined(quarantinePath, at.FileData, info.DetectedType)
+		if err != nil {
+			return fmt.Errorf("processing [%s]: %w", info.FileName, err)
+		}
+
+		// Size and hash describe the content that is served
+		setDigest(info, stripped)
+	}
+
+	// Stored names are generated, so a client name can't point outside the store or collide with another file
+	now := time.Now()
+	id, err := naming.NewID(now)
+	if err != nil {
+		return err
+	}
+
+	storedName := config.Naming.StoredName(id, info.FileName, now)
+	storedPath := filepath.Join(config.StoreLocation, filepath.FromSlash(storedName))
+	encoding := config.Compression.encodingFor(info.DetectedType, len(at.FileData))
+
+	keyID, err := svc.stage(ctx, tx, quarantinePath, storedPath, encoding)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	staged = true
+
+	info.StoredName = storedName
+	info.StoredEncoding = encoding
+	info.KeyID = keyID
+
+	return nil
+}
+
+func stripQuarantined(path string, data []byte, contentType string) ([]byte, error) {
+
+	stripped, err := media.StripMetadata(data, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("stripping metadata: %w", err)
+	}
+
+	if err := os.WriteFile(path, stripped, 0600); err != nil {
+		return nil, fmt.Errorf("writing stripped file: %w", err)
+	}
+
+	return stripped, nil
+}
+
+func setDigest(info *AttachmentInfo, data []byte) {
+	sum := sha256.Sum256(data)
+
+	info.Size = int64(len(data))
+	info.SHA256 = hex.EncodeToString(sum[:])
+}
+
+func writeQuarantined(dir string, data []byte) (string, error) {
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("creating quarantine: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, quarantinePrefix+"*")
+	if err != nil {
+		return "", fmt.Errorf("creating quarantined file: %w", err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("writing quarantined file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("closing quarantined file: %w", err)
+	}
+
+	return f.Name(), nil
+}
+
+func scanFile(ctx context.Context, s scanner.Scanner, path string) (scanner.Verdict, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return scanner.Verdict{}, fmt.Errorf("opening quarantined file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return s.Scan(ctx, f)
+}
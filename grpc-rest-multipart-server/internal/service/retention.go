@@ -0,0 +1,416 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/audit"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/metadata"
+)
+
+const (
+	// TrashDir in the store keeps files that are trashed by retention, with their stored paths
+	TrashDir                 = ".trash"
+	defaultRetentionInterval = time.Hour
+)
+
+// retentionMetrics are published at /debug/vars
+var retentionMetrics = expvar.NewMap("retention")
+
+type (
+	// RetentionPolicy limits the store. Attachments over any limit are moved to trash and removed
+	// after the trash period. Zero limits are not applied.
+	RetentionPolicy struct {
+		Enabled bool `json:"enabled" yaml:"enabled"`
+
+		// Interval between janitor runs
+		Interval time.Duration `json:"interval" yaml:"interval" default:"1h" validate:"gte=0"`
+
+		// DryRun logs what would be removed without changing anything
+		DryRun bool `json:"dryRun" yaml:"dry-run" split_words:"true"`
+
+		MaxAge time.Duration `json:"maxAge" yaml:"max-age" split_words:"true" validate:"gte=0"`
+
+		// MaxTotalSize is in bytes on disk, i.e. after compression. The oldest attachments are removed first.
+		MaxTotalSize int64 `json:"maxTotalSize" yaml:"max-total-size" split_words:"true" validate:"gte=0"`
+
+		// The newest attachments of a title or a principal are kept
+		MaxFilesPerTitle     int `json:"maxFilesPerTitle" yaml:"max-files-per-title" split_words:"true" validate:"gte=0"`
+		MaxFilesPerPrincipal int `json:"maxFilesPerPrincipal" yaml:"max-files-per-principal" split_words:"true" validate:"gte=0"`
+
+		// TrashPeriod is how long trashed files are kept before permanent removal. 0 removes them on the next run.
+		TrashPeriod time.Duration `json:"trashPeriod" yaml:"trash-period" split_words:"true" default:"24h" validate:"gte=0"`
+	}
+
+	RetentionReport struct {
+		DryRun bool
+
+		Trashed      int
+		TrashedBytes int64
+
+		Removed        int
+		ReclaimedBytes int64
+	}
+
+	// Janitor enforces the retention policy in background. It follows config reloads.
+	Janitor struct {
+		svc *Service
+	}
+
+	retentionCandidate struct {
+		at metadata.Attachment
+
+		// files are stored files of the attachment and its derived files with sizes on disk
+		files map[string]int64
+		size  int64
+	}
+)
+
+func NewJanitor(svc *Service) *Janitor {
+	return &Janitor{svc: svc}
+}
+
+// Run enforces retention every interval until ctx is done
+func (j *Janitor) Run(ctx context.Context) error {
+
+	for {
+		interval := j.svc.Config().Retention.Interval
+		if interval <= 0 {
+			interval = defaultRetentionInterval
+		}
+
+		timer := time.NewTimer(interval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+
+		case <-timer.C:
+		}
+
+		if !j.svc.Config().Retention.Enabled {
+			continue
+		}
+
+		report, err := j.svc.EnforceRetention(time.Now(), false)
+		if err != nil {
+			j.svc.log.Errorf("enforcing retention: %v", err)
+			retentionMetrics.Add("errors", 1)
+			continue
+		}
+
+		if report.DryRun {
+			j.svc.log.Infof("retention dry run: %d attachments (%d bytes) would be trashed, %d (%d bytes) would be removed",
+				report.Trashed, report.TrashedBytes, report.Removed, report.ReclaimedBytes)
+			continue
+		}
+
+		if report.Trashed > 0 || report.Removed > 0 {
+			j.svc.log.Infof("retention: %d attachments (%d bytes) are trashed, %d are removed, %d bytes reclaimed",
+				report.Trashed, report.TrashedBytes, report.Removed, report.ReclaimedBytes)
+		}
+	}
+}
+
+// EnforceRetention moves attachments that are over the policy limits to trash and removes
+// the trashed ones which trash period is over. Only attachments in the metadata store are considered.
+// dryRun overrides the policy with a dry run. Runs of the janitor and admins don't overlap.
+func (svc *Service) EnforceRetention(now time.Time, dryRun bool) (RetentionReport, error) {
+
+	svc.retention.Lock()
+	defer svc.retention.Unlock()
+
+	config := svc.Config()
+	policy := config.Retention
+	policy.DryRun = policy.DryRun || dryRun
+	trashDir := filepath.Join(config.StoreLocation, TrashDir)
+
+	report := RetentionReport{DryRun: policy.DryRun}
+
+	uploads, err := svc.store.Uploads()
+	if err != nil {
+		return report, fmt.Errorf("reading uploads: %w", err)
+	}
+
+	var live []retentionCandidate
+	var trashed []retentionCandidate
+
+	for _, u := range uploads {
+
+		// Requests without attachments are only metadata
+		if len(u.Attachments) == 0 && policy.MaxAge > 0 && now.Sub(u.CreatedAt) > policy.MaxAge {
+			if !policy.DryRun {
+				if err := svc.store.Delete(u.ID); err != nil {
+					return report, fmt.Errorf("removing upload [%s]: %w", u.ID, err)
+				}
+
+				svc.audit(context.Background(), audit.Event{
+					Action:    audit.ActionDelete,
+					Principal: audit.ActorSystem,
+					UploadID:  u.ID,
+					Detail:    "retention: upload without attachments is over max age",
+				})
+			}
+			continue
+		}
+
+		for _, at := range u.Attachments {
+
+			dir := config.StoreLocation
+			if at.TrashedAt != nil {
+				dir = trashDir
+			}
+
+			c, err := newRetentionCandidate(dir, at)
+			if err != nil {
+				return report, err
+			}
+
+			if at.TrashedAt != nil {
+				trashed = append(trashed, c)
+			} else {
+				live = append(live, c)
+			}
+		}
+	}
+
+	for _, c := range selectOverLimits(live, policy, now) {
+
+		if policy.DryRun {
+			svc.log.Infof("retention dry run: [%s] %s would be trashed", c.at.ID, c.at.StoredName)
+		} else if err := svc.trash(c, config.StoreLocation, trashDir, now); err != nil {
+			svc.log.Errorf("trashing attachment [%s]: %v", c.at.ID, err)
+			retentionMetrics.Add("errors", 1)
+			continue
+		}
+
+		if !policy.DryRun {
+			svc.audit(context.Background(), audit.Event{
+				Action:    audit.ActionTrash,
+				Principal: audit.ActorSystem,
+				UploadID:  c.at.UploadID,
+				Files:     auditAttachment(c.at),
+				Detail:    "retention",
+			})
+		}
+
+		// Trashed files are removed in the same run if there is no trash period
+		c.at.TrashedAt = &now
+		trashed = append(trashed, c)
+
+		report.Trashed++
+		report.TrashedBytes += c.size
+	}
+
+	for _, c := range trashed {
+
+		if now.Sub(*c.at.TrashedAt) < policy.TrashPeriod {
+			continue
+		}
+
+		if policy.DryRun {
+			svc.log.Infof("retention dry run: [%s] %s would be removed", c.at.ID, c.at.StoredName)
+		} else if err := svc.purge(c, trashDir); err != nil {
+			svc.log.Errorf("removing attachment [%s]: %v", c.at.ID, err)
+			retentionMetrics.Add("errors", 1)
+			continue
+		}
+
+		if !policy.DryRun {
+			svc.audit(context.Background(), audit.Event{
+				Action:    audit.ActionDelete,
+				Principal: audit.ActorSystem,
+				UploadID:  c.at.UploadID,
+				Files:     auditAttachment(c.at),
+				Detail:    "retention: trash period is over",
+			})
+		}
+
+		report.Removed++
+		report.ReclaimedBytes += c.size
+	}
+
+	if !policy.DryRun {
+		retentionMetrics.Add("trashed_files", int64(report.Trashed))
+		retentionMetrics.Add("trashed_bytes", report.TrashedBytes)
+		retentionMetrics.Add("removed_files", int64(report.Removed))
+		retentionMetrics.Add("reclaimed_bytes", report.ReclaimedBytes)
+	}
+
+	retentionMetrics.Add("runs", 1)
+
+	lastRun := expvar.Int{}
+	lastRun.Set(now.Unix())
+	retentionMetrics.Set("last_run_unix", &lastRun)
+
+	return report, nil
+}
+
+func newRetentionCandidate(dir string, at metadata.Attachment) (retentionCandidate, error) {
+
+	c := retentionCandidate{
+		at:    at,
+		files: make(map[string]int64),
+	}
+
+	if len(at.StoredName) == 0 {
+		return c, nil
+	}
+
+	names := []string{at.StoredName}
+	for _, d := range at.Derived {
+		names = append(names, d.FileName)
+	}
+
+	for _, name := range names {
+		files, err := storedFiles(dir, name)
+		if err != nil {
+			return c, fmt.Errorf("checking files of [%s]: %w", at.ID, err)
+		}
+
+		for f, size := range files {
+			c.files[f] = size
+			c.size += size
+		}
+	}
+
+	return c, nil
+}
+
+// selectOverLimits returns attachments that break any of the limits. Each is returned once.
+func selectOverLimits(live []retentionCandidate, policy RetentionPolicy, now time.Time) []retentionCandidate {
+
+	// Newest first, so per group limits keep the newest attachments
+	sort.Slice(live, func(i, j int) bool {
+		if !live[i].at.CreatedAt.Equal(live[j].at.CreatedAt) {
+			return live[i].at.CreatedAt.After(live[j].at.CreatedAt)
+		}
+		return live[i].at.ID > live[j].at.ID
+	})
+
+	selected := make([]bool, len(live))
+
+	if policy.MaxAge > 0 {
+		for i, c := range live {
+			if now.Sub(c.at.CreatedAt) > policy.MaxAge {
+				selected[i] = true
+			}
+		}
+	}
+
+	limitGroups := func(limit int, key func(metadata.Attachment) string) {
+		if limit <= 0 {
+			return
+		}
+
+		counts := make(map[string]int)
+		for i, c := range live {
+			if selected[i] {
+				continue
+			}
+
+			k := key(c.at)
+			counts[k]++
+			if counts[k] > limit {
+				selected[i] = true
+			}
+		}
+	}
+
+	limitGroups(policy.MaxFilesPerTitle, func(at metadata.Attachment) string { return at.Title })
+	limitGroups(policy.MaxFilesPerPrincipal, func(at metadata.Attachment) string { return at.Principal })
+
+	if policy.MaxTotalSize > 0 {
+		var total int64
+		for i, c := range live {
+			if !selected[i] {
+				total += c.size
+			}
+		}
+
+		for i := len(live) - 1; i >= 0 && total > policy.MaxTotalSize; i-- {
+			if !selected[i] {
+				selected[i] = true
+				total -= live[i].size
+			}
+		}
+	}
+
+	var res []retentionCandidate
+	for i, c := range live {
+		if selected[i] {
+			res = append(res, c)
+		}
+	}
+
+	return res
+}
+
+// trash moves files to the trash dir and marks the attachment as trashed. Files that are already moved are skipped,
+// so an interrupted run could be repeated.
+func (svc *Service) trash(c retentionCandidate, storeDir, trashDir string, now time.Time) error {
+
+	for name := range c.files {
+		// Sharded files keep their subdirectories in trash
+		dst := filepath.Join(trashDir, name)
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return fmt.Errorf("creating trash: %w", err)
+		}
+
+		err := os.Rename(filepath.Join(storeDir, name), dst)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("moving [%s] to trash: %w", name, err)
+		}
+	}
+
+	return svc.store.Update(c.at.UploadID, func(u *metadata.Upload) error {
+		for i := range u.Attachments {
+			if u.Attachments[i].ID == c.at.ID {
+				u.Attachments[i].TrashedAt = &now
+			}
+		}
+		return nil
+	})
+}
+
+// purge removes trashed files and the attachment record. The upload is removed with its last attachment.
+func (svc *Service) purge(c retentionCandidate, trashDir string) error {
+
+	for name := range c.files {
+		if err := os.Remove(filepath.Join(trashDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing [%s]: %w", name, err)
+		}
+	}
+
+	empty := false
+
+	err := svc.store.Update(c.at.UploadID, func(u *metadata.Upload) error {
+		kept := u.Attachments[:0]
+		for _, at := range u.Attachments {
+			if at.ID != c.at.ID {
+				kept = append(kept, at)
+			}
+		}
+
+		u.Attachments = kept
+		empty = len(kept) == 0
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if empty {
+		return svc.store.Delete(c.at.UploadID)
+	}
+
+	return nil
+}
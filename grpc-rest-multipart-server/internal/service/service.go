@@ -3,91 +3,275 @@ package service
 import (
 	"context"
 	"fmt"
-	"github.com/hashicorp/go-multierror"
-	logger "github.com/sirupsen/logrus"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/audit"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/encryption"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/media"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/metadata"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/naming"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/scanner"
+
+	"github.com/hashicorp/go-multierror"
+	logger "github.com/sirupsen/logrus"
 )
 
 type (
 	Service struct {
-		Config
+		// config is replaced as a whole on reload, so a request always sees a consistent config
+		config atomic.Value
+
+		scanner scanner.Scanner
+
+		// keyring encrypts stored files. It's nil if encryption is disabled
+		keyring *encryption.Keyring
+
+		store *metadata.Store
+
+		// trail records uploads, downloads and deletions. It's nil if audit is disabled
+		trail *audit.Trail
+
+		// retention serializes retention runs
+		retention sync.Mutex
+
+		log *logger.Logger
 	}
 
 	Config struct {
+		// StoreLocation needs restart: the metadata store, the audit trail and recovery of interrupted uploads
+		// are opened in it at startup
 		StoreLocation string `json:"storeLocation" yaml:"store-location" split_words:"true" validate:"required"`
+
+		// QuarantineLocation keeps files until they are scanned. It's <store-location>/.quarantine by default
+		QuarantineLocation string `json:"quarantineLocation" yaml:"quarantine-location" split_words:"true"`
+
+		ContentTypes ContentTypePolicy `json:"contentTypes" yaml:"content-types" split_words:"true"`
+
+		Images media.Config `json:"images" yaml:"images"`
+
+		Compression Compression `json:"compression" yaml:"compression"`
+
+		Retention RetentionPolicy `json:"retention" yaml:"retention"`
+
+		Naming naming.Config `json:"naming" yaml:"naming"`
+
+		Limits UploadLimits `json:"limits" yaml:"limits"`
 	}
 
 	Attachment struct {
-		FileName string
-		FileData []byte
+		FileName    string
+		FileData    []byte
+		ContentType string
+	}
+
+	// AttachmentInfo is metadata of a received attachment
+	AttachmentInfo struct {
+		// FileName is the sanitized client file name. It's kept in metadata only
+		FileName     string
+		DeclaredType string
+		DetectedType string
+
+		// Size and SHA256 are of the stored content, i.e. after metadata stripping
+		Size   int64
+		SHA256 string
+
+		// ScanStatus is one of scanner statuses. Infected files are not saved
+		ScanStatus    string
+		ScanSignature string
+
+		// StoredName is a file name in the store location. Derived files are stored next to it
+		StoredName string
+
+		// StoredEncoding is compression of the stored file. Files are decompressed on read
+		StoredEncoding string
+
+		// KeyID is ID of the master key that wraps the data key of an encrypted file
+		KeyID string
+
+		Derived []media.Derived
+	}
+
+	HelloResult struct {
+		UploadID    string
+		Response    string
+		Attachments []AttachmentInfo
 	}
 )
 
-func New(config Config) *Service {
-	return &Service{
-		Config: config,
+// New creates a service. Requests are logged to request loggers of contexts or to log. trail could be nil.
+func New(
+	config Config,
+	scanner scanner.Scanner,
+	keyring *encryption.Keyring,
+	store *metadata.Store,
+	trail *audit.Trail,
+	log *logger.Logger,
+) *Service {
+	svc := Service{
+		scanner: scanner,
+		keyring: keyring,
+		store:   store,
+		trail:   trail,
+		log:     log,
+	}
+	svc.config.Store(config)
+
+	return &svc
+}
+
+func (svc *Service) Config() Config {
+	return svc.config.Load().(Config)
+}
+
+// ApplyConfig replaces config of a running service. Requests that are in progress keep the previous one.
+func (svc *Service) ApplyConfig(config Config) error {
+
+	fi, err := os.Stat(config.StoreLocation)
+	if err != nil {
+		return fmt.Errorf("checking store location: %w", err)
+	}
+
+	if !fi.IsDir() {
+		return fmt.Errorf("store location [%s] is not a directory", config.StoreLocation)
 	}
+
+	svc.config.Store(config)
+
+	return nil
 }
 
 func (svc *Service) ReactOnHello(
-	_ context.Context,
+	ctx context.Context,
 	title, description string, intValue int,
 	attachments []Attachment,
-) (string, error) {
+) (*HelloResult, error) {
+
+	log := logging.FromContext(ctx, svc.log)
+	log.Debugf("service got a Hello request: %v", title)
+
+	config := svc.Config()
 
-	logger.Debugf("service got a Hello request: %v", title)
+	if err := config.Limits.check(attachments); err != nil {
+		return nil, err
+	}
+
+	// All attachments are checked before anything is saved, so a rejected request leaves no files
+	infos := make([]AttachmentInfo, len(attachments))
+
+	for i, at := range attachments {
+
+		fileName, err := naming.Sanitize(at.FileName, config.Naming.MaxLength)
+		if err != nil {
+			return nil, fmt.Errorf("checking attachment %d: %w", i, err)
+		}
+
+		infos[i] = AttachmentInfo{
+			FileName:     fileName,
+			DeclaredType: baseType(at.ContentType),
+			DetectedType: DetectContentType(at.FileData),
+		}
+
+		mismatch, err := config.ContentTypes.Check(fileName, infos[i].DetectedType)
+		if err != nil {
+			return nil, fmt.Errorf("checking attachment [%s]: %w", fileName, err)
+		}
+
+		if mismatch {
+			log.Warnf("attachment [%s] is detected as %s", fileName, infos[i].DetectedType)
+		}
+	}
+
+	uploadID, err := metadata.NewID(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Attachments are committed together after all of them are staged, and rolled back if anything fails
+	tx, err := newTransaction(config, uploadID, log)
+	if err != nil {
+		return nil, err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.rollback()
+		}
+	}()
 
 	var resErr error
 	savedFiles := make([]string, 0, len(attachments))
+	rejectedFiles := make([]string, 0)
+	scannedInfos := make([]AttachmentInfo, 0, len(attachments))
 
-	for _, at := range attachments {
+	for i, at := range attachments {
 
 		if len(at.FileData) == 0 {
-			logger.Infof("%s: [%s: %d]. Data is nil, nothing was saved", title, description, intValue)
+			log.Infof("%s: [%s: %d]. Data is nil, nothing was saved", title, description, intValue)
 			continue
 		}
 
-		fullFileName := svc.getFilePathToSave(at.FileName)
+		if err := svc.stageAttachment(ctx, config, tx, at, &infos[i]); err != nil {
+			resErr = multierror.Append(resErr, err)
+			continue
+		}
 
-		err := os.WriteFile(fullFileName, at.FileData, 0600)
-		if err != nil {
-			resErr = multierror.Append(resErr, fmt.Errorf("failed to create file: %w", err))
+		if infos[i].ScanStatus == scanner.StatusInfected {
+			rejectedFiles = append(rejectedFiles, infos[i].FileName)
+			scannedInfos = append(scannedInfos, infos[i])
 			continue
 		}
 
-		savedFiles = append(savedFiles, at.FileName)
+		// Thumbnails are optional, so a failure doesn't fail the upload
+		if config.Images.Enabled && media.IsRaster(infos[i].DetectedType) {
+			derived, err := media.MakeThumbnails(at.FileData, infos[i].DetectedType, infos[i].StoredName,
+				config.Images.ThumbnailSizes, config.Images.MaxPixels, svc.stageDerived(ctx, config, tx))
+			if err != nil {
+				log.Errorf("making thumbnails for [%s]: %v", infos[i].FileName, err)
+			}
+			infos[i].Derived = derived
+		}
+
+		savedFiles = append(savedFiles, infos[i].FileName)
+		scannedInfos = append(scannedInfos, infos[i])
 	}
 
 	if resErr != nil {
-		return "", resErr
+		return nil, resErr
 	}
 
-	return fmt.Sprintf("%s: [%s: %d]. [%s] were saved",
-		title, description, intValue, strings.Join(savedFiles, ",")), nil
-}
+	if err := tx.commit(); err != nil {
+		return nil, err
+	}
 
-func (svc *Service) getFilePathToSave(fileName string) string {
+	if err := svc.record(ctx, uploadID, title, description, intValue, scannedInfos); err != nil {
+		return nil, err
+	}
 
-	var fn string
-	var ext string
+	committed = true
+	tx.finish()
 
-	if len(fileName) > 0 {
-		fnParts := strings.Split(fileName, ".")
-		if len(fnParts) > 0 {
-			fn = fnParts[0]
-		}
+	svc.audit(ctx, audit.Event{
+		Action:   audit.ActionUpload,
+		UploadID: uploadID,
+		Files:    auditFiles(scannedInfos),
+	})
 
-		if len(fnParts) > 1 {
-			ext = fnParts[1]
-		}
-	} else {
-		fn = "empty-filename"
-		ext = "data"
-	}
+	response := fmt.Sprintf("%s: [%s: %d]. [%s] were saved",
+		title, description, intValue, strings.Join(savedFiles, ","))
 
-	fullFilePath := fmt.Sprintf("%s/%s-%s.%s", svc.StoreLocation, fn, time.Now().UTC().Format("2006-01-02T15-04-05"), ext)
+	if len(rejectedFiles) > 0 {
+		response += fmt.Sprintf(". [%s] were rejected", strings.Join(rejectedFiles, ","))
+	}
 
-	return fullFilePath
+	return &HelloResult{
+		UploadID:    uploadID,
+		Response:    response,
+		Attachments: scannedInfos,
+	}, nil
 }
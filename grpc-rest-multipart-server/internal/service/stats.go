@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StorageStats are counted on request by walking the store, so they could be slow for large stores
+type StorageStats struct {
+	StoreLocation string
+
+	// Files are stored attachments and their derived files
+	Files int64
+	Bytes int64
+
+	TrashFiles int64
+	TrashBytes int64
+
+	QuarantineFiles int64
+	QuarantineBytes int64
+
+	// Uploads and Attachments are records of the metadata store
+	Uploads     int64
+	Attachments int64
+}
+
+func (svc *Service) StorageStats() (StorageStats, error) {
+
+	config := svc.Config()
+
+	stats := StorageStats{StoreLocation: config.StoreLocation}
+
+	var err error
+
+	// Hidden entries of the store are trash, quarantine, temporary files and the metadata store
+	if stats.Files, stats.Bytes, err = countFiles(config.StoreLocation, true); err != nil {
+		return stats, fmt.Errorf("counting stored files: %w", err)
+	}
+
+	if stats.TrashFiles, stats.TrashBytes, err = countFiles(filepath.Join(config.StoreLocation, TrashDir), false); err != nil {
+		return stats, fmt.Errorf("counting trash: %w", err)
+	}
+
+	if stats.QuarantineFiles, stats.QuarantineBytes, err = countFiles(config.quarantineLocation(), false); err != nil {
+		return stats, fmt.Errorf("counting quarantine: %w", err)
+	}
+
+	uploads, err := svc.store.Uploads()
+	if err != nil {
+		return stats, fmt.Errorf("reading uploads: %w", err)
+	}
+
+	stats.Uploads = int64(len(uploads))
+	for _, u := range uploads {
+		stats.Attachments += int64(len(u.Attachments))
+	}
+
+	return stats, nil
+}
+
+// countFiles counts regular files in dir and its subdirectories. A missing dir is empty.
+func countFiles(dir string, skipHidden bool) (int64, int64, error) {
+
+	var files, bytes int64
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if skipHidden && path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		files++
+		bytes += fi.Size()
+
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+
+	return files, bytes, err
+}
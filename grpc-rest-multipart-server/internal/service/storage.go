@@ -0,0 +1,273 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/compress"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/audit"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/metadata"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/naming"
+)
+
+// ExtEncrypted is a suffix of encrypted files. It goes after the compression suffix: file.txt.zst.enc
+const ExtEncrypted = ".enc"
+
+var ErrNotFound = errors.New("attachment is not found")
+
+// stage adds a quarantined file to the transaction. Files that are compressed or encrypted are written
+// through the pipeline with suffixes of the applied transformations, others are just moved.
+// It returns ID of the master key if the file is encrypted.
+func (svc *Service) stage(ctx context.Context, tx *transaction, src, dst, encoding string) (string, error) {
+
+	if len(encoding) == 0 && svc.keyring == nil {
+		return "", tx.add(src, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("opening quarantined file: %w", err)
+	}
+	defer func() { _ = in.Close() }()
+
+	keyID, err := svc.stageStored(ctx, tx, dst, encoding, in)
+	if err != nil {
+		return "", err
+	}
+
+	return keyID, os.Remove(src)
+}
+
+// stageStored writes a staged file: plaintext -> compression -> encryption -> file
+func (svc *Service) stageStored(ctx context.Context, tx *transaction, dst, encoding string, r io.Reader) (string, error) {
+
+	dst += compress.Extension(encoding)
+	if svc.keyring != nil {
+		dst += ExtEncrypted
+	}
+
+	out, err := tx.create(dst)
+	if err != nil {
+		return "", err
+	}
+
+	keyID, err := svc.transform(ctx, out, encoding, r)
+	if err != nil {
+		_ = out.Close()
+		tx.drop(out.Name())
+		return "", err
+	}
+
+	if err := closeSynced(out); err != nil {
+		tx.drop(out.Name())
+		return "", err
+	}
+
+	return keyID, nil
+}
+
+func (svc *Service) transform(ctx context.Context, out io.Writer, encoding string, r io.Reader) (string, error) {
+
+	var (
+		w       io.Writer = out
+		closers []io.Closer
+		keyID   string
+	)
+
+	if svc.keyring != nil {
+		encrypter, id, err := svc.keyring.Encrypt(ctx, w)
+		if err != nil {
+			return "", fmt.Errorf("encrypting file: %w", err)
+		}
+
+		w, keyID = encrypter, id
+		closers = append(closers, encrypter)
+	}
+
+	if len(encoding) > 0 {
+		zw, err := compress.NewWriter(encoding, w)
+		if err != nil {
+			return "", err
+		}
+
+		w = zw
+		closers = append(closers, zw)
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return "", fmt.Errorf("writing file: %w", err)
+	}
+
+	// Inner writers flush into outer ones, so they are closed first
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			return "", fmt.Errorf("finishing file: %w", err)
+		}
+	}
+
+	return keyID, nil
+}
+
+// stageDerived writes an artefact of an attachment, e.g. a thumbnail. It's encrypted like the original.
+func (svc *Service) stageDerived(ctx context.Context, config Config, tx *transaction) func(string, []byte) error {
+	return func(fileName string, data []byte) error {
+		_, err := svc.stageStored(ctx, tx, filepath.Join(config.StoreLocation, filepath.FromSlash(fileName)), "", bytes.NewReader(data))
+		return err
+	}
+}
+
+// OpenAttachment reads a stored file or its derived artefact by the stored name.
+// The name is relative to the store location and could include shard directories.
+// Compressed and encrypted files are decompressed and decrypted transparently. Opened files are audited as downloads.
+func (svc *Service) OpenAttachment(ctx context.Context, storedName string) (io.ReadCloser, error) {
+
+	if !naming.IsValidStoredName(storedName) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, storedName)
+	}
+
+	path := filepath.Join(svc.Config().StoreLocation, filepath.FromSlash(storedName))
+
+	for _, v := range storedVariants(path) {
+
+		f, err := os.Open(v.path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("opening file: %w", err)
+		}
+
+		rc, err := svc.reader(ctx, f, v.encoding, v.encrypted)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+
+		svc.audit(ctx, audit.Event{
+			Action: audit.ActionDownload,
+			Files:  []audit.File{{StoredName: storedName}},
+		})
+
+		return rc, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, storedName)
+}
+
+type storedVariant struct {
+	path      string
+	encoding  string
+	encrypted bool
+}
+
+// storedVariants are names a file could have on disk depending on compression and encryption
+func storedVariants(path string) []storedVariant {
+
+	var res []storedVariant
+
+	for _, encoding := range append([]string{""}, compress.Algorithms...) {
+		for _, encrypted := range []bool{false, true} {
+
+			v := storedVariant{
+				path:      path + compress.Extension(encoding),
+				encoding:  encoding,
+				encrypted: encrypted,
+			}
+			if encrypted {
+				v.path += ExtEncrypted
+			}
+
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+// storedFiles returns existing files of a stored name with their sizes. Names are relative to dir.
+func storedFiles(dir, storedName string) (map[string]int64, error) {
+
+	res := make(map[string]int64)
+
+	for _, v := range storedVariants(filepath.FromSlash(storedName)) {
+
+		fi, err := os.Stat(filepath.Join(dir, v.path))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		res[v.path] = fi.Size()
+	}
+
+	return res, nil
+}
+
+// reader reverts the store pipeline: file -> decryption -> decompression -> plaintext
+func (svc *Service) reader(ctx context.Context, f *os.File, encoding string, encrypted bool) (io.ReadCloser, error) {
+
+	res := storedFile{Reader: f, closers: []io.Closer{f}}
+
+	if encrypted {
+		if svc.keyring == nil {
+			return nil, errors.New("file is encrypted, but encryption is not configured")
+		}
+
+		dr, err := svc.keyring.Decrypt(ctx, res.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting file: %w", err)
+		}
+		res.Reader = dr
+	}
+
+	if len(encoding) > 0 {
+		zr, err := compress.NewReader(encoding, res.Reader, 0)
+		if err != nil {
+			return nil, fmt.Errorf("reading compressed file: %w", err)
+		}
+		res.Reader = zr
+		res.closers = append([]io.Closer{zr}, res.closers...)
+	}
+
+	return &res, nil
+}
+
+type storedFile struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (f *storedFile) Close() error {
+	var res error
+	for _, c := range f.closers {
+		if err := c.Close(); err != nil && res == nil {
+			res = err
+		}
+	}
+
+	return res
+}
+
+// StoredPath is the file of an attachment on disk relative to the store, with suffixes of the applied transformations
+func StoredPath(at metadata.Attachment) string {
+
+	path := at.StoredName + compress.Extension(at.StoredEncoding)
+	if len(at.KeyID) > 0 {
+		path += ExtEncrypted
+	}
+
+	return path
+}
+
+// IsEncrypted reports stored files that are written by the encryption pipeline
+func IsEncrypted(fileName string) bool {
+	return strings.HasSuffix(fileName, ExtEncrypted)
+}
@@ -0,0 +1,418 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/audit"
+
+	"github.com/hashicorp/go-multierror"
+	logger "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTmpDir = ".tmp"
+
+	journalPrefix = "journal-"
+	stagedPrefix  = "staged-"
+)
+
+var ErrFileExists = errors.New("stored file already exists")
+
+type (
+	// transaction commits files of a request together. Files are staged in <store-location>/.tmp and synced.
+	// On commit a journal is written and staged files are linked to their final names, so each file appears
+	// complete and an existing file is never replaced. Staged names are kept until the request is recorded,
+	// so a rollback removes only the files that were linked by this transaction.
+	// A journal that is left after a crash is rolled back on startup.
+	transaction struct {
+		tmpDir   string
+		storeDir string
+		journal  string
+
+		log *logger.Entry
+
+		UploadID string        `json:"uploadId"`
+		Files    []stagedFiles `json:"files"`
+	}
+
+	stagedFiles struct {
+		Staged string `json:"staged"`
+		Final  string `json:"final"`
+	}
+)
+
+func (config Config) tmpLocation() string {
+	return filepath.Join(config.StoreLocation, defaultTmpDir)
+}
+
+func newTransaction(config Config, uploadID string, log *logger.Entry) (*transaction, error) {
+
+	tx := transaction{
+		tmpDir:   config.tmpLocation(),
+		storeDir: config.StoreLocation,
+		log:      log,
+		UploadID: uploadID,
+	}
+
+	if err := os.MkdirAll(tx.tmpDir, 0700); err != nil {
+		return nil, fmt.Errorf("creating tmp dir: %w", err)
+	}
+
+	return &tx, nil
+}
+
+// create makes a staged file for the final path. The caller writes, syncs and closes it.
+func (tx *transaction) create(final string) (*os.File, error) {
+
+	f, err := os.CreateTemp(tx.tmpDir, stagedPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("creating staged file: %w", err)
+	}
+
+	tx.Files = append(tx.Files, stagedFiles{Staged: f.Name(), Final: final})
+
+	return f, nil
+}
+
+// drop removes a staged file that failed to be written, so it's not committed
+func (tx *transaction) drop(staged string) {
+
+	for i, f := range tx.Files {
+		if f.Staged == staged {
+			tx.Files = append(tx.Files[:i], tx.Files[i+1:]...)
+			break
+		}
+	}
+
+	if err := os.Remove(staged); err != nil && !errors.Is(err, os.ErrNotExist) {
+		tx.log.Errorf("removing staged file [%s]: %v", staged, err)
+	}
+}
+
+// add stages an existing file, e.g. a quarantined one. It's moved to tmp dir, or copied if it's on another device.
+func (tx *transaction) add(src, final string) error {
+
+	staged, err := tx.create(final)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.move(src, staged); err != nil {
+		tx.drop(staged.Name())
+		return err
+	}
+
+	return nil
+}
+
+func (tx *transaction) move(src string, staged *os.File) error {
+
+	if err := os.Rename(src, staged.Name()); err == nil {
+		_ = staged.Close()
+		return syncFile(staged.Name())
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		_ = staged.Close()
+		return fmt.Errorf("opening file: %w", err)
+	}
+	defer func() { _ = in.Close() }()
+
+	if _, err := staged.ReadFrom(in); err != nil {
+		_ = staged.Close()
+		return fmt.Errorf("copying file: %w", err)
+	}
+
+	if err := closeSynced(staged); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// commit links staged files to their final names. On failure linked files are removed.
+func (tx *transaction) commit() error {
+
+	if len(tx.Files) == 0 {
+		return nil
+	}
+
+	if err := tx.writeJournal(); err != nil {
+		tx.rollback()
+		return err
+	}
+
+	for _, f := range tx.Files {
+		// Sharded names need their subdirectories
+		if err := os.MkdirAll(filepath.Dir(f.Final), 0700); err != nil {
+			tx.rollback()
+			return fmt.Errorf("creating dir of [%s]: %w", filepath.Base(f.Final), err)
+		}
+
+		if err := os.Link(f.Staged, f.Final); err != nil {
+			tx.rollback()
+
+			if errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("%w: %s", ErrFileExists, filepath.Base(f.Final))
+			}
+			return fmt.Errorf("committing [%s]: %w", filepath.Base(f.Final), err)
+		}
+	}
+
+	if err := tx.syncFinalDirs(); err != nil {
+		tx.rollback()
+		return err
+	}
+
+	return nil
+}
+
+// syncFinalDirs syncs dirs of the final names and their parents up to the store location,
+// so subdirectories that were created on commit are durable too
+func (tx *transaction) syncFinalDirs() error {
+
+	root := filepath.Clean(tx.storeDir)
+	synced := make(map[string]bool)
+
+	for _, f := range tx.Files {
+		for dir := filepath.Dir(f.Final); !synced[dir]; dir = filepath.Dir(dir) {
+
+			if err := syncDir(dir); err != nil {
+				return err
+			}
+			synced[dir] = true
+
+			if dir == root || dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// finish drops the journal and staged names after the request is recorded. Final files stay.
+func (tx *transaction) finish() {
+
+	if len(tx.journal) > 0 {
+		if err := os.Remove(tx.journal); err != nil {
+			tx.log.Errorf("removing journal [%s]: %v", tx.journal, err)
+		}
+	}
+
+	for _, f := range tx.Files {
+		if err := os.Remove(f.Staged); err != nil && !errors.Is(err, os.ErrNotExist) {
+			tx.log.Errorf("removing staged file [%s]: %v", f.Staged, err)
+		}
+	}
+}
+
+// rollback removes final files that are linked to staged ones, staged files and the journal
+func (tx *transaction) rollback() {
+	if err := tx.undo(); err != nil {
+		tx.log.Errorf("rolling back upload [%s]: %v", tx.UploadID, err)
+	}
+}
+
+func (tx *transaction) undo() error {
+
+	var resErr error
+
+	for _, f := range tx.Files {
+
+		staged, errStaged := os.Stat(f.Staged)
+		final, errFinal := os.Stat(f.Final)
+
+		if errStaged == nil && errFinal == nil && os.SameFile(staged, final) {
+			if err := os.Remove(f.Final); err != nil {
+				resErr = multierror.Append(resErr, err)
+			}
+		}
+
+		if err := os.Remove(f.Staged); err != nil && !errors.Is(err, os.ErrNotExist) {
+			resErr = multierror.Append(resErr, err)
+		}
+	}
+
+	if len(tx.journal) > 0 {
+		if err := os.Remove(tx.journal); err != nil && !errors.Is(err, os.ErrNotExist) {
+			resErr = multierror.Append(resErr, err)
+		}
+	}
+
+	return resErr
+}
+
+func (tx *transaction) writeJournal() error {
+
+	buf, err := json.Marshal(tx)
+	if err != nil {
+		return fmt.Errorf("marshalling journal: %w", err)
+	}
+
+	f, err := os.CreateTemp(tx.tmpDir, journalPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("creating journal: %w", err)
+	}
+	tx.journal = f.Name()
+
+	if _, err := f.Write(buf); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing journal: %w", err)
+	}
+
+	if err := closeSynced(f); err != nil {
+		return err
+	}
+
+	return syncDir(tx.tmpDir)
+}
+
+// Recover rolls back requests that were interrupted by a crash and removes orphaned temporary
+// and quarantined files. It should be called on startup before requests are served.
+func (svc *Service) Recover() error {
+
+	config := svc.Config()
+	tmpDir := config.tmpLocation()
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading tmp dir: %w", err)
+	}
+
+	// Journals go first, they refer to staged files
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), journalPrefix) {
+			continue
+		}
+
+		if err := svc.recoverJournal(filepath.Join(tmpDir, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	removed, err := removeAll(tmpDir, stagedPrefix)
+	if err != nil {
+		return fmt.Errorf("cleaning tmp dir: %w", err)
+	}
+
+	quarantined, err := removeAll(config.quarantineLocation(), quarantinePrefix)
+	if err != nil {
+		return fmt.Errorf("cleaning quarantine: %w", err)
+	}
+
+	if removed+quarantined > 0 {
+		svc.log.Infof("recovery: %d orphaned temporary and %d quarantined files are removed", removed, quarantined)
+	}
+
+	return nil
+}
+
+func (svc *Service) recoverJournal(path string) error {
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading journal: %w", err)
+	}
+
+	tx := transaction{journal: path, log: logger.NewEntry(svc.log)}
+
+	// A journal that is not completely written means that nothing was linked
+	if err := json.Unmarshal(buf, &tx); err != nil {
+		svc.log.Warnf("recovery: journal [%s] is incomplete, removing it", path)
+		return os.Remove(path)
+	}
+
+	if err := tx.undo(); err != nil {
+		return fmt.Errorf("rolling back upload [%s]: %w", tx.UploadID, err)
+	}
+
+	if len(tx.UploadID) > 0 {
+		if err := svc.store.Delete(tx.UploadID); err != nil {
+			return fmt.Errorf("removing upload [%s]: %w", tx.UploadID, err)
+		}
+	}
+
+	svc.log.Warnf("recovery: interrupted upload [%s] is rolled back, %d files", tx.UploadID, len(tx.Files))
+
+	svc.audit(context.Background(), audit.Event{
+		Action:    audit.ActionRollback,
+		Principal: audit.ActorSystem,
+		UploadID:  tx.UploadID,
+		Detail:    fmt.Sprintf("recovery: interrupted upload, %d files are removed", len(tx.Files)),
+	})
+
+	return nil
+}
+
+func removeAll(dir, prefix string) (int, error) {
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+func closeSynced(f *os.File) error {
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("syncing [%s]: %w", f.Name(), err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing [%s]: %w", f.Name(), err)
+	}
+
+	return nil
+}
+
+func syncFile(path string) error {
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	return closeSynced(f)
+}
+
+// syncDir makes created and renamed entries of the dir durable
+func syncDir(dir string) error {
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("syncing dir [%s]: %w", dir, err)
+	}
+
+	return nil
+}
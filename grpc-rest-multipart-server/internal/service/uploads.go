@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/metadata"
+)
+
+// record saves the request and its attachments to the metadata store
+func (svc *Service) record(ctx context.Context, uploadID, title, description string, intValue int, infos []AttachmentInfo) error {
+
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		principal = PrincipalAnonymous
+	}
+
+	upload := metadata.Upload{
+		ID:          uploadID,
+		Title:       title,
+		Description: description,
+		IntValue:    intValue,
+		Principal:   principal,
+		Attachments: make([]metadata.Attachment, 0, len(infos)),
+	}
+
+	for _, info := range infos {
+		at := metadata.Attachment{
+			FileName:       info.FileName,
+			Size:           info.Size,
+			SHA256:         info.SHA256,
+			DeclaredType:   info.DeclaredType,
+			DetectedType:   info.DetectedType,
+			ScanStatus:     info.ScanStatus,
+			ScanSignature:  info.ScanSignature,
+			StoredName:     info.StoredName,
+			StoredEncoding: info.StoredEncoding,
+			KeyID:          info.KeyID,
+		}
+
+		for _, d := range info.Derived {
+			at.Derived = append(at.Derived, metadata.Derived{
+				Kind:     d.Kind,
+				FileName: d.FileName,
+				Width:    d.Width,
+				Height:   d.Height,
+			})
+		}
+
+		upload.Attachments = append(upload.Attachments, at)
+	}
+
+	if err := svc.store.Save(&upload); err != nil {
+		return fmt.Errorf("recording upload: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *Service) ListAttachments(_ context.Context, q metadata.Query) (*metadata.Page, error) {
+	return svc.store.ListAttachments(q)
+}
+
+func (svc *Service) GetUpload(_ context.Context, id string) (*metadata.Upload, error) {
+	return svc.store.Get(id)
+}
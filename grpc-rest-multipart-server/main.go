@@ -5,12 +5,19 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/audit"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/encryption"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/grpc"
-	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/log"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/metadata"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/opts"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/scanner"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
 
 	logger "github.com/sirupsen/logrus"
@@ -26,37 +33,127 @@ func main() {
 
 func setup() error {
 
-	configFile := os.Getenv("CONFIG_FILE")
+	cfg, loadResult, err := opts.Load(os.Args[1:])
+	if err != nil {
+		return fmt.Errorf("building opts: %w", err)
+	}
 
-	config := opts.Config{}
+	if loadResult.PrintConfig {
+		return config.Print(os.Stdout, cfg, loadResult)
+	}
 
-	err := opts.LoadConfigFromFileOrEnvs(configFile, &config)
+	log, err := logging.New(cfg.Log)
 	if err != nil {
-		return fmt.Errorf("building opts: %w", err)
+		return fmt.Errorf("creating logger: %w", err)
 	}
+	defer func() { _ = logging.Close(log) }()
+
+	configManager, err := config.NewManager(cfg, loadResult.Files, func() (*opts.Config, error) {
+		cfg, _, err := opts.Load(os.Args[1:])
+		return cfg, err
+	}, log, opts.ReloadablePaths...)
+	if err != nil {
+		return fmt.Errorf("creating config manager: %w", err)
+	}
+	configManager.RefreshEvery(loadResult.SecretsRefresh)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	initLogger(config.Log)
-
-	return run(ctx, config)
+	return run(ctx, configManager, log)
 }
 
-func run(ctx context.Context, config opts.Config) error {
+func run(ctx context.Context, configManager *config.Manager[*opts.Config], log *logger.Logger) error {
 
-	svc := service.New(config.Service)
+	cfg := configManager.Current()
+
+	scn, err := scanner.New(cfg.Scanner)
+	if err != nil {
+		return fmt.Errorf("creating scanner: %w", err)
+	}
+
+	var keyring *encryption.Keyring
+	if cfg.Encryption.Enabled {
+		if keyring, err = encryption.New(cfg.Encryption); err != nil {
+			return fmt.Errorf("creating keyring: %w", err)
+		}
+	}
+
+	store, err := metadata.Open(cfg.Metadata.FilePath(cfg.Service.StoreLocation))
+	if err != nil {
+		return fmt.Errorf("opening metadata store: %w", err)
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			log.Errorf("closing metadata store: %v", err)
+		}
+	}()
+
+	var trail *audit.Trail
+	if cfg.Audit.Enabled {
+		auditConfig := cfg.Audit
+		if len(auditConfig.Path) == 0 {
+			auditConfig.Path = filepath.Join(cfg.Service.StoreLocation, audit.DefaultFileName)
+		}
+
+		if trail, err = audit.Open(auditConfig); err != nil {
+			return fmt.Errorf("opening audit trail: %w", err)
+		}
+		defer func() {
+			if err := trail.Close(); err != nil {
+				log.Errorf("closing audit trail: %v", err)
+			}
+		}()
+
+		log.Infof("auditing to %s", auditConfig.Path)
+	}
+
+	svc := service.New(cfg.Service, scn, keyring, store, trail, log)
+
+	if err := svc.Recover(); err != nil {
+		return fmt.Errorf("recovering store: %w", err)
+	}
 
 	resolver, err := grpc.NewResolver(svc)
 	if err != nil {
 		return fmt.Errorf("creating grpc resolver: %w", err)
 	}
 
-	grpcServer, err := grpc.NewServer(config.GRPC, resolver)
+	var tracker *admin.Tracker
+	if cfg.Admin.Enabled {
+		tracker = admin.NewTracker()
+	}
+
+	grpcServer, err := grpc.NewServer(cfg.GRPC, resolver, service.NewJanitor(svc), tracker, log)
 	if err != nil {
 		return fmt.Errorf("creating grpc server: %w", err)
 	}
 
+	// Components that may reject a config go first, so less has to be rolled back
+	configManager.OnReload(func(cfg *opts.Config) error { return svc.ApplyConfig(cfg.Service) })
+	configManager.OnReload(func(cfg *opts.Config) error { return logging.Apply(log, cfg.Log) })
+	configManager.OnReload(func(cfg *opts.Config) error { return grpcServer.ApplyConfig(cfg.GRPC) })
+
+	if trail != nil {
+		configManager.OnReport(auditReload(trail, log))
+	}
+
+	if cfg.Admin.Enabled {
+		adminServer := admin.New(cfg.Admin, "grpc-rest-multipart-server", configManager, tracker, adminStorage{svc: svc}, log)
+		if trail != nil {
+			adminServer.OnAudit(auditAdmin(trail, log))
+		}
+		if err := adminServer.Start(ctx); err != nil {
+			return fmt.Errorf("starting admin server: %w", err)
+		}
+	}
+
+	go func() {
+		if err := configManager.Run(ctx); err != nil {
+			log.Errorf("config reload is disabled: %v", err)
+		}
+	}()
+
 	if err := grpcServer.Run(ctx); err != nil {
 		return fmt.Errorf("server run: %w", err)
 	}
@@ -64,23 +161,33 @@ func run(ctx context.Context, config opts.Config) error {
 	return nil
 }
 
-func initLogger(config log.Config) {
-
-	var lvl logger.Level
-
-	switch strings.ToUpper(config.Level) {
-	case "TRACE":
-		lvl = logger.TraceLevel
-	case "DEBUG":
-		lvl = logger.DebugLevel
-	case "INFO":
-		lvl = logger.InfoLevel
-	case "ERROR":
-		lvl = logger.ErrorLevel
-	}
-
-	logger.SetLevel(lvl)
-	if !config.NonJson {
-		logger.SetFormatter(&logger.JSONFormatter{PrettyPrint: config.Pretty})
+// auditReload records reloads that change config or fail. Reloads without changes, e.g. secret refreshes, are skipped.
+func auditReload(trail *audit.Trail, log *logger.Logger) config.ReportFunc {
+	return func(report config.Report, err error) {
+
+		var details []string
+
+		if err != nil {
+			details = append(details, "failed: "+err.Error())
+		}
+		if len(report.Applied) > 0 {
+			details = append(details, fmt.Sprintf("applied: [%s]", strings.Join(report.Applied, ",")))
+		}
+		if len(report.Ignored) > 0 {
+			details = append(details, fmt.Sprintf("ignored until restart: [%s]", strings.Join(report.Ignored, ",")))
+		}
+
+		if len(details) == 0 {
+			return
+		}
+
+		err = trail.Record(audit.Event{
+			Action:    audit.ActionConfigReload,
+			Principal: audit.ActorSystem,
+			Detail:    strings.Join(details, ", "),
+		})
+		if err != nil {
+			log.Errorf("recording audit event: %v", err)
+		}
 	}
 }
@@ -0,0 +1,233 @@
+// Package testserver runs the server in-process for tests: gRPC over bufconn and REST over httptest.
+// Files are stored in a temp dir. Servers are stopped and the dir is removed when the test ends.
+package testserver
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/encryption"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/grpc"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/metadata"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/opts"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/scanner"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-multipart-server/internal/service"
+
+	goGrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Target is a gRPC target to dial with DialOptions
+	Target = "bufnet"
+
+	bufSize = 1 << 20
+)
+
+type (
+	Server struct {
+		// URL is the base URL of the REST API
+		URL string
+
+		// StoreDir is the store location
+		StoreDir string
+
+		lis *bufconn.Listener
+		svc *service.Service
+	}
+
+	// Option changes the server before it starts
+	Option func(st *settings) error
+
+	settings struct {
+		cfg  opts.Config
+		wrap func(grpc.Service) grpc.Service
+	}
+)
+
+// WithYAML overlays config with a YAML document in the config file format, e.g. "service: {images: {enabled: true}}".
+// The store location is always a temp dir.
+func WithYAML(doc string) Option {
+	return func(st *settings) error {
+		return yaml.Unmarshal([]byte(doc), &st.cfg)
+	}
+}
+
+// WrapService puts wrap between transports and the service, e.g. to record service calls
+func WrapService(wrap func(grpc.Service) grpc.Service) Option {
+	return func(st *settings) error {
+		st.wrap = wrap
+		return nil
+	}
+}
+
+// Start runs a server with the default config and options
+func Start(t testing.TB, options ...Option) *Server {
+	t.Helper()
+
+	st := settings{}
+	if err := config.Defaults(&st.cfg); err != nil {
+		t.Fatalf("applying config defaults: %v", err)
+	}
+
+	for _, opt := range options {
+		if err := opt(&st); err != nil {
+			t.Fatalf("applying option: %v", err)
+		}
+	}
+	cfg := st.cfg
+
+	s := Server{
+		StoreDir: t.TempDir(),
+		lis:      bufconn.Listen(bufSize),
+	}
+	cfg.Service.StoreLocation = s.StoreDir
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validating config: %v", err)
+	}
+
+	scn, err := scanner.New(cfg.Scanner)
+	if err != nil {
+		t.Fatalf("creating scanner: %v", err)
+	}
+
+	var keyring *encryption.Keyring
+	if cfg.Encryption.Enabled {
+		if keyring, err = encryption.New(cfg.Encryption); err != nil {
+			t.Fatalf("creating keyring: %v", err)
+		}
+	}
+
+	store, err := metadata.Open(filepath.Join(s.StoreDir, metadata.DefaultFileName))
+	if err != nil {
+		t.Fatalf("opening metadata store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	log, err := logging.New(cfg.Log)
+	if err != nil {
+		t.Fatalf("creating logger: %v", err)
+	}
+
+	s.svc = service.New(cfg.Service, scn, keyring, store, nil, log)
+
+	var svc grpc.Service = s.svc
+	if st.wrap != nil {
+		svc = st.wrap(svc)
+	}
+
+	resolver, err := grpc.NewResolver(svc)
+	if err != nil {
+		t.Fatalf("creating grpc resolver: %v", err)
+	}
+
+	srv, err := grpc.NewServer(cfg.GRPC, resolver, nil, nil, log)
+	if err != nil {
+		t.Fatalf("creating grpc server: %v", err)
+	}
+
+	grpcServer := srv.NewGrpcServer()
+	go func() { _ = grpcServer.Serve(s.lis) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := goGrpc.DialContext(context.Background(), Target, append(srv.GatewayDialOptions(), s.dialer())...)
+	if err != nil {
+		t.Fatalf("dialing grpc server: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	handler, err := srv.RestHandler(conn)
+	if err != nil {
+		t.Fatalf("creating rest handler: %v", err)
+	}
+
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	s.URL = ts.URL
+
+	return &s
+}
+
+// DialOptions connect a gRPC client to the server. They are used with Target.
+func (s *Server) DialOptions() []goGrpc.DialOption {
+	return []goGrpc.DialOption{
+		s.dialer(),
+		goGrpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+}
+
+func (s *Server) dialer() goGrpc.DialOption {
+	return goGrpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return s.lis.DialContext(ctx)
+	})
+}
+
+// StoredFiles returns sorted slash separated names of files in the store. Internal dot dirs and files are skipped.
+func (s *Server) StoredFiles(t testing.TB) []string {
+	t.Helper()
+
+	var res []string
+
+	err := filepath.WalkDir(s.StoreDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(d.Name(), ".") && path != s.StoreDir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.StoreDir, path)
+		if err != nil {
+			return err
+		}
+
+		res = append(res, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("listing store: %v", err)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// ReadStored reads a stored file by its stored name the way it's served, i.e. decompressed and decrypted
+func (s *Server) ReadStored(t testing.TB, storedName string) []byte {
+	t.Helper()
+
+	rc, err := s.svc.OpenAttachment(context.Background(), storedName)
+	if err != nil {
+		t.Fatalf("opening stored file [%s]: %v", storedName, err)
+	}
+	defer func() { _ = rc.Close() }()
+
+	buf, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("reading stored file [%s]: %v", storedName, err)
+	}
+
+	return buf
+}
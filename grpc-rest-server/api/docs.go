@@ -0,0 +1,10 @@
+package api
+
+import (
+	_ "embed"
+)
+
+// SwaggerDoc is generated by protoc-gen-openapiv2 (make protogen_swag)
+//
+//go:embed service.swagger.json
+var SwaggerDoc []byte
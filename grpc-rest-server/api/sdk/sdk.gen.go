@@ -0,0 +1,207 @@
+// Code generated by openapi-sdkgen from service.swagger.json. DO NOT EDIT.
+
+// Package sdk is a REST client for service.proto (version not set).
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+type ProtobufAny map[string]interface{}
+
+type RpcStatus struct {
+	Code    int32         `json:"code,omitempty"`
+	Details []ProtobufAny `json:"details,omitempty"`
+	Message string        `json:"message,omitempty"`
+}
+
+type SayHelloRequest struct {
+	Description string `json:"description,omitempty"`
+	IntValue    int64  `json:"intValue,omitempty,string"`
+	Title       string `json:"title,omitempty"`
+}
+
+type SayHelloResponse struct {
+	Response string `json:"response,omitempty"`
+}
+
+// HttpDoer is satisfied by *http.Client. It allows to add retries, tracing etc.
+type HttpDoer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+type Client struct {
+	baseURL    string
+	httpClient HttpDoer
+}
+
+type Option func(*Client)
+
+// WithHttpClient sets a client that sends requests. http.DefaultClient is used by default.
+func WithHttpClient(httpClient HttpDoer) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func NewClient(baseURL string, opts ...Option) (*Client, error) {
+
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("bad base url [%s]: %w", baseURL, err)
+	}
+
+	c := Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return &c, nil
+}
+
+// Error is returned when server responds with an unexpected status code.
+type Error struct {
+	StatusCode int
+	Body       []byte
+
+	// Status is a decoded error response. It's nil if the body doesn't match the schema.
+	Status *RpcStatus
+}
+
+func (e *Error) Error() string {
+	if e.Status != nil {
+		return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Status.Message)
+	}
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, string(e.Body))
+}
+
+// FilePart is a file that is streamed to the server as a multipart part.
+type FilePart struct {
+	FileName    string
+	ContentType string
+	Reader      io.Reader
+}
+
+// SayHello sends /v1/sayhello
+func (c *Client) SayHello(ctx context.Context, body *SayHelloRequest) (*SayHelloResponse, error) {
+
+	var reqBody io.Reader
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+	reqBody = bytes.NewReader(buf)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/sayhello", reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := SayHelloResponse{}
+	if err := c.do(req, &resp, 200); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+func (c *Client) do(req *http.Request, res interface{}, successCodes ...int) error {
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	for _, code := range successCodes {
+		if resp.StatusCode != code {
+			continue
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+			return fmt.Errorf("decoding response: %w", err)
+		}
+
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading error response: %w", err)
+	}
+
+	resErr := Error{
+		StatusCode: resp.StatusCode,
+		Body:       body,
+	}
+
+	status := RpcStatus{}
+	if err := json.Unmarshal(body, &status); err == nil {
+		resErr.Status = &status
+	}
+
+	return &resErr
+}
+
+func writeJsonPart(mpw *multipart.Writer, name, contentType string, v interface{}) error {
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, name))
+	h.Set("Content-Type", contentType)
+
+	w, err := mpw.CreatePart(h)
+	if err != nil {
+		return fmt.Errorf("creating part [%s]: %w", name, err)
+	}
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("writing part [%s]: %w", name, err)
+	}
+
+	return nil
+}
+
+func writeFilePart(mpw *multipart.Writer, name, contentType string, f FilePart) error {
+
+	if len(f.ContentType) > 0 {
+		contentType = f.ContentType
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, name, escapeQuotes(f.FileName)))
+	h.Set("Content-Type", contentType)
+
+	w, err := mpw.CreatePart(h)
+	if err != nil {
+		return fmt.Errorf("creating part [%s]: %w", name, err)
+	}
+
+	if _, err := io.Copy(w, f.Reader); err != nil {
+		return fmt.Errorf("writing part [%s]: %w", name, err)
+	}
+
+	return nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
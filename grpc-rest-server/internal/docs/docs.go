@@ -1,21 +1,32 @@
 package docs
 
 import (
-	"os"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/openapi"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/api"
 
 	"github.com/swaggo/swag"
 )
 
+// InstanceName is the swag instance of the document. Servers have their own, so both could run in one process.
+const InstanceName = "grpc-rest-server"
+
+var Docs *openapi.Docs
+
 type s struct{}
 
 func (s *s) ReadDoc() string {
-	doc, err := os.ReadFile("./api/service.swagger.json")
-	if err != nil {
-		panic("cannot load protocol.swagger.json")
-	}
-	return string(doc)
+	return string(Docs.V2)
 }
 
 func init() {
-	swag.Register(swag.Name, &s{})
+
+	// Document is embedded, so it can fail only if generated document is broken
+	docs, err := openapi.NewDocs(api.SwaggerDoc)
+	if err != nil {
+		panic("cannot load service.swagger.json: " + err.Error())
+	}
+
+	Docs = docs
+
+	swag.Register(InstanceName, &s{})
 }
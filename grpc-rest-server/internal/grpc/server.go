@@ -5,40 +5,113 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/textproto"
+	"sync/atomic"
 	"time"
 
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin"
+	// compress also registers gzip and zstd gRPC compressors, the server responds with the compressor of a request
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/compress"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/debugdump"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/gateway"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/limits"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/openapi"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/recording"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/api"
-	_ "github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/internal/docs"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/internal/docs"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	logger "github.com/sirupsen/logrus"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 type (
 	Server struct {
 		host           string
-		gatewayTimeout time.Duration
+		gatewayTimeout atomic.Int64
 		restHost       string
+		limits         limits.Config
 		resolver       *Resolver
+		recorder       *recording.Recorder
+		dumper         *debugdump.Dumper
+		tracker        *admin.Tracker
+		log            *logger.Logger
 	}
 
 	Config struct {
 		Host           string        `json:"host" yaml:"host" split_words:"true"`
-		GatewayTimeout time.Duration `json:"gatewayTimeout" yaml:"gateway-timeout" split_words:"true"`
+		GatewayTimeout time.Duration `json:"gatewayTimeout" yaml:"gateway-timeout" split_words:"true" default:"10s"`
 		RestHost       string        `json:"restHost" yaml:"rest-host" split_words:"true"`
+
+		// Limits are applied on start
+		Limits limits.Config `json:"limits" yaml:"limits"`
+
+		// Recording captures incoming requests to an archive that grpc-rest-client replays
+		Recording recording.Config `json:"recording" yaml:"recording"`
+
+		// DebugDump logs requests and responses. It's reloadable, so dumps could be enabled for a route at runtime.
+		DebugDump debugdump.Config `json:"debugDump" yaml:"debug-dump" split_words:"true"`
 	}
 )
 
-func NewServer(config Config, resolver *Resolver) (*Server, error) {
+// NewServer creates a server. tracker could be nil, it keeps connections and requests for the admin server.
+func NewServer(config Config, resolver *Resolver, tracker *admin.Tracker, log *logger.Logger) (*Server, error) {
+
+	s := Server{
+		host:     config.Host,
+		restHost: config.RestHost,
+		limits:   config.Limits,
+		resolver: resolver,
+		dumper:   debugdump.New(config.DebugDump, log),
+		tracker:  tracker,
+		log:      log,
+	}
+	s.gatewayTimeout.Store(int64(config.GatewayTimeout))
+
+	if config.Recording.Enabled {
+		recorder, err := recording.NewRecorder(config.Recording, "grpc-rest-server", log)
+		if err != nil {
+			return nil, fmt.Errorf("creating recorder: %w", err)
+		}
+		s.recorder = recorder
+
+		log.Infof("recording requests to %s", recorder.Dir())
+	}
+
+	return &s, nil
+}
 
-	return &Server{
-		host:           config.Host,
-		gatewayTimeout: config.GatewayTimeout,
-		restHost:       config.RestHost,
-		resolver:       resolver,
-	}, nil
+// ApplyConfig updates reloadable settings of a running server. Hosts can't be changed without restart.
+func (s *Server) ApplyConfig(config Config) error {
+	s.gatewayTimeout.Store(int64(config.GatewayTimeout))
+	s.dumper.Apply(config.DebugDump)
+	return nil
+}
+
+func (s *Server) GatewayTimeout() time.Duration {
+	return time.Duration(s.gatewayTimeout.Load())
+}
+
+// withGatewayTimeout limits gateway requests with the current gateway timeout
+func (s *Server) withGatewayTimeout(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		timeout := s.GatewayTimeout()
+		if timeout <= 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
 func (s *Server) ServeGrpc(grpcServer *grpc.Server) error {
@@ -53,49 +126,150 @@ func (s *Server) ServeGrpc(grpcServer *grpc.Server) error {
 
 func (s *Server) ServeHttp(ctx context.Context) error {
 
-	ctxDial, cancel := context.WithTimeout(ctx, s.gatewayTimeout)
+	ctxDial, cancel := context.WithTimeout(ctx, s.GatewayTimeout())
 	defer cancel()
 
-	conn, err := grpc.DialContext(
-		ctxDial,
-		fmt.Sprintf(s.host),
-		grpc.WithBlock(),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	conn, err := grpc.DialContext(ctxDial, s.host, append(s.GatewayDialOptions(), grpc.WithBlock())...)
 	if err != nil {
 		return fmt.Errorf("dialing grpc connection: %w", err)
 	}
 
-	gwmux := runtime.NewServeMux()
+	handler, err := s.RestHandler(conn)
+	if err != nil {
+		return err
+	}
+
+	gwServer := http.Server{
+		Addr:              s.restHost,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	if s.tracker != nil {
+		gwServer.ConnState = s.tracker.ConnState
+	}
+
+	return gwServer.ListenAndServe()
+}
+
+// GatewayDialOptions are options of the gateway connection to the gRPC server
+func (s *Server) GatewayDialOptions() []grpc.DialOption {
+	return append(gateway.DialOptions(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(s.limits.CallOptions()...),
+	)
+}
+
+// RestHandler serves REST API over conn to the gRPC server. It lets REST run without listening on RestHost.
+func (s *Server) RestHandler(conn *grpc.ClientConn) (http.Handler, error) {
+
+	gwmux := runtime.NewServeMux(
+		runtime.WithErrorHandler(gatewayErrorHandler),
+		runtime.WithIncomingHeaderMatcher(incomingHeader),
+	)
 
 	// Register Greeter
-	err = api.RegisterGrpcRestServiceHandler(context.Background(), gwmux, conn)
+	err := api.RegisterGrpcRestServiceHandler(context.Background(), gwmux, conn)
 	if err != nil {
-		return fmt.Errorf("dialing grpc server: %w", err)
+		return nil, fmt.Errorf("dialing grpc server: %w", err)
 	}
 
 	if err = gwmux.HandlePath("GET", "/swagger/*", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
-		httpSwagger.WrapHandler(w, r)
+		swaggerHandler(w, r)
 	}); err != nil {
-		return fmt.Errorf("handle GET /swagger/*: %w", err)
+		return nil, fmt.Errorf("handle GET /swagger/*: %w", err)
 	}
 
-	gwServer := http.Server{
-		Addr:              s.restHost,
-		Handler:           gwmux,
-		ReadHeaderTimeout: 5 * time.Second,
+	if err = gwmux.HandlePath("GET", openapi.PathSwagger, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		docs.Docs.SwaggerHandler()(w, r)
+	}); err != nil {
+		return nil, fmt.Errorf("handle GET %s: %w", openapi.PathSwagger, err)
 	}
 
-	return gwServer.ListenAndServe()
+	if err = gwmux.HandlePath("GET", openapi.PathOpenAPI, func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+		docs.Docs.OpenAPIHandler()(w, r)
+	}); err != nil {
+		return nil, fmt.Errorf("handle GET %s: %w", openapi.PathOpenAPI, err)
+	}
+
+	handler := s.record(s.dumper.Middleware(s.withGatewayTimeout(gwmux)))
+
+	handler = s.tracker.Middleware(compress.Middleware(int64(s.limits.MaxRequestSize), limits.Middleware(int64(s.limits.MaxRequestSize), handler)))
+
+	return logging.Middleware(s.log, handler), nil
 }
 
-func (s *Server) Run(ctx context.Context) error {
+// incomingHeader forwards the request ID to gRPC metadata in addition to the default headers
+func incomingHeader(key string) (string, bool) {
+	if textproto.CanonicalMIMEHeaderKey(key) == logging.HeaderRequestID {
+		return logging.MetadataRequestID, true
+	}
+
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// record wraps h with the recorder if recording is enabled
+func (s *Server) record(h http.Handler) http.Handler {
+	if s.recorder == nil {
+		return h
+	}
+
+	return s.recorder.Middleware(h)
+}
+
+var swaggerHandler = httpSwagger.Handler(httpSwagger.InstanceName(docs.InstanceName))
 
-	var opts []grpc.ServerOption
+// gatewayErrorHandler responds 413 to bodies that are cut by the size limit and to messages
+// that the gRPC server rejects as too large. The gateway reports them as 400 and 429 otherwise.
+func gatewayErrorHandler(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+
+	if limits.TooLarge(r) || status.Code(err) == codes.ResourceExhausted {
+		err = &runtime.HTTPStatusError{
+			HTTPStatus: http.StatusRequestEntityTooLarge,
+			Err:        status.Error(codes.ResourceExhausted, status.Convert(err).Message()),
+		}
+	}
+
+	runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+}
+
+// NewGrpcServer creates a gRPC server with the service registered. It's served by Run or on any listener.
+func (s *Server) NewGrpcServer() *grpc.Server {
+
+	interceptors := []grpc.UnaryServerInterceptor{
+		logging.UnaryInterceptor(s.log),
+		s.tracker.UnaryInterceptor(),
+		s.dumper.UnaryInterceptor(),
+	}
+	if s.recorder != nil {
+		interceptors = append(interceptors, s.recorder.UnaryInterceptor())
+	}
+
+	// zstd messages are decoded before gRPC checks their size, so decoders are limited too
+	compress.SetGrpcMaxMessageSize(s.limits.MaxRequestSize)
+
+	opts := append(s.limits.ServerOptions(), grpc.ChainUnaryInterceptor(interceptors...))
+	if s.tracker != nil {
+		opts = append(opts, grpc.StatsHandler(s.tracker.StatsHandler()))
+	}
 
 	grpcServer := grpc.NewServer(opts...)
 	api.RegisterGrpcRestServiceServer(grpcServer, s.resolver)
 
+	return grpcServer
+}
+
+func (s *Server) Run(ctx context.Context) error {
+
+	if s.recorder != nil {
+		defer func() {
+			if err := s.recorder.Close(); err != nil {
+				s.log.Errorf("closing recorder: %v", err)
+			}
+		}()
+	}
+
+	grpcServer := s.NewGrpcServer()
+
 	termChan := make(chan struct{})
 	defer close(termChan)
 
@@ -1,70 +1,42 @@
 package opts
 
 import (
-	"encoding/json"
-	"fmt"
-	"gopkg.in/yaml.v3"
-	"os"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
 
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/internal/grpc"
-	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/internal/log"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/hashicorp/go-multierror"
-	"github.com/kelseyhightower/envconfig"
 )
 
-const (
-	defaultAppPrefix = "APP"
-)
+// ReloadablePaths are config fields that are applied without restart
+var ReloadablePaths = []string{
+	"log",
+	"grpc.gateway-timeout",
+	"grpc.debug-dump",
+}
 
 type Config struct {
-	Log  log.Config  `json:"log" yaml:"log"`
-	GRPC grpc.Config `json:"grpc" yaml:"grpc"`
-}
+	Log  logging.Config `json:"log" yaml:"log"`
+	GRPC grpc.Config    `json:"grpc" yaml:"grpc"`
 
-type YamlConfigLoader interface {
-	Unmarshal([]byte) error
+	// Admin serves the Admin gRPC service on its own host. It needs restart.
+	Admin admin.Config `json:"admin" yaml:"admin"`
 }
 
-func LoadConfigFromFileOrEnvs(configFile string, config interface{}) error {
+// Load merges defaults, config files, APP_* env vars and command line flags.
+// See config.Loader for the precedence.
+func Load(args []string) (*Config, *config.Result, error) {
 
-	if len(configFile) == 0 {
-		if err := FromEnv(config); err != nil {
-			return fmt.Errorf("locading opts from envs: %w", err)
-		}
+	cfg := Config{}
 
-		return nil
-	}
-
-	buf, err := os.ReadFile(configFile)
+	res, err := config.NewLoader(args).Load(&cfg)
 	if err != nil {
-		return fmt.Errorf("reading opts file: %w", err)
-	}
-
-	errYaml := yaml.Unmarshal(buf, config)
-	if errYaml == nil {
-		return nil
-	}
-
-	errJson := json.Unmarshal(buf, config)
-	if errJson == nil {
-		return nil
-	}
-
-	return multierror.Append(
-		fmt.Errorf("bad opts file [%s]. Failed to unmarshal YAML and JSON", configFile),
-		errYaml, errJson,
-	)
-}
-
-func FromEnv(config interface{}) error {
-
-	if err := envconfig.Process(defaultAppPrefix, &config); err != nil {
-		return fmt.Errorf("failed to load opts: %w", err)
+		return nil, nil, err
 	}
 
-	return nil
+	return &cfg, res, nil
 }
 
 func (c *Config) Validate() error {
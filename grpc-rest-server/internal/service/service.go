@@ -3,19 +3,25 @@ package service
 import (
 	"context"
 	"fmt"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+
 	logger "github.com/sirupsen/logrus"
 )
 
 type Service struct {
+	log *logger.Logger
 }
 
-func New() *Service {
-	return &Service{}
+func New(log *logger.Logger) *Service {
+	return &Service{
+		log: log,
+	}
 }
 
-func (svc *Service) ReactOnHello(_ context.Context, title, description string, intValue int) (string, error) {
+func (svc *Service) ReactOnHello(ctx context.Context, title, description string, intValue int) (string, error) {
 
-	logger.Debugf("service got a Hello request: %v", title)
+	logging.FromContext(ctx, svc.log).Debugf("service got a Hello request: %v", title)
 
 	return fmt.Sprintf("%s: [%s: %d]", title, description, intValue), nil
 }
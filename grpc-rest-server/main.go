@@ -5,11 +5,12 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/admin"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/internal/grpc"
-	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/internal/log"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/internal/opts"
 	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/internal/service"
 
@@ -26,61 +27,76 @@ func main() {
 
 func setup() error {
 
-	configFile := os.Getenv("CONFIG_FILE")
+	cfg, loadResult, err := opts.Load(os.Args[1:])
+	if err != nil {
+		return fmt.Errorf("building opts: %w", err)
+	}
 
-	config := opts.Config{}
+	if loadResult.PrintConfig {
+		return config.Print(os.Stdout, cfg, loadResult)
+	}
 
-	err := opts.LoadConfigFromFileOrEnvs(configFile, &config)
+	log, err := logging.New(cfg.Log)
 	if err != nil {
-		return fmt.Errorf("building opts: %w", err)
+		return fmt.Errorf("creating logger: %w", err)
+	}
+	defer func() { _ = logging.Close(log) }()
+
+	configManager, err := config.NewManager(cfg, loadResult.Files, func() (*opts.Config, error) {
+		cfg, _, err := opts.Load(os.Args[1:])
+		return cfg, err
+	}, log, opts.ReloadablePaths...)
+	if err != nil {
+		return fmt.Errorf("creating config manager: %w", err)
 	}
+	configManager.RefreshEvery(loadResult.SecretsRefresh)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	initLogger(config.Log)
-
-	return run(ctx, config)
+	return run(ctx, configManager, log)
 }
 
-func run(ctx context.Context, config opts.Config) error {
+func run(ctx context.Context, configManager *config.Manager[*opts.Config], log *logger.Logger) error {
 
-	svc := service.New()
+	cfg := configManager.Current()
+
+	svc := service.New(log)
 
 	resolver, err := grpc.NewResolver(svc)
 	if err != nil {
 		return fmt.Errorf("creating grpc resolver: %w", err)
 	}
 
-	grpcServer, err := grpc.NewServer(config.GRPC, resolver)
-	if err != nil {
-		return fmt.Errorf("creating grpc server: %w", err)
+	var tracker *admin.Tracker
+	if cfg.Admin.Enabled {
+		tracker = admin.NewTracker()
 	}
 
-	if err := grpcServer.Run(ctx); err != nil {
-		return fmt.Errorf("grpc server run: %w", err)
+	grpcServer, err := grpc.NewServer(cfg.GRPC, resolver, tracker, log)
+	if err != nil {
+		return fmt.Errorf("creating grpc server: %w", err)
 	}
 
-	return nil
-}
+	configManager.OnReload(func(cfg *opts.Config) error { return logging.Apply(log, cfg.Log) })
+	configManager.OnReload(func(cfg *opts.Config) error { return grpcServer.ApplyConfig(cfg.GRPC) })
 
-func initLogger(config log.Config) {
+	// The server has no storage, so storage stats and retention are unimplemented
+	if cfg.Admin.Enabled {
+		if err := admin.New(cfg.Admin, "grpc-rest-server", configManager, tracker, nil, log).Start(ctx); err != nil {
+			return fmt.Errorf("starting admin server: %w", err)
+		}
+	}
 
-	var lvl logger.Level
+	go func() {
+		if err := configManager.Run(ctx); err != nil {
+			log.Errorf("config reload is disabled: %v", err)
+		}
+	}()
 
-	switch strings.ToUpper(config.Level) {
-	case "TRACE":
-		lvl = logger.TraceLevel
-	case "DEBUG":
-		lvl = logger.DebugLevel
-	case "INFO":
-		lvl = logger.InfoLevel
-	case "ERROR":
-		lvl = logger.ErrorLevel
+	if err := grpcServer.Run(ctx); err != nil {
+		return fmt.Errorf("grpc server run: %w", err)
 	}
 
-	logger.SetLevel(lvl)
-	if !config.NonJson {
-		logger.SetFormatter(&logger.JSONFormatter{PrettyPrint: config.Pretty})
-	}
+	return nil
 }
@@ -0,0 +1,99 @@
+// Package testserver runs the server in-process for tests: gRPC over bufconn and REST over httptest.
+// Servers are stopped when the test ends.
+package testserver
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/config"
+	"github.com/yurii-vyrovyi/go-grpc-rest/common/logging"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/internal/grpc"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/internal/opts"
+	"github.com/yurii-vyrovyi/go-grpc-rest/grpc-rest-server/internal/service"
+
+	goGrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	// Target is a gRPC target to dial with DialOptions
+	Target = "bufnet"
+
+	bufSize = 1 << 20
+)
+
+type Server struct {
+	// URL is the base URL of the REST API
+	URL string
+
+	lis *bufconn.Listener
+}
+
+// Start runs a server with the default config
+func Start(t testing.TB) *Server {
+	t.Helper()
+
+	cfg := opts.Config{}
+	if err := config.Defaults(&cfg); err != nil {
+		t.Fatalf("applying config defaults: %v", err)
+	}
+
+	log, err := logging.New(cfg.Log)
+	if err != nil {
+		t.Fatalf("creating logger: %v", err)
+	}
+
+	resolver, err := grpc.NewResolver(service.New(log))
+	if err != nil {
+		t.Fatalf("creating grpc resolver: %v", err)
+	}
+
+	srv, err := grpc.NewServer(cfg.GRPC, resolver, nil, log)
+	if err != nil {
+		t.Fatalf("creating grpc server: %v", err)
+	}
+
+	s := Server{
+		lis: bufconn.Listen(bufSize),
+	}
+
+	grpcServer := srv.NewGrpcServer()
+	go func() { _ = grpcServer.Serve(s.lis) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := goGrpc.DialContext(context.Background(), Target, append(srv.GatewayDialOptions(), s.dialer())...)
+	if err != nil {
+		t.Fatalf("dialing grpc server: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	handler, err := srv.RestHandler(conn)
+	if err != nil {
+		t.Fatalf("creating rest handler: %v", err)
+	}
+
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	s.URL = ts.URL
+
+	return &s
+}
+
+// DialOptions connect a gRPC client to the server. They are used with Target.
+func (s *Server) DialOptions() []goGrpc.DialOption {
+	return []goGrpc.DialOption{
+		s.dialer(),
+		goGrpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+}
+
+func (s *Server) dialer() goGrpc.DialOption {
+	return goGrpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return s.lis.DialContext(ctx)
+	})
+}